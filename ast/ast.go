@@ -0,0 +1,238 @@
+package ast
+
+import "fmt"
+
+// A Position is a place in the source of a template.  A Position is valid if
+// its line is greater than zero.
+type Position struct {
+	Filename string
+	Offset   int // The offset in bytes, starting at 0.
+	Line     int // The line, starting at 1.
+	Column   int // The column in bytes, starting at 1.
+}
+
+// Reports whether the position is a place in a source.  Nodes that weren't
+// parsed from a source have invalid positions.
+func (pos Position) IsValid() bool {
+	return pos.Line > 0
+}
+
+// Returns the position in one of these forms, like go/token does:
+//
+//	file:line:column    valid position with file name
+//	line:column         valid position without file name
+//	file                invalid position with file name
+//	-                   invalid position without file name
+func (pos Position) String() string {
+	s := pos.Filename
+	if pos.IsValid() {
+		if s != "" {
+			s += ":"
+		}
+		s += fmt.Sprintf("%d:%d", pos.Line, pos.Column)
+	}
+	if s == "" {
+		s = "-"
+	}
+	return s
+}
+
+// A Node is a part of a template.  Every node knows the part of the source it
+// was parsed from.
+type Node interface {
+	// Returns the position of the first character of the node.
+	Pos() Position
+	// Returns the position of the character right after the node.
+	End() Position
+}
+
+// The part of the source a node was parsed from.
+type span struct {
+	pos Position
+	end Position
+}
+
+func (s span) Pos() Position { return s.pos }
+func (s span) End() Position { return s.end }
+
+// A File is a parsed template file.  Its template is named after the file, and
+// it can define other templates that are exported from the file as well.
+type File struct {
+	span
+	// The name of the file the template was parsed from.
+	Filename string
+	// The name of the template, which is the capitalized name of the file up
+	// to its first dot, like "Index" for "index.html.gwirl".
+	Name string
+	// The comment before the parameters of the template, or nil.
+	Doc *Comment
+	// The type parameters of the template, or nil if it isn't generic.
+	TypeParams *FieldList
+	// The parameters of the template, or nil if it doesn't declare any.
+	Params  *FieldList
+	Imports []*Import
+	Body    []Node
+	// The templates defined in the file with exported names, which are
+	// exported from the file like its own template.  Templates defined with
+	// unexported names are in the body instead.
+	Templates []*Template
+}
+
+// A Template is a template defined in a file, like
+// "@Card(title string) = { ... }".
+type Template struct {
+	span
+	// The comment right before the definition, or nil.  Only the templates
+	// with exported names have their comment here, the others have it in the
+	// content before them.
+	Doc        *Comment
+	Name       *Ident
+	TypeParams *FieldList // nil if the template isn't generic
+	Params     *FieldList
+	Body       []Node
+}
+
+// An Ident is the name of a template.
+type Ident struct {
+	span
+	Name string
+}
+
+// A FieldList is a list of parameters in parentheses, or of type parameters in
+// brackets.
+type FieldList struct {
+	span
+	List []*Field
+}
+
+// A Field is a single parameter or type parameter.  Parameters that share a
+// type, like "(a, b string)", are each a field with that type, though only the
+// last of them spans the type.
+type Field struct {
+	span
+	Name string
+	// The type of a parameter, or the constraint of a type parameter.
+	Type string
+	// The default value of a parameter, or empty when it is required.
+	Default string
+}
+
+// An Import is a Go package imported by a template, with @import.  The imports
+// of a block each have their own Import.
+type Import struct {
+	span
+	// The name the package is imported as, which is "." for a dot import and
+	// "_" for a blank import, or empty when it is imported by its own name.
+	Name string
+	// The path of the package as it is written, including its quotes.
+	Path string
+}
+
+// Text is markup that is output as is.  Escaped characters, like "@@", have
+// been replaced by the character they escape.
+type Text struct {
+	span
+	Value string
+}
+
+// A Comment is a comment, like "@* text *@", that isn't output.
+type Comment struct {
+	span
+	// The text between the "@*" and the "*@".
+	Text string
+}
+
+// A GoBlock is Go code that is run where it is written, like
+// "@{ total := 0 }".
+type GoBlock struct {
+	span
+	// The code between the braces.
+	Code string
+}
+
+// An Expr is a Go expression whose value is output, like "@user.Name",
+// "@(a + b)" or "@raw(html)".  A call of a template can also pass content to
+// it, either in blocks or in named slots.
+type Expr struct {
+	span
+	// The Go code of the expression.
+	X string
+	// Written "@!", the expression is escaped even when expressions aren't
+	// escaped by default.
+	Escape bool
+	// Written in parentheses, like "@(a + b)".
+	Safe bool
+	// Written "@raw(...)", the expression is never escaped.
+	Raw bool
+	// The content passed to the call in blocks, one for each block.
+	Blocks [][]Node
+	// The content passed to the call in named slots.  A call has either
+	// blocks or slots, not both.
+	Slots []*Slot
+}
+
+// A Slot is content passed to the parameter of a template by name, like
+// "@slot footer { ... }".
+type Slot struct {
+	span
+	Name string
+	Body []Node
+}
+
+// An If is a conditional, like "@if cond { ... } @else { ... }".
+type If struct {
+	span
+	Cond    string
+	Body    []Node
+	ElseIfs []*ElseIf
+	Else    *Else // nil if there is no @else
+}
+
+// An ElseIf is an "@else if cond { ... }" of an If.
+type ElseIf struct {
+	span
+	Cond string
+	Body []Node
+}
+
+// An Else is the "@else { ... }" of an If.
+type Else struct {
+	span
+	Body []Node
+}
+
+// A For is a loop, like "@for _, item := range items { ... }".
+type For struct {
+	span
+	// The clause of the loop, between "@for" and the brace.
+	Clause string
+	Body   []Node
+}
+
+// A Switch is a switch, like "@switch value { @case 1 { ... } }".
+type Switch struct {
+	span
+	// The expression that is switched on, which can be empty.
+	Tag   string
+	Cases []*Case
+}
+
+// A Case is a "@case values { ... }" or a "@default { ... }" of a Switch.
+type Case struct {
+	span
+	// The values of the case, or empty for the default.
+	Values  string
+	Default bool
+	Body    []Node
+}
+
+// An Error is a problem found while parsing a template.
+type Error struct {
+	Pos Position
+	End Position
+	Msg string
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("%v: %s", e.Pos, e.Msg)
+}
@@ -0,0 +1,215 @@
+package ast_test
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gamebox/gwirl/ast"
+)
+
+const source = `@* The list of items *@
+@(items []Item, title string = "Items")
+@import h "example.com/helpers"
+
+<h1>@h.Title(title)</h1>
+@for _, item := range items {
+	@if item.Done {
+		<s>@item.Name</s>
+	} @else if item.Late {
+		<b>@raw(item.HTML)</b>
+	} @else {
+		@Card(item.Name) {
+			@slot body {<p>@@me</p>}
+		}
+	}
+}
+@switch len(items) {
+	@case 0 {None}
+	@default {@{ n := len(items) }@n}
+}
+@row(item Item) = {
+	<tr>@item.Name</tr>
+}
+@* A card *@
+@Card(title string) = {
+	<div>@title</div>
+}
+`
+
+func parse(t *testing.T, src string) *ast.File {
+	t.Helper()
+	file, errs := ast.Parse("templates/list.html.gwirl", []byte(src))
+	if len(errs) > 0 {
+		t.Fatalf("Expected no errors, got %v", errs)
+	}
+	return file
+}
+
+func sourceOf(n ast.Node) string {
+	return source[n.Pos().Offset:n.End().Offset]
+}
+
+func TestParse(t *testing.T) {
+	file := parse(t, source)
+
+	if file.Name != "List" || file.Filename != "templates/list.html.gwirl" {
+		t.Errorf("Expected the List template of templates/list.html.gwirl, got %s of %s", file.Name, file.Filename)
+	}
+	if file.Doc == nil || file.Doc.Text != " The list of items " {
+		t.Errorf("Expected the doc comment of the file, got %v", file.Doc)
+	}
+	if file.TypeParams != nil {
+		t.Errorf("Expected no type params, got %v", file.TypeParams)
+	}
+	expectedParams := []ast.Field{
+		{Name: "items", Type: "[]Item"},
+		{Name: "title", Type: "string", Default: `"Items"`},
+	}
+	expectedSources := []string{"items []Item", `title string = "Items"`}
+	if len(file.Params.List) != len(expectedParams) {
+		t.Fatalf("Expected params %v, got %v", expectedParams, file.Params.List)
+	}
+	for i, field := range file.Params.List {
+		expected := expectedParams[i]
+		if field.Name != expected.Name || field.Type != expected.Type || field.Default != expected.Default {
+			t.Errorf("Expected param %v, got %v", expected, *field)
+		}
+		if got := sourceOf(field); got != expectedSources[i] {
+			t.Errorf("Expected param %s to span %q, got %q", field.Name, expectedSources[i], got)
+		}
+	}
+	if len(file.Imports) != 1 || file.Imports[0].Name != "h" || file.Imports[0].Path != `"example.com/helpers"` {
+		t.Fatalf("Expected the helpers import, got %v", file.Imports)
+	}
+	if got := sourceOf(file.Imports[0]); got != `h "example.com/helpers"` {
+		t.Errorf("Expected the import to span its spec, got %q", got)
+	}
+
+	title := file.Body[1].(*ast.Expr)
+	if title.X != "h.Title(title)" || sourceOf(title) != "@h.Title(title)" {
+		t.Errorf("Expected the title expression, got %q at %q", title.X, sourceOf(title))
+	}
+	pos := title.Pos()
+	if pos.Line != 5 || pos.Column != 5 || pos.String() != "templates/list.html.gwirl:5:5" {
+		t.Errorf("Expected the title to be at 5:5, got %v", pos)
+	}
+
+	loop := file.Body[3].(*ast.For)
+	if loop.Clause != "_, item := range items" {
+		t.Errorf("Expected the clause of the loop, got %q", loop.Clause)
+	}
+	cond := loop.Body[1].(*ast.If)
+	if cond.Cond != "item.Done" || len(cond.ElseIfs) != 1 || cond.ElseIfs[0].Cond != "item.Late" || cond.Else == nil {
+		t.Fatalf("Expected an if with an else if and an else, got %v", cond)
+	}
+	raw := cond.ElseIfs[0].Body[1].(*ast.Expr)
+	if !raw.Raw || raw.X != "item.HTML" {
+		t.Errorf("Expected a raw expression, got %v", raw)
+	}
+	card := cond.Else.Body[1].(*ast.Expr)
+	if card.X != "Card(item.Name)" || len(card.Blocks) != 0 || len(card.Slots) != 1 {
+		t.Fatalf("Expected a call with a slot, got %v", card)
+	}
+	slot := card.Slots[0]
+	if slot.Name != "body" || slot.Body[0].(*ast.Text).Value != "<p>@me</p>" {
+		t.Errorf("Expected the body slot with escaped text, got %v", slot)
+	}
+
+	sw := file.Body[5].(*ast.Switch)
+	if sw.Tag != "len(items)" || len(sw.Cases) != 2 || sw.Cases[0].Values != "0" || !sw.Cases[1].Default {
+		t.Fatalf("Expected a switch with a case and a default, got %v", sw)
+	}
+	if block := sw.Cases[1].Body[0].(*ast.GoBlock); strings.TrimSpace(block.Code) != "n := len(items)" {
+		t.Errorf("Expected a Go block, got %q", block.Code)
+	}
+
+	row := file.Body[7].(*ast.Template)
+	if row.Name.Name != "row" || sourceOf(row.Name) != "row" || sourceOf(row.Params) != "(item Item)" {
+		t.Errorf("Expected the row template in the body, got %v", row)
+	}
+	if len(file.Templates) != 1 {
+		t.Fatalf("Expected one exported template, got %d", len(file.Templates))
+	}
+	exported := file.Templates[0]
+	if exported.Name.Name != "Card" || exported.Doc == nil || exported.Doc.Text != " A card " {
+		t.Errorf("Expected the Card template with its doc, got %v", exported)
+	}
+	if got := sourceOf(exported); got != "@Card(title string) = {\n\t<div>@title</div>\n}" {
+		t.Errorf("Expected the Card template to span its definition, got %q", got)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	file, errs := ast.Parse("broken.html.gwirl", []byte("@(name string)\n@if name {\n<p>@name</p>\n"))
+	if file == nil || file.Name != "Broken" {
+		t.Fatalf("Expected a file even with errors, got %v", file)
+	}
+	if len(errs) != 1 {
+		t.Fatalf("Expected one error, got %v", errs)
+	}
+	expected := "broken.html.gwirl:2:10: Expected '}' to close the block"
+	if errs[0].Error() != expected {
+		t.Errorf("Expected %q, got %q", expected, errs[0].Error())
+	}
+}
+
+func TestInspect(t *testing.T) {
+	file := parse(t, source)
+	var visited []string
+	depth := 0
+	ast.Inspect(file, func(n ast.Node) bool {
+		if n == nil {
+			depth--
+			return true
+		}
+		visited = append(visited, strings.Repeat(" ", depth)+strings.TrimPrefix(fmt.Sprintf("%T", n), "*ast."))
+		depth++
+		return true
+	})
+	if depth != 0 {
+		t.Errorf("Expected a nil visit for every node, %d left", depth)
+	}
+	expected := []string{
+		"File",
+		" Comment",
+		" FieldList",
+		" Import",
+		" Text",
+		" Expr",
+		" Text",
+		" For",
+		"  Text",
+		"  If",
+	}
+	if !reflect.DeepEqual(visited[:len(expected)], expected) {
+		t.Errorf("Expected the walk to start with\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(visited, "\n"))
+	}
+	// The exported template is the last child of the file.
+	idx := len(visited) - 1
+	for idx > 0 && visited[idx] != " Template" {
+		idx--
+	}
+	expected = []string{" Template", "  Comment", "  Ident", "  FieldList", "  Text", "  Expr", "  Text"}
+	if !reflect.DeepEqual(visited[idx:], expected) {
+		t.Errorf("Expected the walk to end with\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(visited[idx:], "\n"))
+	}
+}
+
+func TestInspectSkipsChildren(t *testing.T) {
+	file := parse(t, source)
+	exprs := 0
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.For, *ast.Template:
+			return false
+		case *ast.Expr:
+			exprs++
+		}
+		return true
+	})
+	if exprs != 2 {
+		t.Errorf("Expected only the 2 expressions outside of the loop and templates, got %d", exprs)
+	}
+}
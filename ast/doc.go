@@ -0,0 +1,28 @@
+// Package ast parses gwirl templates into syntax trees, for tools that work
+// with templates, like linters, formatters and generators.
+//
+// Parse parses the source of a template file into a File, whose body is a
+// list of nodes, like Text for markup and Expr for the expressions whose
+// values are output.  Walk and Inspect visit every node of a tree, the way
+// their namesakes in go/ast do:
+//
+//	file, errs := ast.Parse("index.html.gwirl", src)
+//	ast.Inspect(file, func(n ast.Node) bool {
+//		if expr, ok := n.(*ast.Expr); ok && expr.Raw {
+//			fmt.Printf("%v: raw output of %s\n", expr.Pos(), expr.X)
+//		}
+//		return true
+//	})
+//
+// The Go code in a template, like the condition of an If, is kept as the text
+// it was written as, which go/parser can parse further.
+//
+// # Stability
+//
+// The API of this package follows the compatibility promise of Go itself: the
+// exported names it has won't be removed or changed in incompatible ways
+// within a major version of the module.  As the template language grows, new
+// node types and new fields of existing ones can be added, so a type switch
+// over nodes should have a default case.  The messages of errors can change
+// too, and should not be matched on.
+package ast
@@ -0,0 +1,45 @@
+package ast_test
+
+import (
+	"fmt"
+
+	"github.com/gamebox/gwirl/ast"
+)
+
+// A check that reports the expressions that are output without escaping.
+func ExampleInspect() {
+	src := `@(user User)
+<h1>@user.Name</h1>
+@if user.Admin {
+	<div>@raw(user.Bio)</div>
+}
+`
+	file, errs := ast.Parse("profile.html.gwirl", []byte(src))
+	if len(errs) > 0 {
+		fmt.Println(errs)
+		return
+	}
+	ast.Inspect(file, func(n ast.Node) bool {
+		if expr, ok := n.(*ast.Expr); ok && expr.Raw {
+			fmt.Printf("%v: %s is not escaped\n", expr.Pos(), expr.X)
+		}
+		return true
+	})
+	// Output:
+	// profile.html.gwirl:4:7: user.Bio is not escaped
+}
+
+func ExampleParse() {
+	src := `@(items []string)
+@for _, item := range items {
+	<li>@item</li>
+}
+`
+	file, _ := ast.Parse("list.html.gwirl", []byte(src))
+	fmt.Println(file.Name, file.Params.List[0].Name, file.Params.List[0].Type)
+	loop := file.Body[0].(*ast.For)
+	fmt.Printf("%v: for %s\n", loop.Pos(), loop.Clause)
+	// Output:
+	// List items []string
+	// list.html.gwirl:2:1: for _, item := range items
+}
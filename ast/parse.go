@@ -0,0 +1,257 @@
+package ast
+
+import (
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gamebox/gwirl/internal/parser"
+)
+
+// Parses the source of a template file.  The file name is used for the
+// positions of the nodes and errors, and to name the template the way gwirl
+// does when it generates it.
+//
+// The parser recovers from the problems it finds, so a file is returned even
+// when there are errors, with as much of the template as could be parsed.
+func Parse(filename string, src []byte) (*File, []Error) {
+	p := parser.NewParser2("")
+	res := p.Parse(string(src), templateName(filename))
+	c := converter{filename: filename, lines: res.Lines()}
+
+	t := &res.Template
+	file := &File{
+		span:       c.span(t.Range()),
+		Filename:   filename,
+		Name:       t.Name.Str,
+		Doc:        c.comment(t.Comment),
+		TypeParams: c.fieldList(&t.TypeParams, parser.ParseTypeParamsAt),
+		Params:     c.fieldList(&t.Params, parser.ParseParamsAt),
+		Imports:    make([]*Import, 0, len(t.TopImports)),
+		Body:       c.content(t.Content),
+		Templates:  make([]*Template, 0, len(t.Templates)),
+	}
+	for _, imp := range t.TopImports {
+		file.Imports = append(file.Imports, &Import{
+			span: c.span(imp.Range()),
+			Name: imp.Name.Str,
+			Path: imp.Path.Str,
+		})
+	}
+	for i := range t.Templates {
+		sub := &t.Templates[i]
+		file.Templates = append(file.Templates, &Template{
+			span:       c.span(sub.Range()),
+			Doc:        c.comment(sub.Comment),
+			Name:       &Ident{span: c.span(sub.Name.Range()), Name: sub.Name.Str},
+			TypeParams: c.fieldList(&sub.TypeParams, parser.ParseTypeParamsAt),
+			Params:     c.fieldList(&sub.Params, parser.ParseParamsAt),
+			Body:       c.content(sub.Content),
+		})
+	}
+
+	errs := make([]Error, 0, len(res.Errors))
+	for _, e := range res.Errors {
+		errs = append(errs, Error{
+			Pos: c.position(e.Start),
+			End: c.position(e.End),
+			Msg: e.Err,
+		})
+	}
+	return file, errs
+}
+
+// Returns the name gwirl gives the template of a file, which is the
+// capitalized name of the file up to its first dot.
+func templateName(filename string) string {
+	name, _, _ := strings.Cut(filepath.Base(filename), ".")
+	first, size := utf8.DecodeRuneInString(name)
+	if size == 0 {
+		return ""
+	}
+	return string(unicode.ToUpper(first)) + name[size:]
+}
+
+// Converts the trees of the parser into nodes.
+type converter struct {
+	filename string
+	lines    *parser.LineIndex
+}
+
+func (c *converter) position(pos parser.Position) Position {
+	if pos == nil {
+		return Position{}
+	}
+	return c.offsetPosition(c.lines.Offset(pos.Line(), pos.Column()))
+}
+
+func (c *converter) offsetPosition(offset int) Position {
+	pos := c.lines.Position(offset)
+	return Position{
+		Filename: c.filename,
+		Offset:   pos.Offset(),
+		Line:     pos.Line(),
+		Column:   pos.Column() + 1,
+	}
+}
+
+func (c *converter) span(r parser.Range) span {
+	if r.IsEmpty() {
+		return span{}
+	}
+	return span{pos: c.offsetPosition(r.Start.Offset()), end: c.offsetPosition(r.End.Offset())}
+}
+
+func (c *converter) offsetSpan(start int, length int) span {
+	return span{pos: c.offsetPosition(start), end: c.offsetPosition(start + length)}
+}
+
+// Returns the fields of a list of parameters, or nil when the list wasn't
+// written in the source.
+func (c *converter) fieldList(ps *parser.PosString, parse paramParser) *FieldList {
+	if ps.Range().IsEmpty() {
+		return nil
+	}
+	return c.fields(ps.Range().Start.Offset(), ps.Str, parse)
+}
+
+// A function that splits a list of parameters found at an offset of a source,
+// like parser.ParseParamsAt.
+type paramParser func(list string, offset int, lines *parser.LineIndex) []parser.Param
+
+// Returns the fields of a list of parameters that starts at an offset of the
+// source.
+func (c *converter) fields(start int, list string, parse paramParser) *FieldList {
+	params := parse(list, start, c.lines)
+	fields := &FieldList{span: c.offsetSpan(start, len(list)), List: make([]*Field, 0, len(params))}
+	for _, param := range params {
+		fields.List = append(fields.List, &Field{span: c.span(param.Range), Name: param.Name, Type: param.Type, Default: param.Default})
+	}
+	return fields
+}
+
+func (c *converter) comment(tree *parser.TemplateTree2) *Comment {
+	if tree == nil {
+		return nil
+	}
+	return &Comment{span: c.span(tree.Range()), Text: tree.Text}
+}
+
+func (c *converter) content(trees []parser.TemplateTree2) []Node {
+	nodes := make([]Node, 0, len(trees))
+	for i := range trees {
+		if node := c.node(&trees[i]); node != nil {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// Returns the content of the first block of a tree, which is where the trees
+// with a single block keep it.
+func (c *converter) body(tree *parser.TemplateTree2) []Node {
+	if len(tree.Children) == 0 {
+		return []Node{}
+	}
+	return c.content(tree.Children[0])
+}
+
+func (c *converter) node(tree *parser.TemplateTree2) Node {
+	s := c.span(tree.Range())
+	text := strings.TrimSpace(tree.Text)
+	switch tree.Type {
+	case parser.TT2Plain:
+		return &Text{span: s, Value: tree.Text}
+	case parser.TT2BlockComment, parser.TT2LineComment:
+		return &Comment{span: s, Text: tree.Text}
+	case parser.TT2GoBlock:
+		code := strings.TrimSuffix(strings.TrimPrefix(tree.Text, "{"), "}")
+		return &GoBlock{span: s, Code: code}
+	case parser.TT2GoExp:
+		return c.expr(tree, s)
+	case parser.TT2If:
+		return c.ifNode(tree, s)
+	case parser.TT2For:
+		return &For{span: s, Clause: text, Body: c.body(tree)}
+	case parser.TT2Switch:
+		sw := &Switch{span: s, Tag: text, Cases: []*Case{}}
+		if len(tree.Children) > 0 {
+			for i := range tree.Children[0] {
+				cs := &tree.Children[0][i]
+				sw.Cases = append(sw.Cases, &Case{
+					span:    c.span(cs.Range()),
+					Values:  strings.TrimSpace(cs.Text),
+					Default: cs.Type == parser.TT2Default,
+					Body:    c.body(cs),
+				})
+			}
+		}
+		return sw
+	case parser.TT2Slot:
+		return &Slot{span: s, Name: tree.Text, Body: c.body(tree)}
+	case parser.TT2TemplateDef:
+		return c.templateDef(tree, s)
+	}
+	return nil
+}
+
+func (c *converter) expr(tree *parser.TemplateTree2, s span) *Expr {
+	expr := &Expr{
+		span:   s,
+		X:      tree.Text,
+		Escape: tree.Metadata.Has(parser.TTMDEscape),
+		Safe:   tree.Metadata.Has(parser.TTMDSafe),
+		Raw:    tree.Metadata.Has(parser.TTMDRaw),
+	}
+	if tree.Metadata.Has(parser.TTMDSlots) {
+		expr.Slots = []*Slot{}
+		for i := range tree.Children[0] {
+			slot := &tree.Children[0][i]
+			expr.Slots = append(expr.Slots, &Slot{span: c.span(slot.Range()), Name: slot.Text, Body: c.body(slot)})
+		}
+		return expr
+	}
+	for _, block := range tree.Children {
+		expr.Blocks = append(expr.Blocks, c.content(block))
+	}
+	return expr
+}
+
+// The children of an if are its content, its else ifs and, if it has one, its
+// else.
+func (c *converter) ifNode(tree *parser.TemplateTree2, s span) *If {
+	node := &If{span: s, Cond: strings.TrimSpace(tree.Text), Body: c.body(tree), ElseIfs: []*ElseIf{}}
+	if len(tree.Children) > 1 {
+		for i := range tree.Children[1] {
+			elseIf := &tree.Children[1][i]
+			node.ElseIfs = append(node.ElseIfs, &ElseIf{
+				span: c.span(elseIf.Range()),
+				Cond: strings.TrimSpace(elseIf.Text),
+				Body: c.body(elseIf),
+			})
+		}
+	}
+	if len(tree.Children) > 2 && len(tree.Children[2]) > 0 {
+		elseTree := &tree.Children[2][0]
+		node.Else = &Else{span: c.span(elseTree.Range()), Body: c.body(elseTree)}
+	}
+	return node
+}
+
+// Templates defined with unexported names stay in the content, with their
+// declaration as their text.
+func (c *converter) templateDef(tree *parser.TemplateTree2, s span) *Template {
+	name, typeParams, params := tree.Declaration()
+	start := tree.TextRange().Start.Offset()
+	t := &Template{
+		span:   s,
+		Name:   &Ident{span: c.offsetSpan(start, len(name)), Name: name},
+		Params: c.fields(start+len(name)+len(typeParams), params, parser.ParseParamsAt),
+		Body:   c.body(tree),
+	}
+	if typeParams != "" {
+		t.TypeParams = c.fields(start+len(name), typeParams, parser.ParseTypeParamsAt)
+	}
+	return t
+}
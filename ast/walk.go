@@ -0,0 +1,124 @@
+package ast
+
+import "fmt"
+
+// A Visitor's Visit method is called for each node found by Walk.  If the
+// visitor it returns is not nil, Walk visits each of the children of the node
+// with it, and then calls its Visit method with nil.
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walks a template in depth-first order, the way go/ast.Walk does.  It calls
+// v.Visit(node), and unless that returns nil, walks each of the children of
+// the node with the visitor it returned, followed by a call of Visit with nil.
+//
+// The children of a node are visited in the order they appear in the source,
+// except for the templates of a file, which are visited after its body.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *File:
+		if n.Doc != nil {
+			Walk(v, n.Doc)
+		}
+		if n.TypeParams != nil {
+			Walk(v, n.TypeParams)
+		}
+		if n.Params != nil {
+			Walk(v, n.Params)
+		}
+		for _, imp := range n.Imports {
+			Walk(v, imp)
+		}
+		walkList(v, n.Body)
+		for _, t := range n.Templates {
+			Walk(v, t)
+		}
+
+	case *Template:
+		if n.Doc != nil {
+			Walk(v, n.Doc)
+		}
+		if n.Name != nil {
+			Walk(v, n.Name)
+		}
+		if n.TypeParams != nil {
+			Walk(v, n.TypeParams)
+		}
+		if n.Params != nil {
+			Walk(v, n.Params)
+		}
+		walkList(v, n.Body)
+
+	case *Expr:
+		for _, block := range n.Blocks {
+			walkList(v, block)
+		}
+		for _, slot := range n.Slots {
+			Walk(v, slot)
+		}
+
+	case *Slot:
+		walkList(v, n.Body)
+
+	case *If:
+		walkList(v, n.Body)
+		for _, elseIf := range n.ElseIfs {
+			Walk(v, elseIf)
+		}
+		if n.Else != nil {
+			Walk(v, n.Else)
+		}
+
+	case *ElseIf:
+		walkList(v, n.Body)
+
+	case *Else:
+		walkList(v, n.Body)
+
+	case *For:
+		walkList(v, n.Body)
+
+	case *Switch:
+		for _, c := range n.Cases {
+			Walk(v, c)
+		}
+
+	case *Case:
+		walkList(v, n.Body)
+
+	case *Ident, *FieldList, *Import, *Text, *Comment, *GoBlock:
+		// Nothing to walk.
+
+	default:
+		panic(fmt.Sprintf("ast.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+func walkList(v Visitor, nodes []Node) {
+	for _, node := range nodes {
+		Walk(v, node)
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Walks a template in depth-first order, calling f(node) for each node.  If f
+// returns true, Inspect goes on to the children of the node, followed by a
+// call of f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
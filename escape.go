@@ -0,0 +1,273 @@
+package gwirl
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	entity "html"
+	html "html/template"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// HTML is a known safe fragment of HTML, such as the output of a template.  It
+// is not escaped when output with `@!`.
+type HTML string
+
+// URL is a known safe URL.  It is not filtered or escaped when output in a URL
+// attribute.
+type URL string
+
+// JS is a known safe JavaScript expression.  It is not escaped when output in a
+// script.
+type JS string
+
+// CSS is a known safe CSS value.  It is not escaped when output in a stylesheet
+// or style attribute.
+type CSS string
+
+// The value substituted for content that is not safe to output in a URL or
+// CSS context.  This mirrors what html/template does.
+const unsafeContent = "ZgotmplZ"
+
+// Escapes a value that is being output at the start of a URL attribute, such as
+// href or src.  Values using any scheme other than http, https, or mailto are
+// replaced with "#ZgotmplZ", and the rest of the URL is normalized.
+func EscapeURL(value interface{}) string {
+	if u, ok := value.(URL); ok {
+		return string(u)
+	}
+	s := fmt.Sprintf("%v", value)
+	if i := strings.IndexAny(s, ":/?#"); i > 0 && s[i] == ':' {
+		scheme := strings.ToLower(s[:i])
+		if scheme != "http" && scheme != "https" && scheme != "mailto" {
+			return "#" + unsafeContent
+		}
+	}
+	return normalizeURL(s)
+}
+
+// Escapes a value that is being output in the path portion of a URL attribute,
+// after the scheme and host have already been written.
+func EscapeURLPath(value interface{}) string {
+	if u, ok := value.(URL); ok {
+		return string(u)
+	}
+	return normalizeURL(fmt.Sprintf("%v", value))
+}
+
+// Escapes a value that is being output in the query or fragment portion of a
+// URL attribute.
+func EscapeURLQuery(value interface{}) string {
+	if u, ok := value.(URL); ok {
+		return string(u)
+	}
+	return url.QueryEscape(fmt.Sprintf("%v", value))
+}
+
+func isURLSafe(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.IndexByte("-._~!#$&*+,/:;=?@[]%", c) >= 0
+}
+
+func normalizeURL(s string) string {
+	sb := strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if isURLSafe(c) {
+			sb.WriteByte(c)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
+
+// Escapes a value that is being output in a JavaScript context, outside of any
+// string literal.  The value is written as a JavaScript literal, so strings
+// become quoted strings and structs become objects.
+func EscapeJS(value interface{}) string {
+	if js, ok := value.(JS); ok {
+		return string(js)
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return "null"
+	}
+	return string(b)
+}
+
+// Escapes a value that is being output inside of a JavaScript string literal.
+// Line breaks and slashes are escaped as well, so the value is also safe in a
+// JavaScript comment.
+func EscapeJSString(value interface{}) string {
+	s := html.JSEscapeString(fmt.Sprintf("%v", value))
+	return jsStringReplacer.Replace(s)
+}
+
+var jsStringReplacer = strings.NewReplacer("`", `\u0060`, "/", `\/`)
+
+// Escapes a value that is being output inside of a JavaScript regular
+// expression literal, so that it matches the value literally.
+func EscapeJSRegexp(value interface{}) string {
+	s := EscapeJSString(value)
+	sb := strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte("$()*+.?[]^{|}", s[i]) >= 0 {
+			sb.WriteByte('\\')
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+// Escapes a value that is being output as a CSS value, outside of any string
+// literal.  Values that could break out of the declaration are replaced with
+// "ZgotmplZ".
+func EscapeCSS(value interface{}) string {
+	if css, ok := value.(CSS); ok {
+		return string(css)
+	}
+	s := fmt.Sprintf("%v", value)
+	if strings.ContainsAny(s, "\x00\"'()/;@[\\]`{}<>\n\r\f") {
+		return unsafeContent
+	}
+	lower := strings.ToLower(s)
+	if strings.Contains(lower, "expression") || strings.Contains(lower, "mozbinding") {
+		return unsafeContent
+	}
+	return s
+}
+
+// Escapes a value that is being output inside of a CSS string literal.
+func EscapeCSSString(value interface{}) string {
+	s := fmt.Sprintf("%v", value)
+	sb := strings.Builder{}
+	for _, r := range s {
+		if strings.ContainsRune("\x00\t\n\f\r\"&'()+/:;<>\\{}", r) {
+			fmt.Fprintf(&sb, "\\%x ", r)
+		} else {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// Returns the text of a value that is output in an attribute.  Values of type
+// HTML and other Content aren't trusted there, since markup has no meaning in an
+// attribute, so their tags are stripped and their character references decoded,
+// leaving the text they display, the way html/template does.
+func attrText(value interface{}) string {
+	c, ok := value.(Content)
+	if !ok {
+		return fmt.Sprintf("%v", value)
+	}
+	return entity.UnescapeString(stripTags(string(Render(c))))
+}
+
+// Returns HTML without its tags and comments.
+func stripTags(s string) string {
+	sb := strings.Builder{}
+	for s != "" {
+		i := strings.IndexByte(s, '<')
+		if i < 0 {
+			sb.WriteString(s)
+			break
+		}
+		sb.WriteString(s[:i])
+		s = s[i:]
+		end := ">"
+		if strings.HasPrefix(s, "<!--") {
+			end = "-->"
+		}
+		j := strings.Index(s, end)
+		if j < 0 {
+			break
+		}
+		s = s[j+len(end):]
+	}
+	return sb.String()
+}
+
+// Writes the value escaped for a quoted attribute value.
+func WriteEscapedAttr(builder io.StringWriter, value interface{}) {
+	builder.WriteString(html.HTMLEscapeString(attrText(value)))
+}
+
+// Writes the value escaped for an unquoted attribute value, which ends at the
+// first whitespace.  Whitespace and every character that could end the value
+// or start another attribute are written as character references, the way
+// html/template does.  Empty values are replaced with "ZgotmplZ", so that the
+// attribute after the value doesn't become its value.
+func WriteEscapedUnquotedAttr(builder io.StringWriter, value interface{}) {
+	s := attrText(value)
+	if s == "" {
+		builder.WriteString(unsafeContent)
+		return
+	}
+	sb := strings.Builder{}
+	for _, r := range s {
+		switch r {
+		case '&':
+			sb.WriteString("&amp;")
+		case '<':
+			sb.WriteString("&lt;")
+		case '>':
+			sb.WriteString("&gt;")
+		case '\t', '\n', '\v', '\f', '\r', ' ', '"', '\'', '+', '=', '`':
+			fmt.Fprintf(&sb, "&#%d;", r)
+		case 0:
+			sb.WriteRune('\uFFFD')
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	builder.WriteString(sb.String())
+}
+
+// Writes the value escaped for XML text or a quoted XML attribute.  Values of
+// type HTML, such as the output of another template, and other Content are
+// written as is.
+func WriteEscapedXML(builder io.StringWriter, value interface{}) {
+	if c, ok := value.(Content); ok {
+		writeContent(builder, c)
+		return
+	}
+	sb := strings.Builder{}
+	xml.EscapeText(&sb, []byte(fmt.Sprintf("%v", value)))
+	builder.WriteString(sb.String())
+}
+
+// Writes the value inside of an XML CDATA section, splitting the section
+// wherever the value contains "]]>" so it cannot end the section early.
+func WriteEscapedCDATA(builder io.StringWriter, value interface{}) {
+	builder.WriteString(strings.ReplaceAll(fmt.Sprintf("%v", value), "]]>", "]]]]><![CDATA[>"))
+}
+
+// Writes the value with every Markdown control character escaped with a
+// backslash, so it is rendered as literal text.  Values of type HTML and other
+// Content are written as is.
+func WriteEscapedMarkdown(builder io.StringWriter, value interface{}) {
+	if c, ok := value.(Content); ok {
+		writeContent(builder, c)
+		return
+	}
+	s := fmt.Sprintf("%v", value)
+	sb := strings.Builder{}
+	for _, r := range s {
+		if strings.ContainsRune("\\`*_{}[]()<>#+-.!|~&", r) {
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(r)
+	}
+	builder.WriteString(sb.String())
+}
+
+// Writes the value to plain text output, where there is nothing to escape.
+func WriteEscapedText(builder io.StringWriter, value interface{}) {
+	builder.WriteString(fmt.Sprintf("%v", value))
+}
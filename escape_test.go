@@ -0,0 +1,117 @@
+package gwirl
+
+import (
+	"io"
+	"testing"
+)
+
+var escapeTests = []struct {
+	name     string
+	escape   func(interface{}) string
+	value    interface{}
+	expected string
+}{
+	{"url", EscapeURL, "https://example.com/a b", "https://example.com/a%20b"},
+	{"relative url", EscapeURL, "/users/1", "/users/1"},
+	{"javascript url", EscapeURL, "javascript:alert(1)", "#ZgotmplZ"},
+	{"url path", EscapeURLPath, `a"b`, "a%22b"},
+	{"url query", EscapeURLQuery, "a&b=c", "a%26b%3Dc"},
+	{"js string value", EscapeJS, "</script>", `"\u003c/script\u003e"`},
+	{"js number value", EscapeJS, 42, "42"},
+	{"js string", EscapeJSString, `it's "x"`, `it\'s \"x\"`},
+	{"js string line break", EscapeJSString, "a\n*/b", `a\u000A*\/b`},
+	{"js regexp", EscapeJSRegexp, "a.b/c(d)", `a\.b\/c\(d\)`},
+	{"css", EscapeCSS, "red", "red"},
+	{"unsafe css", EscapeCSS, "red;background:url(x)", "ZgotmplZ"},
+	{"css string", EscapeCSSString, "a'b", `a\27 b`},
+}
+
+func TestEscapers(t *testing.T) {
+	for _, test := range escapeTests {
+		t.Run(test.name, func(t *testing.T) {
+			result := test.escape(test.value)
+			if result != test.expected {
+				t.Fatalf("Expected `%s`, got `%s`", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestTrustedValues(t *testing.T) {
+	b := TemplateBuilder{}
+	WriteEscapedHTML(&b, HTML("<p>Hi</p>"))
+	WriteEscapedHTML(&b, "<p>Hi</p>")
+	if b.String() != "<p>Hi</p>&lt;p&gt;Hi&lt;/p&gt;" {
+		t.Fatalf("Expected only the plain string to be escaped, got `%s`", b.String())
+	}
+	if EscapeURL(URL("javascript:void(0)")) != "javascript:void(0)" {
+		t.Fatal("Expected URL value to be passed through")
+	}
+	if EscapeJS(JS("a.b")) != "a.b" {
+		t.Fatal("Expected JS value to be passed through")
+	}
+	if EscapeCSS(CSS("url(a.png)")) != "url(a.png)" {
+		t.Fatal("Expected CSS value to be passed through")
+	}
+}
+
+var writerTests = []struct {
+	name     string
+	write    func(io.StringWriter, interface{})
+	value    interface{}
+	expected string
+}{
+	{"attribute", WriteEscapedAttr, `"a" & <b>`, "&#34;a&#34; &amp; &lt;b&gt;"},
+	{"attribute template output", WriteEscapedAttr, HTML(`<b title="x">Tom</b> &amp; "Jerry"<!-- c -->`), "Tom &amp; &#34;Jerry&#34;"},
+	{"unquoted attribute template output", WriteEscapedUnquotedAttr, HTML("<b>a b</b>"), "a&#32;b"},
+	{"unquoted attribute", WriteEscapedUnquotedAttr, "a onclick=alert(1)", "a&#32;onclick&#61;alert(1)"},
+	{"unquoted attribute markup", WriteEscapedUnquotedAttr, "x><script>`", "x&gt;&lt;script&gt;&#96;"},
+	{"empty unquoted attribute", WriteEscapedUnquotedAttr, "", "ZgotmplZ"},
+	{"xml", WriteEscapedXML, `<a href="x">Tom & Jerry's</a>`, "&lt;a href=&#34;x&#34;&gt;Tom &amp; Jerry&#39;s&lt;/a&gt;"},
+	{"xml template output", WriteEscapedXML, HTML("<item/>"), "<item/>"},
+	{"cdata", WriteEscapedCDATA, "a]]>b", "a]]]]><![CDATA[>b"},
+	{"markdown", WriteEscapedMarkdown, "# *Hi* [x](y)", `\# \*Hi\* \[x\]\(y\)`},
+	{"text", WriteEscapedText, "<b>&</b>", "<b>&</b>"},
+	{"content", WriteEscapedHTML, boldContent, "<b>a b</b>"},
+	{"raw content", WriteRawHTML, boldContent, "<b>a b</b>"},
+	{"attribute content", WriteEscapedUnquotedAttr, boldContent, "a&#32;b"},
+	{"xml content", WriteEscapedXML, boldContent, "<b>a b</b>"},
+	{"text content", WriteEscapedText, boldContent, "<b>a b</b>"},
+}
+
+var boldContent = ContentFunc(func(w io.Writer) error {
+	_, err := io.WriteString(w, "<b>a b</b>")
+	return err
+})
+
+func TestFiletypeWriters(t *testing.T) {
+	for _, test := range writerTests {
+		t.Run(test.name, func(t *testing.T) {
+			b := TemplateBuilder{}
+			test.write(&b, test.value)
+			if b.String() != test.expected {
+				t.Fatalf("Expected `%s`, got `%s`", test.expected, b.String())
+			}
+		})
+	}
+}
+
+func TestContentStreams(t *testing.T) {
+	b := TemplateBuilder{}
+	w := TemplateWriter{Writer: &b}
+	var target io.Writer
+	WriteEscapedHTML(&w, ContentFunc(func(out io.Writer) error {
+		target = out
+		_, err := io.WriteString(out, "<p>Hi</p>")
+		return err
+	}))
+	if b.String() != "<p>Hi</p>" {
+		t.Fatalf("Expected the content to be written as is, got `%s`", b.String())
+	}
+	if target != &w {
+		t.Fatal("Expected the content to be written straight to the writer")
+	}
+	if Render(HTML("<p>Hi</p>")) != "<p>Hi</p>" || Render(nil) != "" {
+		t.Fatal("Expected HTML to render as itself")
+	}
+}
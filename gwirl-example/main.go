@@ -6,13 +6,14 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/gamebox/gwirl"
 	"github.com/gamebox/gwirl/gwirl-example/model"
 	"github.com/gamebox/gwirl/gwirl-example/views/html"
 )
 
 func renderTemplate(responseWriter http.ResponseWriter, request *http.Request) {
 	template := strings.TrimPrefix(request.URL.Path, "/template/")
-	var content string
+	var content gwirl.HTML
 	switch template {
 	case "transcluded":
 		content = html.Transcluded("Bob", 0)
@@ -12,26 +12,34 @@ import (
 var testTemplateFile string
 
 func TestAddImportTokens(t *testing.T) {
-	tokens := make([]absToken, 0, 2)
-	topImports := []parser.PosString{}
-	import1 := parser.NewPosString("import \"fmt\"")
-	import1.SetPos(PlainPosition{line: 1, column: 1})
-	topImports = append(topImports, import1)
-	template := parser.NewTemplate2(parser.NewPosString("Test"), nil, parser.NewPosString(""), topImports, []parser.TemplateTree2{})
-	tokens = AddImportsTokens(&template, tokens)
+	p := parser.NewParser2("")
+	res := p.Parse("@import \"fmt\"\n@import (\n\th \"example.com/helpers\"\n)\n", "Test")
+	if len(res.Errors) > 0 {
+		t.Fatalf("Template failed to parse: %v", res.Errors)
+	}
+	tokens := AddImportsTokens(&res.Template, []absToken{})
 	expected := []absToken{
 		{0, 0, 1, protocol.SemanticTokenOperator},
-		{0, 2, 6, protocol.SemanticTokenKeyword},
-		{0, 7, 5, protocol.SemanticTokenString},
+		{0, 1, 6, protocol.SemanticTokenKeyword},
+		{0, 8, 5, protocol.SemanticTokenString},
+		{1, 0, 1, protocol.SemanticTokenOperator},
+		{1, 1, 6, protocol.SemanticTokenKeyword},
+		{2, 1, 1, protocol.SemanticTokenVariable},
+		{2, 3, 21, protocol.SemanticTokenString},
 	}
 	checkTokens(tokens, expected, t)
+	for i := range tokens {
+		if tokens[i] != expected[i] {
+			t.Errorf("Expected token %v, got %v", expected[i], tokens[i])
+		}
+	}
 }
 
 func TestAddParamsTokens(t *testing.T) {
 	tokens := make([]absToken, 0, 4)
 	params := parser.NewPosString("(name string, index int)")
 	params.SetPos(PlainPosition{line: 1, column: 1})
-	template := parser.NewTemplate2(parser.NewPosString("Test"), nil, params, []parser.PosString{}, []parser.TemplateTree2{})
+	template := parser.NewTemplate2(parser.NewPosString("Test"), nil, params, []parser.Import{}, []parser.TemplateTree2{})
 	tokens = AddParamsTokens(&template, tokens)
 	expected := []absToken{
 		{0, 0, 1, protocol.SemanticTokenOperator},
@@ -43,6 +51,25 @@ func TestAddParamsTokens(t *testing.T) {
 	checkTokens(tokens, expected, t)
 }
 
+func TestAddParamsTokensWithTypeParams(t *testing.T) {
+	p := parser.NewParser2("")
+	res := p.Parse("@[K comparable, V any](m map[K]V)\n", "Test")
+	if len(res.Errors) > 0 {
+		t.Fatal("Template failed to parse")
+	}
+	tokens := AddParamsTokens(&res.Template, []absToken{})
+	expected := []absToken{
+		{0, 0, 1, protocol.SemanticTokenOperator},
+		{0, 2, 1, protocol.SemanticTokenTypeParameter},
+		{0, 4, 10, protocol.SemanticTokenType},
+		{0, 16, 1, protocol.SemanticTokenTypeParameter},
+		{0, 18, 3, protocol.SemanticTokenType},
+		{0, 23, 1, protocol.SemanticTokenParameter},
+		{0, 25, 8, protocol.SemanticTokenType},
+	}
+	checkTokens(tokens, expected, t)
+}
+
 func TestCreateSemanticTokensForTemplate(t *testing.T) {
 	p := parser.NewParser2("")
 	res := p.Parse(testTemplateFile, "Test")
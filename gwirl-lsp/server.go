@@ -444,44 +444,25 @@ func (s *GwirlLspServer) Definition(ctx context.Context, params *lsp.DefinitionP
 	if contents == "" {
 		return []lsp.Location{}, nil
 	}
-	var definition string
 	res := s.parser.Parse(contents, params.TextDocument.URI.Filename())
-	tt := FindTemplateTreeForPosition(res.Template.Content, LspPosToParserPos(params.Position))
-	if tt == nil {
+	// For now, we can only really resolve the first segment of an expression
+	// since we don't have any type information.
+	definition := FindWordAtPosition(&res.Template, LspPosToParserPos(res.Lines(), params.Position))
+	if definition == "" {
 		s.log("Didn't find a match")
 		return []lsp.Location{}, nil
 	}
 
-	switch tt.Type {
-	case parser.TT2GoExp:
-		// Go Exp code is single line, find the segment where the
-		// lsp.Position.Character belongs.
-		// For now, we can only really resolve the first segment since we don't
-		// have any type information.
-		segments := splitOnSet(tt.Text, ".()")
-		if len(segments) > 0 && params.Position.Character <= uint32(tt.Column()+len(segments[0])) {
-			definition = segments[0]
-		}
-	}
-
-	paramNames := GetTemplateParamNames(&res.Template)
+	templateParams := res.Template.Params
 	locs := []lsp.Location{}
-	for _, param := range paramNames {
-		if definition == param {
-			startLine := res.Template.Params.Line()
-			// Adding one to the index to account for dropping the paren
-			startCol := strings.Index(res.Template.Params.Str, param) + 1
+	for _, param := range parser.ParseParamsAt(templateParams.Str, templateParams.Range().Start.Offset(), res.Lines()) {
+		if definition == param.Name {
+			start := param.Range.Start.Offset()
 			loc := lsp.Location{
 				URI: params.TextDocument.URI,
 				Range: lsp.Range{
-					Start: ParserPosToLspPos(PlainPosition{
-						line:   startLine,
-						column: startCol,
-					}),
-					End: ParserPosToLspPos(PlainPosition{
-						line:   startLine,
-						column: startCol + len(param),
-					}),
+					Start: ParserPosToLspPos(param.Range.Start),
+					End:   ParserPosToLspPos(res.Lines().Position(start + len(param.Name))),
 				},
 			}
 			locs = append(locs, loc)
@@ -588,7 +569,7 @@ func (s *GwirlLspServer) Hover(ctx context.Context, params *lsp.HoverParams) (re
 	}
 	p := parser.NewParser2("")
 	res := p.Parse(contents, params.TextDocument.URI.Filename())
-	word := FindWordAtPosition(&res.Template, params.Position)
+	word := FindWordAtPosition(&res.Template, LspPosToParserPos(res.Lines(), params.Position))
 	if word == "" {
 		s.log("Didn't find a word for hover")
 		return nil, nil
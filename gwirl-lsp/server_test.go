@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"go.lsp.dev/protocol"
+	"go.lsp.dev/uri"
+)
+
+func TestDefinitionOfParam(t *testing.T) {
+	s := NewGwirlLspServer(io.Discard, io.Discard, bufio.NewReader(strings.NewReader("")), context.Background())
+	file := uri.File("/templates/list.html.gwirl")
+	s.fileContents[file] = "@(items []string, item string)\n<p>@item</p>\n"
+	locs, err := s.Definition(context.Background(), &protocol.DefinitionParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: file},
+			Position:     protocol.Position{Line: 1, Character: 5},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locs) != 1 {
+		t.Fatalf("Expected a single definition, got %v", locs)
+	}
+	rng := locs[0].Range
+	if rng.Start.Line != 0 || rng.Start.Character != 18 || rng.End.Character != 22 {
+		t.Errorf("Expected the definition of item at 0:18-0:22, got %v", rng)
+	}
+}
@@ -5,6 +5,9 @@ import (
 	"path"
 	"sort"
 	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
 
 	"github.com/gamebox/gwirl/internal/parser"
 	lsp "go.lsp.dev/protocol"
@@ -22,19 +25,23 @@ type templateEntry struct {
 }
 
 func TemplateName(fileName string) string {
-	str := strings.TrimSuffix(fileName, ".html.gwirl")
-	name := strings.TrimSuffix(path.Base(str), ".html.gwirl")
-	return strings.Replace(name, string(name[0]), string(name[0]-32), 1)
+	return capitalize(strings.TrimSuffix(path.Base(fileName), ".html.gwirl"))
+}
+
+func capitalize(str string) string {
+	if str == "" {
+		return ""
+	}
+	first, size := utf8.DecodeRuneInString(str)
+	return string(unicode.ToUpper(first)) + str[size:]
 }
 
 func TemplateNames(fileContents map[uri.URI]string) []templateEntry {
 	entries := make([]templateEntry, 0, 100)
 	for filepath := range fileContents {
-		name := strings.TrimSuffix(path.Base(filepath.Filename()), ".html.gwirl")
-		name = strings.Replace(name, string(name[0]), string(name[0]-32), 1)
 		entries = append(entries, templateEntry{
 			path: filepath.Filename(),
-			name: name,
+			name: TemplateName(filepath.Filename()),
 		})
 	}
 
@@ -49,63 +56,72 @@ func (pos PlainPosition) Column() int {
 	return pos.column
 }
 
-func LspPosToParserPos(pos lsp.Position) parser.Position {
-	return PlainPosition{
-		line:   int(pos.Line + 1),
-		column: int(pos.Character),
-	}
+// Converts a position from a client, whose columns are in UTF-16 code units,
+// to a position in the source with the given lines.
+func LspPosToParserPos(lines *parser.LineIndex, pos lsp.Position) parser.Position {
+	return lines.PositionUTF16(int(pos.Line+1), int(pos.Character))
 }
 
+// Converts a position in a source to a position for a client, whose columns
+// are in UTF-16 code units.  Positions that only know their column in bytes
+// are assumed to be on a line of ASCII.
 func ParserPosToLspPos(pos parser.Position) lsp.Position {
+	column := pos.Column()
+	if pos, ok := pos.(parser.UTF16Position); ok {
+		column = pos.UTF16Column()
+	}
 	return lsp.Position{
 		Line:      uint32(pos.Line() - 1),
-		Character: uint32(pos.Column()),
+		Character: uint32(column),
 	}
 }
 
+// Returns the length of a string in UTF-16 code units, which is how clients
+// measure the lengths of tokens.
+func utf16Len(s string) int {
+	return len(utf16.Encode([]rune(s)))
+}
+
 func GetTemplateParamNames(t *parser.Template2) []string {
-	tParamsStr := strings.TrimLeft(t.Params.Str, "(")
-	tParamsStr = strings.TrimRight(tParamsStr, ")")
-	tParamsFull := strings.Split(tParamsStr, ",")
 	tParamNames := []string{}
-	for _, p := range tParamsFull {
-		tParamNames = append(tParamNames, strings.Split(p, " ")[0])
+	for _, p := range parser.ParseParams(t.Params.Str) {
+		tParamNames = append(tParamNames, p.Name)
 	}
 	return tParamNames
 }
 
+// Returns the innermost tree whose source contains the position, or nil if
+// none of the trees do.
 func FindTemplateTreeForPosition(tts []parser.TemplateTree2, pos parser.Position) *parser.TemplateTree2 {
-	var result *parser.TemplateTree2 = nil
-	var previous *parser.TemplateTree2 = nil
 	for idx := range tts {
-		tt := tts[idx]
-		nodeLineIsBeforePosLine := tt.Line() < pos.Line()
-		nodeLineIsAfterPosLine := tt.Line() > pos.Line()
-		nodeLineIsEqualToPosLine := tt.Line() == pos.Line()
-		nodeColumnIsBeforePosColumn := tt.Column() < pos.Column()
-		nodeColumnIsAfterOrEqualToPosColumn := tt.Column() >= pos.Column()
-		if nodeLineIsAfterPosLine || (nodeLineIsEqualToPosLine && nodeColumnIsAfterOrEqualToPosColumn) {
-			result = previous
-			break
+		tt := &tts[idx]
+		if !tt.Range().Contains(pos) {
+			continue
 		}
-		if nodeLineIsBeforePosLine || (nodeLineIsEqualToPosLine && nodeColumnIsBeforePosColumn) {
-			switch tt.Type {
-			case parser.TT2If, parser.TT2Else, parser.TT2ElseIf, parser.TT2GoExp, parser.TT2For:
-				if tt.Children == nil || len(tt.Children) == 0 {
-				} else {
-					for _, childTrees := range tt.Children {
-						res := FindTemplateTreeForPosition(childTrees, pos)
-						if res != nil {
-							return res
-						}
-					}
-				}
-			default:
+		for _, childTrees := range tt.Children {
+			res := FindTemplateTreeForPosition(childTrees, pos)
+			if res != nil {
+				return res
 			}
-			previous = &tt
 		}
+		return tt
 	}
-	return result
+	return nil
+}
+
+// Returns the innermost tree of the template, or of the templates defined in
+// it, whose source contains the position.
+func FindTemplateTreeInTemplate(template *parser.Template2, pos parser.Position) *parser.TemplateTree2 {
+	if t := FindTemplateTreeForPosition(template.Content, pos); t != nil {
+		return t
+	}
+	for i := range template.Templates {
+		sub := &template.Templates[i]
+		if sub.Range().Contains(pos) {
+			return FindTemplateTreeForPosition(sub.Content, pos)
+		}
+	}
+	return nil
 }
 
 type absToken struct {
@@ -152,6 +168,7 @@ var SemanticTokenLegend = []lsp.SemanticTokenTypes{
 	lsp.SemanticTokenComment,
 	lsp.SemanticTokenVariable,
 	lsp.SemanticTokenOperator,
+	lsp.SemanticTokenTypeParameter,
 }
 
 func subUint32(x uint32, y uint32) uint32 {
@@ -249,9 +266,11 @@ func absTokensForContent(tt []parser.TemplateTree2) []absToken {
 			blockTokens := absTokensForChildren(t.Children)
 			tokens = append(tokens, blockTokens...)
 		case parser.TT2GoExp:
-			length := len(t.Text)
+			length := utf16Len(t.Text)
 			var atToken absToken
-            if t.Metadata.Has(parser.TTMDSafe) && !t.Metadata.Has(parser.TTMDEscape) {
+			if t.Metadata.Has(parser.TTMDRaw) {
+				atToken = NewAbsToken(startLine, subUint32(startCol, 5), 5, lsp.SemanticTokenOperator)
+			} else if t.Metadata.Has(parser.TTMDSafe) && !t.Metadata.Has(parser.TTMDEscape) {
                 atToken = NewAbsToken(startLine, startCol-2, 2, lsp.SemanticTokenOperator)
             } else if t.Metadata.Has(parser.TTMDEscape) && t.Metadata.Has(parser.TTMDSafe) {
                 atToken = NewAbsToken(startLine, startCol-3, 3, lsp.SemanticTokenOperator)
@@ -262,7 +281,7 @@ func absTokensForContent(tt []parser.TemplateTree2) []absToken {
 			}
 			token := NewAbsToken(startLine, startCol, length, lsp.SemanticTokenParameter)
 			tokens = append(tokens, atToken, token)
-            if t.Metadata.Has(parser.TTMDSafe) {
+            if t.Metadata.Has(parser.TTMDSafe) || t.Metadata.Has(parser.TTMDRaw) {
                 tokens = append(tokens, NewAbsToken(startLine, startCol + uint32(length), 1, lsp.SemanticTokenOperator))
             }
 			if t.Children == nil {
@@ -270,6 +289,31 @@ func absTokensForContent(tt []parser.TemplateTree2) []absToken {
 			}
 			blockTokens := absTokensForChildren(t.Children)
 			tokens = append(tokens, blockTokens...)
+		case parser.TT2Switch, parser.TT2Case, parser.TT2Default, parser.TT2Slot:
+			length := 6
+			if t.Type == parser.TT2Case || t.Type == parser.TT2Slot {
+				length = 4
+			} else if t.Type == parser.TT2Default {
+				length = 7
+			}
+			atToken := NewAbsToken(startLine, startCol-1, 1, lsp.SemanticTokenOperator)
+			token := NewAbsToken(startLine, startCol, length, lsp.SemanticTokenKeyword)
+			tokens = append(tokens, atToken, token)
+			if t.Children == nil {
+				continue
+			}
+			blockTokens := absTokensForChildren(t.Children)
+			tokens = append(tokens, blockTokens...)
+		case parser.TT2TemplateDef:
+			name, _, _ := t.Declaration()
+			atToken := NewAbsToken(startLine, startCol-1, 1, lsp.SemanticTokenOperator)
+			token := NewAbsToken(startLine, startCol, utf16Len(name), lsp.SemanticTokenVariable)
+			tokens = append(tokens, atToken, token)
+			if t.Children == nil {
+				continue
+			}
+			blockTokens := absTokensForChildren(t.Children)
+			tokens = append(tokens, blockTokens...)
 		case parser.TT2ElseIf:
 			length := 7
 			atToken := NewAbsToken(startLine, startCol-1, 1, lsp.SemanticTokenOperator)
@@ -286,11 +330,11 @@ func absTokensForContent(tt []parser.TemplateTree2) []absToken {
 			for i, l := range lines {
 				var length int
 				if i == 0 || i == (len(lines)-1) {
-					length = len(l) + 3
+					length = utf16Len(l) + 3
 				} else if len(lines) == 1 {
-					length = len(l) + 6
+					length = utf16Len(l) + 6
 				} else {
-					length = len(l)
+					length = utf16Len(l)
 				}
 				if i > 0 {
 					startCol = 0
@@ -305,42 +349,65 @@ func absTokensForContent(tt []parser.TemplateTree2) []absToken {
 	return tokens
 }
 
+func addTypeParamsTokens(t *parser.Template2, absTokens []absToken) []absToken {
+	ps := strings.Split(strings.Trim(t.TypeParams.Str, "[]"), ", ")
+	lsppos := ParserPosToLspPos(&t.TypeParams)
+	column := lsppos.Character + 1
+	for _, p := range ps {
+		name, constraint, found := strings.Cut(p, " ")
+		absTokens = append(absTokens, NewAbsToken(lsppos.Line, column, utf16Len(name), lsp.SemanticTokenTypeParameter))
+		if found {
+			absTokens = append(absTokens, NewAbsToken(lsppos.Line, column+uint32(utf16Len(name))+1, utf16Len(constraint), lsp.SemanticTokenType))
+		}
+		column += uint32(utf16Len(p)) + 2
+	}
+	return absTokens
+}
+
 func AddParamsTokens(t *parser.Template2, absTokens []absToken) []absToken {
 	ps := strings.Split(strings.Trim(t.Params.Str, "()"), ", ")
 	lsppos := ParserPosToLspPos(&t.Params)
 	paramsLine := lsppos.Line
 	paramsColumn := lsppos.Character + 1
 	atToken := NewAbsToken(paramsLine, subUint32(lsppos.Character, 1), 1, lsp.SemanticTokenOperator)
-	absTokens = append(absTokens, atToken)
+	if t.TypeParams.Str != "" {
+		atToken.startCharacter = subUint32(ParserPosToLspPos(&t.TypeParams).Character, 1)
+		absTokens = append(absTokens, atToken)
+		absTokens = addTypeParamsTokens(t, absTokens)
+	} else {
+		absTokens = append(absTokens, atToken)
+	}
 	for _, p := range ps {
 		parts := strings.SplitN(p, " ", 2)
 		if len(parts) != 2 {
 			continue
 		}
-		nameT := NewAbsToken(paramsLine, paramsColumn, len(parts[0]), lsp.SemanticTokenParameter)
-		typeT := NewAbsToken(paramsLine, paramsColumn+uint32(len(parts[0]))+1, len(parts[1]), lsp.SemanticTokenType)
+		nameT := NewAbsToken(paramsLine, paramsColumn, utf16Len(parts[0]), lsp.SemanticTokenParameter)
+		typeT := NewAbsToken(paramsLine, paramsColumn+uint32(utf16Len(parts[0]))+1, utf16Len(parts[1]), lsp.SemanticTokenType)
 		absTokens = append(absTokens, nameT, typeT)
-		paramsColumn += uint32(len(p)) + 2
+		paramsColumn += uint32(utf16Len(p)) + 2
 	}
 	return absTokens
 }
 
 func AddImportsTokens(t *parser.Template2, absTokens []absToken) []absToken {
-	for _, imp := range t.TopImports {
-		lsppos := ParserPosToLspPos(&imp)
-		startLine := lsppos.Line
-		startCol := lsppos.Character
-		length := 6
-		atToken := NewAbsToken(startLine, subUint32(startCol, 1), 1, lsp.SemanticTokenOperator)
-		impT := NewAbsToken(startLine, startCol, length, lsp.SemanticTokenKeyword)
-		startCol += 7
-		str, _ := strings.CutPrefix(imp.Str, "import ")
-		if strings.HasPrefix(str, "\"") {
-			pkgT := NewAbsToken(startLine, startCol, len(str), lsp.SemanticTokenString)
-			absTokens = append(absTokens, atToken, impT, pkgT)
-		} else {
+	var keyword *parser.PosString
+	for i := range t.TopImports {
+		imp := &t.TopImports[i]
+		// The imports of a block share the same keyword.
+		if keyword == nil || keyword.Line() != imp.Keyword.Line() || keyword.Column() != imp.Keyword.Column() {
+			keyword = &imp.Keyword
+			lsppos := ParserPosToLspPos(keyword)
+			atToken := NewAbsToken(lsppos.Line, subUint32(lsppos.Character, 1), 1, lsp.SemanticTokenOperator)
+			impT := NewAbsToken(lsppos.Line, lsppos.Character, len(keyword.Str), lsp.SemanticTokenKeyword)
 			absTokens = append(absTokens, atToken, impT)
 		}
+		if imp.Name.Str != "" {
+			lsppos := ParserPosToLspPos(&imp.Name)
+			absTokens = append(absTokens, NewAbsToken(lsppos.Line, lsppos.Character, utf16Len(imp.Name.Str), lsp.SemanticTokenVariable))
+		}
+		lsppos := ParserPosToLspPos(&imp.Path)
+		absTokens = append(absTokens, NewAbsToken(lsppos.Line, lsppos.Character, utf16Len(imp.Path.Str), lsp.SemanticTokenString))
 	}
 	return absTokens
 }
@@ -361,6 +428,15 @@ func CreateTemplateSemanticTokens(t *parser.Template2) *lsp.SemanticTokens {
 	absTokens = AddImportsTokens(t, absTokens)
 	tokens := absTokensForContent(t.Content)
 	absTokens = append(absTokens, tokens...)
+	for i := range t.Templates {
+		sub := &t.Templates[i]
+		lsppos := ParserPosToLspPos(&sub.Name)
+		atToken := NewAbsToken(lsppos.Line, subUint32(lsppos.Character, 1), 1, lsp.SemanticTokenOperator)
+		nameToken := NewAbsToken(lsppos.Line, lsppos.Character, utf16Len(sub.Name.Str), lsp.SemanticTokenVariable)
+		absTokens = append(absTokens, atToken, nameToken)
+		absTokens = AddCommentsTokens(sub, absTokens)
+		absTokens = append(absTokens, absTokensForContent(sub.Content)...)
+	}
 
 	return &lsp.SemanticTokens{
 		Data: semanticTokensDataFromAbsTokens(absTokens),
@@ -389,9 +465,8 @@ func splitOnSet(s string, splitSet string) []string {
 	return res
 }
 
-func FindWordAtPosition(template *parser.Template2, position lsp.Position) string {
-	pos := LspPosToParserPos(position)
-	t := FindTemplateTreeForPosition(template.Content, pos)
+func FindWordAtPosition(template *parser.Template2, pos parser.Position) string {
+	t := FindTemplateTreeInTemplate(template, pos)
 	if t == nil {
 		return ""
 	}
@@ -403,7 +478,8 @@ func FindWordAtPosition(template *parser.Template2, position lsp.Position) strin
 			break
 		}
 		word := words[0]
-		if pos.Line() != t.Line() && pos.Column() > (t.Column()+len(word)) {
+		start := t.TextRange().Start
+		if pos.Line() != start.Line() || pos.Column() < start.Column() || pos.Column() > start.Column()+len(word) {
 			break
 		}
 		return word
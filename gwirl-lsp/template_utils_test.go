@@ -0,0 +1,99 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gamebox/gwirl/internal/parser"
+	"go.lsp.dev/protocol"
+)
+
+func TestFindWordAtPosition(t *testing.T) {
+	p := parser.NewParser2("")
+	res := p.Parse("@(user User)\n<p>@if user.Admin {<b>@user.Name</b>}</p>\n@Card(title string) = {\n\t<h2>@title</h2>\n}\n", "Test")
+	if len(res.Errors) > 0 {
+		t.Fatalf("Template failed to parse: %v", res.Errors)
+	}
+	tests := []struct {
+		line      uint32
+		character uint32
+		expected  string
+	}{
+		{1, 23, "user"},
+		{1, 26, "user"},
+		{1, 27, "user"},
+		{1, 29, ""},
+		{1, 8, ""},
+		{1, 0, ""},
+		{3, 7, "title"},
+		{3, 14, ""},
+	}
+	for _, test := range tests {
+		pos := LspPosToParserPos(res.Lines(), protocol.Position{Line: test.line, Character: test.character})
+		word := FindWordAtPosition(&res.Template, pos)
+		if word != test.expected {
+			t.Errorf("Expected %q at %d:%d, got %q", test.expected, test.line, test.character, word)
+		}
+	}
+}
+
+func TestFindTemplateTreeForPosition(t *testing.T) {
+	p := parser.NewParser2("")
+	res := p.Parse("@(items []string)\n@for _, item := range items {\n\t<li>@item</li>\n}\n", "Test")
+	if len(res.Errors) > 0 {
+		t.Fatalf("Template failed to parse: %v", res.Errors)
+	}
+	tt := FindTemplateTreeForPosition(res.Template.Content, PlainPosition{line: 3, column: 6})
+	if tt == nil || tt.Type != parser.TT2GoExp || tt.Text != "item" {
+		t.Fatalf("Expected the item expression, got %v", tt)
+	}
+	tt = FindTemplateTreeForPosition(res.Template.Content, PlainPosition{line: 2, column: 10})
+	if tt == nil || tt.Type != parser.TT2For {
+		t.Fatalf("Expected the for, got %v", tt)
+	}
+	tt = FindTemplateTreeForPosition(res.Template.Content, PlainPosition{line: 9, column: 0})
+	if tt != nil {
+		t.Fatalf("Expected nothing past the end of the template, got %v", tt)
+	}
+}
+
+func TestFindWordAtPositionUTF16(t *testing.T) {
+	p := parser.NewParser2("")
+	// The accents take two bytes and one UTF-16 code unit, and the emoji four
+	// bytes and two code units.
+	res := p.Parse("@(préférence string)\n<p>été 🎉 @préférence</p>\n", "Test")
+	if len(res.Errors) > 0 {
+		t.Fatalf("Template failed to parse: %v", res.Errors)
+	}
+	tests := []struct {
+		character uint32
+		expected  string
+	}{
+		{10, ""},
+		{11, "préférence"},
+		{16, "préférence"},
+		{20, "préférence"},
+		{21, ""},
+	}
+	for _, test := range tests {
+		pos := LspPosToParserPos(res.Lines(), protocol.Position{Line: 1, Character: test.character})
+		if word := FindWordAtPosition(&res.Template, pos); word != test.expected {
+			t.Errorf("Expected %q at 1:%d, got %q", test.expected, test.character, word)
+		}
+	}
+	expression := res.Template.Content[1]
+	if lsppos := ParserPosToLspPos(&expression); lsppos.Line != 1 || lsppos.Character != 11 {
+		t.Errorf("Expected the expression to start at 1:11, got %d:%d", lsppos.Line, lsppos.Character)
+	}
+}
+
+func TestTemplateName(t *testing.T) {
+	tests := map[string]string{
+		"/templates/index.html.gwirl":   "Index",
+		"/templates/élément.html.gwirl": "Élément",
+	}
+	for fileName, expected := range tests {
+		if name := TemplateName(fileName); name != expected {
+			t.Errorf("Expected the name of %s to be %s, got %s", fileName, expected, name)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package gwirl
 import (
 	"fmt"
 	html "html/template"
+	"io"
 	"strings"
 )
 
@@ -10,11 +11,115 @@ type TemplateBuilder struct {
 	strings.Builder
 }
 
-// Writes the
-func WriteEscapedHTML(builder *TemplateBuilder, value interface{}) {
+// Returns the content that has been written so far as HTML.
+func (b *TemplateBuilder) HTML() HTML {
+	return HTML(b.String())
+}
+
+// TemplateWriter streams template output directly to an io.Writer.  The first
+// error returned by the underlying writer is kept and every write after it is
+// skipped, so generated code only needs to check Err once at the end.  It is an
+// io.Writer itself, so the templates it calls can stream to it as well.
+type TemplateWriter struct {
+	Writer io.Writer
+	err    error
+}
+
+func (w *TemplateWriter) WriteString(s string) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n, err := io.WriteString(w.Writer, s)
+	w.err = err
+	return n, err
+}
+
+func (w *TemplateWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n, err := w.Writer.Write(p)
+	w.err = err
+	return n, err
+}
+
+// Returns the first error encountered while writing, if any.
+func (w *TemplateWriter) Err() error {
+	return w.err
+}
+
+// Content is HTML that writes itself to a writer.  The streaming variants of
+// templates take Content in place of their gwirl.HTML parameters, so that the
+// transclusions passed to them are only rendered when they are written, and go
+// straight to the writer.  HTML is Content, so the output of a template can be
+// passed to them as well.
+type Content interface {
+	WriteContent(w io.Writer) error
+}
+
+// Writes the HTML as is.
+func (h HTML) WriteContent(w io.Writer) error {
+	_, err := io.WriteString(w, string(h))
+	return err
+}
+
+// ContentFunc is Content rendered by a function each time it is written, which
+// is how the streaming variants of templates pass transclusions on.
+type ContentFunc func(w io.Writer) error
+
+func (f ContentFunc) WriteContent(w io.Writer) error {
+	return f(w)
+}
+
+// Returns the rendered content, so that it prints as its HTML.
+func (f ContentFunc) String() string {
+	return string(Render(f))
+}
+
+// Renders content to HTML, for when it is needed as a value rather than
+// written.
+func Render(c Content) HTML {
+	if c == nil {
+		return ""
+	}
+	if h, ok := c.(HTML); ok {
+		return h
+	}
+	b := TemplateBuilder{}
+	c.WriteContent(&b)
+	return b.HTML()
+}
+
+// Writes content as is, straight to the builder when it is a writer.  Errors
+// don't need to be returned, since a TemplateWriter keeps the first one.
+func writeContent(builder io.StringWriter, c Content) {
+	if h, ok := c.(HTML); ok {
+		builder.WriteString(string(h))
+		return
+	}
+	if w, ok := builder.(io.Writer); ok {
+		c.WriteContent(w)
+		return
+	}
+	builder.WriteString(string(Render(c)))
+}
+
+// Writes the value escaped for HTML text.  Values of type HTML are trusted and
+// written as is, so the output of one template can be used in another without
+// being escaped twice, and so is other Content.  In attribute values, where
+// markup isn't trusted, values are written with WriteEscapedAttr instead.
+func WriteEscapedHTML(builder io.StringWriter, value interface{}) {
+	if c, ok := value.(Content); ok {
+		writeContent(builder, c)
+		return
+	}
 	builder.WriteString(html.HTMLEscapeString(fmt.Sprintf("%v", value)))
 }
 
-func WriteRawHTML(builder *TemplateBuilder, value interface{}) {
+func WriteRawHTML(builder io.StringWriter, value interface{}) {
+	if c, ok := value.(Content); ok {
+		writeContent(builder, c)
+		return
+	}
 	builder.WriteString(fmt.Sprintf("%v", value))
 }
@@ -1,11 +1,16 @@
 package main
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
+	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
 	"unicode"
 
 	"github.com/gamebox/gwirl/internal/gen"
@@ -13,9 +18,18 @@ import (
 )
 
 type Builder struct {
-	flags     *Flags
-	accessor  FSAccessor
-	logger    io.Writer
+	flags    *Flags
+	accessor FSAccessor
+	logger   io.Writer
+	version  string
+	// Guards the manifest and the logger, which are shared by all workers.
+	mu       sync.Mutex
+	manifest *manifest
+}
+
+// A worker holds the parser and generator used to build templates on a single
+// goroutine, since neither can be shared.
+type worker struct {
 	parser    *parser.Parser2
 	generator *gen.Generator
 }
@@ -26,15 +40,21 @@ func NewBuilder(flags *Flags, accessor FSAccessor, logger io.Writer) *Builder {
 		accessor: accessor,
 		logger:   logger,
 	}
+	b.version = executableVersion()
+	b.manifest = newManifest(b.version, flags.generationKey())
+
+	return &b
+}
+
+func (b *Builder) newWorker() *worker {
 	p := parser.NewParser2("")
-	if logger != nil {
-		p.SetLogger(logger)
+	if b.logger != nil {
+		p.SetLogger(b.logger)
 	}
 	g := gen.NewGenerator(false)
-	b.parser = &p
-	b.generator = &g
-
-	return &b
+	g.SetStreaming(b.flags.stream)
+	g.SetEscapeByDefault(b.flags.escape)
+	return &worker{parser: &p, generator: &g}
 }
 
 func capitalize(str string) string {
@@ -46,63 +66,355 @@ func capitalize(str string) string {
 	return string(runes)
 }
 
+// Returns the path of the template relative to the directory its generated
+// file is written to, which is how the Go compiler resolves line directives.
+func sourcePath(f *File) string {
+	if f.path == "" {
+		return ""
+	}
+	path, err := filepath.Rel(filepath.Join("views", f.filetype), f.path)
+	if err != nil {
+		return ""
+	}
+	return filepath.ToSlash(path)
+}
+
 func (b *Builder) Printf(format string, vals ...any) {
 	if b.logger != nil {
+		b.mu.Lock()
+		defer b.mu.Unlock()
 		b.logger.Write([]byte(fmt.Sprintf(format, vals...)))
 	}
 }
 
-func (b *Builder) parse(f *File) (*parser.ParseResult2, error) {
+func (b *Builder) parse(w *worker, f *File) (*parser.ParseResult2, error) {
 	b.Printf("Parsing %s\n", f.name)
 
-	result := b.parser.Parse(f.content, capitalize(f.name))
+	result := w.parser.Parse(f.content, capitalize(f.name))
 	if len(result.Errors) > 0 {
-		err := strings.Builder{}
-		err.WriteString(fmt.Sprintf("Could not parse file %s:\n", f.name+f.filetype+".gwirl"))
-		for _, e := range result.Errors {
-			err.WriteString(fmt.Sprintf("%v\n", e))
-		}
-		return nil, errors.New(err.String())
+		return nil, parseDiagnostics(f, result.Errors)
 	}
 	return &result, nil
 }
 
-func (b *Builder) generate(result *parser.ParseResult2, f *File) error {
+func (b *Builder) generate(w *worker, result *parser.ParseResult2, f *File) error {
 	b.Printf("Generating %s\n", f.name)
 
-	fileWriter, fileName, err := b.accessor.CreateGwirlFile(f)
+	w.generator.SetFiletype(f.filetype)
+	w.generator.SetSourcePath(sourcePath(f), filepath.Base(gwirlFilePath(f)))
+	output := bytes.Buffer{}
+	err := w.generator.Generate(result.Template, f.filetype, &output)
 	if err != nil {
-		e := errors.New(fmt.Sprintf("Failed to open go file for template: %s\nERROR: %v", f.name, err))
+		e := errors.New(fmt.Sprintf("Could not generate a file for template: %s", f.name))
 		return errors.Join(e, err)
 	}
 
-	err = b.generator.Generate(result.Template, f.filetype, fileWriter)
+	// Leave files that would not change untouched, so that their modification
+	// times don't cause the packages using them to be rebuilt.
+	existing, err := b.accessor.ReadFile(gwirlFilePath(f))
+	if err == nil && bytes.Equal(existing, output.Bytes()) {
+		return nil
+	}
+
+	fileWriter, _, err := b.accessor.CreateGwirlFile(f)
 	if err != nil {
-		b.accessor.Remove(fileName)
-		e := errors.New(fmt.Sprintf("Could not generate a file for template: %s", f.name))
+		e := errors.New(fmt.Sprintf("Failed to open go file for template: %s\nERROR: %v", f.name, err))
 		return errors.Join(e, err)
 	}
+	if closer, ok := fileWriter.(io.Closer); ok {
+		defer closer.Close()
+	}
+	_, err = fileWriter.Write(output.Bytes())
+	return err
+}
 
-	return nil
+// The parameters of every template in a package, which are needed to bind named
+// slots to the parameters of the templates they are passed to.
+type signatures struct {
+	params map[string]string
+	// The templates of the files that failed to parse, whose parameters aren't
+	// known.
+	unparsed map[string]bool
+	// The names declared by the Go files of the package that aren't generated,
+	// which the code of templates can use.
+	declarations map[string]bool
+	// Changes whenever a name is declared or removed from those files.
+	declarationsFingerprint string
 }
 
-func (b *Builder) build() error {
-	if b.flags.clean {
-		clean()
+// Returns the parameters of the templates defined in a file, keyed by their
+// names.
+func templateSignatures(result *parser.ParseResult2) map[string]string {
+	params := map[string]string{result.Template.Name.Str: result.Template.Params.Str}
+	for _, t := range result.Template.Templates {
+		params[t.Name.Str] = t.Params.Str
 	}
-	fs := b.accessor.TemplateFiles("templates", b.flags.filter.filters)
-	for _, f := range fs {
-		b.accessor.EnsureDirectoryExists(filepath.Join("views", f.filetype))
-		result, err := b.parse(&f)
-		if err != nil {
-			return err
+	return params
+}
+
+// Collects the signatures of the templates, keyed by their filetype since each
+// filetype is generated into a package of its own.  The signatures of the
+// templates that were not parsed again come from their manifest entries.
+func collectSignatures(fs []File, results []*parser.ParseResult2, entries []*manifestEntry) map[string]*signatures {
+	sigs := map[string]*signatures{}
+	for i := range fs {
+		ft := fs[i].filetype
+		if sigs[ft] == nil {
+			sigs[ft] = &signatures{params: map[string]string{}, unparsed: map[string]bool{}}
+		}
+		var params map[string]string
+		if results[i] != nil {
+			params = templateSignatures(results[i])
+		} else if entries[i] != nil {
+			params = entries[i].Signatures
+		} else {
+			sigs[ft].unparsed[capitalize(fs[i].name)] = true
+			continue
+		}
+		for name, ps := range params {
+			sigs[ft].params[name] = ps
+		}
+	}
+	return sigs
+}
+
+// Adds the names declared by the Go files of each package to its signatures.
+func (b *Builder) collectDeclarations(fs []File, sigs map[string]*signatures) {
+	for i := range fs {
+		ft := fs[i].filetype
+		if sigs[ft] == nil {
+			sigs[ft] = &signatures{params: map[string]string{}}
+		}
+		if sigs[ft].declarations != nil {
+			continue
+		}
+		declarations := b.accessor.PackageDeclarations(filepath.Join("views", ft))
+		names := make([]string, 0, len(declarations))
+		for name := range declarations {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		sigs[ft].declarations = declarations
+		sigs[ft].declarationsFingerprint = hashContent(strings.Join(names, "\n"))
+	}
+}
+
+// Returns the current parameters of the called templates, with nil for the
+// names that aren't templates.
+func (sigs *signatures) calls(names []string) map[string]*string {
+	calls := make(map[string]*string, len(names))
+	for _, name := range names {
+		if params, ok := sigs.params[name]; ok {
+			calls[name] = &params
+		} else {
+			calls[name] = nil
+		}
+	}
+	return calls
+}
+
+// Reports whether the called templates still have the same parameters.
+func (sigs *signatures) unchanged(calls map[string]*string) bool {
+	for name, prev := range calls {
+		params, ok := sigs.params[name]
+		if ok != (prev != nil) || ok && params != *prev {
+			return false
+		}
+	}
+	return true
+}
+
+// The packages of the module, which templates can use without importing them.
+type localPackages struct {
+	names       map[string]string
+	fingerprint string
+}
+
+// Generates the file of a template, unless its content, the templates it calls,
+// the packages of the module, and the names declared by the Go files of its
+// package are the same as when it was last generated.
+// The template is parsed here when it was not parsed to collect the signatures.
+func (b *Builder) buildFile(w *worker, f *File, result *parser.ParseResult2, entry *manifestEntry, sigs *signatures, pkgs *localPackages) error {
+	if sigs == nil {
+		sigs = &signatures{}
+	}
+	b.mu.Lock()
+	b.manifest.forget(f.path)
+	b.mu.Unlock()
+	if entry != nil && entry.Packages == pkgs.fingerprint && entry.Declarations == sigs.declarationsFingerprint && sigs.unchanged(entry.Calls) {
+		if _, err := b.accessor.ReadFile(gwirlFilePath(f)); err == nil {
+			b.Printf("Skipping unchanged %s\n", f.name)
+			b.mu.Lock()
+			b.manifest.record(f.path, entry)
+			b.mu.Unlock()
+			return nil
 		}
-		err = b.generate(result, &f)
+	}
+	if result == nil {
+		var err error
+		result, err = b.parse(w, f)
 		if err != nil {
 			return err
 		}
 	}
+	b.accessor.EnsureDirectoryExists(filepath.Join("views", f.filetype))
+	w.generator.SetSignatures(sigs.params)
+	w.generator.SetUnparsed(sigs.unparsed)
+	w.generator.SetPackages(pkgs.names)
+	w.generator.SetDeclarations(sigs.declarations)
+	err := b.generate(w, result, f)
+	if errors.Is(err, gen.ErrUnparsedCallee) {
+		// The errors of the called template are reported instead, and the
+		// template is generated again once they are fixed, since it isn't
+		// recorded in the manifest.
+		b.Printf("Skipping %s, which calls a template that failed to parse\n", f.name)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	b.manifest.record(f.path, &manifestEntry{
+		Content:      hashContent(f.content),
+		Signatures:   templateSignatures(result),
+		Calls:        sigs.calls(w.generator.Calls()),
+		Packages:     pkgs.fingerprint,
+		Declarations: sigs.declarationsFingerprint,
+	})
+	b.mu.Unlock()
+	return nil
+}
+
+// Removes the generated file for a template that no longer exists.
+func (b *Builder) removeFile(f *File) error {
+	b.Printf("Removing generated file for %s\n", f.name)
+	b.mu.Lock()
+	b.manifest.forget(f.path)
+	b.mu.Unlock()
+	return b.accessor.Remove(gwirlFilePath(f))
+}
+
+// Removes the generated files and the manifest entries of the templates that
+// were deleted since the last build, which are the ones in the manifest that
+// aren't among the template files anymore.
+func (b *Builder) removeDeleted(files []File) {
+	exists := make(map[string]bool, len(files))
+	for i := range files {
+		exists[files[i].path] = true
+	}
+	deleted := []string{}
+	b.mu.Lock()
+	for path := range b.manifest.Templates {
+		if !exists[path] {
+			deleted = append(deleted, path)
+		}
+	}
+	b.mu.Unlock()
+	sort.Strings(deleted)
+	for _, path := range deleted {
+		f := newFile(path)
+		if err := b.removeFile(&f); err != nil && !errors.Is(err, os.ErrNotExist) {
+			b.Printf("Could not remove the generated file for %s: %v\n", path, err)
+		}
+	}
+}
 
-	b.Printf("Completed generating %d templates", len(fs))
+func (b *Builder) saveManifest() {
+	b.mu.Lock()
+	err := b.manifest.save(b.accessor)
+	b.mu.Unlock()
+	if err != nil {
+		b.Printf("Could not save the manifest: %v\n", err)
+	}
+}
+
+// Runs fn for every index up to n on a pool of workers sized to the number of
+// goroutines that can run at once.
+func (b *Builder) parallel(n int, fn func(w *worker, i int)) {
+	jobs := make(chan int)
+	workers := runtime.GOMAXPROCS(0)
+	if workers > n {
+		workers = n
+	}
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := b.newWorker()
+			for i := range jobs {
+				fn(w, i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+func (b *Builder) build() error {
+	if b.flags.clean {
+		clean(b.flags.Output())
+		b.manifest = newManifest(b.version, b.flags.generationKey())
+	} else {
+		b.manifest = loadManifest(b.accessor, b.version, b.flags.generationKey())
+	}
+	defer b.saveManifest()
+	return b.buildAll()
+}
+
+// Builds every template that matches the filters.  The signatures of every
+// template are needed to generate the templates that call them, so all of the
+// templates that changed since the last build are parsed, even the ones that
+// don't match.  The others are only parsed when they have to be generated again.
+func (b *Builder) buildAll() error {
+	fs := b.accessor.TemplateFiles("templates", nil)
+	selected := make([]bool, len(fs))
+	count := 0
+	for i := range fs {
+		selected[i] = matchesFilter(filepath.Base(fs[i].path), b.flags.filter.filters)
+		if selected[i] {
+			count++
+		}
+	}
+
+	// Every template is built, even when some fail, so that all of the errors
+	// can be reported at once.  They are kept in the same order as the files.
+	results := make([]*parser.ParseResult2, len(fs))
+	entries := make([]*manifestEntry, len(fs))
+	errs := make([]error, len(fs))
+	b.parallel(len(fs), func(w *worker, i int) {
+		b.mu.Lock()
+		entries[i] = b.manifest.entry(fs[i].path, hashContent(fs[i].content))
+		b.mu.Unlock()
+		if entries[i] == nil {
+			results[i], errs[i] = b.parse(w, &fs[i])
+		}
+	})
+	sigs := collectSignatures(fs, results, entries)
+	b.collectDeclarations(fs, sigs)
+	names := b.accessor.ModulePackages()
+	pkgs := &localPackages{names: names, fingerprint: packagesFingerprint(names)}
+	b.parallel(len(fs), func(w *worker, i int) {
+		if errs[i] != nil || !selected[i] {
+			return
+		}
+		errs[i] = b.buildFile(w, &fs[i], results[i], entries[i], sigs[fs[i].filetype], pkgs)
+	})
+
+	b.removeDeleted(fs)
+
+	b.Printf("Completed generating %d templates", count)
+	var ds Diagnostics
+	for i, err := range errs {
+		if err != nil && selected[i] {
+			ds = append(ds, fileDiagnostics(&fs[i], err)...)
+		}
+	}
+	if len(ds) > 0 {
+		return ds
+	}
 	return nil
 }
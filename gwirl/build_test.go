@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
@@ -56,7 +57,7 @@ func TestBuildSuccess(t *testing.T) {
 			t.Log(showWhitespace(expectedContents))
 			t.Log("----- Received -----")
 			t.Log(showWhitespace(string(contents)))
-			// t.FailNow()
+			t.Fail()
 		}
 	}
 }
@@ -67,3 +68,47 @@ func showWhitespace(str string) string {
 	str = strings.ReplaceAll(str, "\n", "⏎\n")
 	return str
 }
+
+func TestBuildReportsAllErrors(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "templates"), 0755)
+	os.WriteFile(filepath.Join(root, "templates", "first.html.gwirl"), []byte("@(name string)\n@if name {\n"), 0644)
+	os.WriteFile(filepath.Join(root, "templates", "good.html.gwirl"), []byte("@(name string)\n<h1>@name</h1>\n"), 0644)
+	os.WriteFile(filepath.Join(root, "templates", "second.html.gwirl"), []byte("@(name string)\n@for name {\n"), 0644)
+
+	err := NewBuilder(&Flags{}, NewRealFSAccessor(root), nil).build()
+	if err == nil {
+		t.Fatalf("Expected the build to fail")
+	}
+	var ds Diagnostics
+	if !errors.As(err, &ds) {
+		t.Fatalf("Expected diagnostics, got %v", err)
+	}
+	if len(ds) != 2 {
+		t.Fatalf("Expected 2 diagnostics, got %d:\n%v", len(ds), ds)
+	}
+	for i, name := range []string{"templates/first.html.gwirl", "templates/second.html.gwirl"} {
+		if ds[i].File != name || ds[i].Line != 2 {
+			t.Errorf("Expected a diagnostic on line 2 of %s, got %v", name, ds[i])
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "views", "html", "good_gwirl.go")); err != nil {
+		t.Errorf("Expected the valid template to be generated: %v", err)
+	}
+}
+
+func TestBuildReportsOnlyTheErrorsOfUnparsedCallees(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "templates"), 0755)
+	os.WriteFile(filepath.Join(root, "templates", "card.html.gwirl"), []byte("@(title string, footer gwirl.HTML)\n@if title {\n"), 0644)
+	os.WriteFile(filepath.Join(root, "templates", "page.html.gwirl"), []byte("@()\n@Card(\"t\") {\n@slot footer {<b>Ok</b>}\n}\n"), 0644)
+
+	err := NewBuilder(&Flags{}, NewRealFSAccessor(root), nil).build()
+	var ds Diagnostics
+	if !errors.As(err, &ds) {
+		t.Fatalf("Expected diagnostics, got %v", err)
+	}
+	if len(ds) != 1 || ds[0].File != "templates/card.html.gwirl" {
+		t.Fatalf("Expected only the error of the card, got %d:\n%v", len(ds), ds)
+	}
+}
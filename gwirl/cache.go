@@ -0,0 +1,152 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+)
+
+// The manifest is kept next to the generated packages so that it is removed
+// along with them.
+var manifestPath = filepath.Join("views", "gwirl_manifest.json")
+
+// The manifest records what every generated file was generated from, so that
+// templates that have not changed since the last build can be skipped.  The
+// whole manifest is discarded when the gwirl executable or the flags that
+// affect the generated code are different from the last build.
+type manifest struct {
+	Version   string                    `json:"version"`
+	Flags     string                    `json:"flags"`
+	Templates map[string]*manifestEntry `json:"templates"`
+}
+
+// What a generated file was generated from.
+type manifestEntry struct {
+	// The hash of the content of the template.
+	Content string `json:"content"`
+	// The parameters of the templates defined in the file, so that the
+	// templates calling them can be generated without parsing it again.
+	Signatures map[string]string `json:"signatures"`
+	// The parameters of the templates the file calls, as they were when it
+	// was generated.  Names that weren't templates of the package are null.
+	Calls map[string]*string `json:"calls"`
+	// The fingerprint of the packages of the module.
+	Packages string `json:"packages"`
+	// The fingerprint of the names declared by the Go files of the package
+	// that aren't generated.
+	Declarations string `json:"declarations"`
+}
+
+func newManifest(version string, flags string) *manifest {
+	return &manifest{
+		Version:   version,
+		Flags:     flags,
+		Templates: make(map[string]*manifestEntry),
+	}
+}
+
+// Loads the manifest from the last build, falling back to an empty one if it
+// does not exist or was written by a different build of gwirl.
+func loadManifest(accessor FSAccessor, version string, flags string) *manifest {
+	m := newManifest(version, flags)
+	if version == "" {
+		return m
+	}
+	content, err := accessor.ReadFile(manifestPath)
+	if err != nil {
+		return m
+	}
+	prev := manifest{}
+	if err := json.Unmarshal(content, &prev); err != nil {
+		return m
+	}
+	if prev.Version != version || prev.Flags != flags || prev.Templates == nil {
+		return m
+	}
+	return &prev
+}
+
+func (m *manifest) save(accessor FSAccessor) error {
+	content, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	accessor.EnsureDirectoryExists("views")
+	return accessor.WriteFile(manifestPath, append(content, '\n'))
+}
+
+// Returns the entry of the template if it was last generated from the same
+// content, which is identified by its hash.
+func (m *manifest) entry(path string, content string) *manifestEntry {
+	if m.Version == "" {
+		return nil
+	}
+	entry := m.Templates[path]
+	if entry == nil || entry.Content != content {
+		return nil
+	}
+	return entry
+}
+
+func (m *manifest) record(path string, entry *manifestEntry) {
+	m.Templates[path] = entry
+}
+
+func (m *manifest) forget(path string) {
+	delete(m.Templates, path)
+}
+
+func hashContent(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// Identifies the running gwirl executable, so that a different build of gwirl
+// never reuses the output of another.  Builds of a version or of a commit are
+// identified by those, and any other build by the modification time and size of
+// its executable.  An empty version disables the manifest.
+func executableVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if version := buildVersion(info); version != "" {
+			return version
+		}
+	}
+	path, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%s %d %d", path, stat.Size(), stat.ModTime().UnixNano())
+}
+
+// Returns the version and commit gwirl was built from, or an empty string when
+// they don't identify the build, like for a build of a checkout with changes
+// that haven't been committed.
+func buildVersion(info *debug.BuildInfo) string {
+	settings := map[string]string{}
+	for _, setting := range info.Settings {
+		settings[setting.Key] = setting.Value
+	}
+	if settings["vcs.modified"] == "true" {
+		return ""
+	}
+	if revision := settings["vcs.revision"]; revision != "" {
+		return info.Main.Version + " " + revision
+	}
+	if info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return ""
+}
+
+// Describes the flags that change the generated code.
+func (flags *Flags) generationKey() string {
+	return fmt.Sprintf("stream=%t escape=%t", flags.stream, flags.escape)
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildSkipsUnchangedTemplates(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "templates"), 0755)
+	template := filepath.Join(root, "templates", "hello.html.gwirl")
+	generated := filepath.Join(root, "views", "html", "hello_gwirl.go")
+	os.WriteFile(template, []byte("@(name string)\n<h1>@name</h1>\n"), 0644)
+
+	accessor := NewRealFSAccessor(root)
+	if err := NewBuilder(&Flags{}, accessor, nil).build(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, manifestPath)); err != nil {
+		t.Fatalf("Expected a manifest to be written: %v", err)
+	}
+
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	os.Chtimes(generated, old, old)
+	modTime := func() time.Time {
+		info, err := os.Stat(generated)
+		if err != nil {
+			t.Fatalf("Expected %s to exist: %v", generated, err)
+		}
+		return info.ModTime()
+	}
+
+	if err := NewBuilder(&Flags{}, accessor, nil).build(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !modTime().Equal(old) {
+		t.Fatalf("Expected unchanged template not to be rewritten")
+	}
+
+	// Without a manifest the template is generated again, but the output is the
+	// same so the file is left alone.
+	os.Remove(filepath.Join(root, manifestPath))
+	if err := NewBuilder(&Flags{}, accessor, nil).build(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !modTime().Equal(old) {
+		t.Fatalf("Expected identical output not to be rewritten")
+	}
+
+	if err := NewBuilder(&Flags{stream: true}, accessor, nil).build(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if modTime().Equal(old) {
+		t.Fatalf("Expected template to be regenerated when the flags change")
+	}
+
+	os.Chtimes(generated, old, old)
+	os.WriteFile(template, []byte("@(name string)\n<h2>@name</h2>\n"), 0644)
+	if err := NewBuilder(&Flags{stream: true}, accessor, nil).build(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if modTime().Equal(old) {
+		t.Fatalf("Expected changed template to be regenerated")
+	}
+
+	os.Remove(generated)
+	if err := NewBuilder(&Flags{stream: true}, accessor, nil).build(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	modTime()
+}
+
+func TestBuildRegeneratesCallersWhenSignaturesChange(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "templates"), 0755)
+	card := filepath.Join(root, "templates", "card.html.gwirl")
+	page := filepath.Join(root, "templates", "page.html.gwirl")
+	generated := filepath.Join(root, "views", "html", "page_gwirl.go")
+	os.WriteFile(card, []byte("@(body gwirl.HTML, footer gwirl.HTML)\n@body@footer\n"), 0644)
+	os.WriteFile(page, []byte("@()\n@Card() {\n@slot footer {f}\n@slot body {b}\n}\n"), 0644)
+
+	accessor := NewRealFSAccessor(root)
+	if err := NewBuilder(&Flags{}, accessor, nil).build(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	before, _ := os.ReadFile(generated)
+
+	os.WriteFile(card, []byte("@(footer gwirl.HTML, body gwirl.HTML)\n@body@footer\n"), 0644)
+	if err := NewBuilder(&Flags{}, accessor, nil).build(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	after, _ := os.ReadFile(generated)
+	if string(before) == string(after) {
+		t.Fatalf("Expected the caller to be regenerated with the new order of slots:\n%s", after)
+	}
+}
+
+func TestBuildOnlyParsesAndRegeneratesWhatChanged(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "templates"), 0755)
+	card := filepath.Join(root, "templates", "card.html.gwirl")
+	os.WriteFile(card, []byte("@(body gwirl.HTML, footer gwirl.HTML)\n@body@footer\n"), 0644)
+	os.WriteFile(filepath.Join(root, "templates", "page.html.gwirl"), []byte("@()\n@Card() {\n@slot footer {f}\n@slot body {b}\n}\n"), 0644)
+	os.WriteFile(filepath.Join(root, "templates", "other.html.gwirl"), []byte("@(name string)\n<p>@name</p>\n"), 0644)
+
+	accessor := NewRealFSAccessor(root)
+	if err := NewBuilder(&Flags{}, accessor, nil).build(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	os.WriteFile(card, []byte("@(footer gwirl.HTML, body gwirl.HTML)\n@body@footer\n"), 0644)
+	log := bytes.Buffer{}
+	if err := NewBuilder(&Flags{}, accessor, &log).build(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, line := range []string{"Parsing card\n", "Parsing page\n", "Generating page\n", "Skipping unchanged other\n"} {
+		if !strings.Contains(log.String(), line) {
+			t.Fatalf("Expected the log to contain %q:\n%s", line, log.String())
+		}
+	}
+	if strings.Contains(log.String(), "Parsing other\n") {
+		t.Fatalf("Expected the unchanged template not to be parsed:\n%s", log.String())
+	}
+}
+
+func TestBuildVersion(t *testing.T) {
+	tests := []struct {
+		name     string
+		version  string
+		settings map[string]string
+		expected string
+	}{
+		{"release", "v1.2.0", nil, "v1.2.0"},
+		{"commit", "(devel)", map[string]string{"vcs.revision": "abc123", "vcs.modified": "false"}, "(devel) abc123"},
+		{"modified checkout", "(devel)", map[string]string{"vcs.revision": "abc123", "vcs.modified": "true"}, ""},
+		{"unknown", "(devel)", nil, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			info := &debug.BuildInfo{Main: debug.Module{Version: test.version}}
+			for key, value := range test.settings {
+				info.Settings = append(info.Settings, debug.BuildSetting{Key: key, Value: value})
+			}
+			if version := buildVersion(info); version != test.expected {
+				t.Fatalf("Expected %q, got %q", test.expected, version)
+			}
+		})
+	}
+	if executableVersion() == "" {
+		t.Fatalf("Expected the test binary to have a version")
+	}
+}
+
+func TestBuildRemovesDeletedTemplates(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "templates"), 0755)
+	template := filepath.Join(root, "templates", "old.html.gwirl")
+	generated := filepath.Join(root, "views", "html", "old_gwirl.go")
+	os.WriteFile(template, []byte("@(name string)\n<p>@name</p>\n"), 0644)
+	os.WriteFile(filepath.Join(root, "templates", "kept.html.gwirl"), []byte("@(name string)\n<p>@name</p>\n"), 0644)
+
+	accessor := NewRealFSAccessor(root)
+	if err := NewBuilder(&Flags{}, accessor, nil).build(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := os.Stat(generated); err != nil {
+		t.Fatalf("Expected %s to be generated: %v", generated, err)
+	}
+
+	os.Remove(template)
+	if err := NewBuilder(&Flags{}, accessor, nil).build(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := os.Stat(generated); !os.IsNotExist(err) {
+		t.Fatalf("Expected the generated file of the deleted template to be removed")
+	}
+	m := loadManifest(accessor, executableVersion(), (&Flags{}).generationKey())
+	if _, ok := m.Templates["templates/old.html.gwirl"]; ok {
+		t.Fatalf("Expected the deleted template to be removed from the manifest")
+	}
+	if _, ok := m.Templates["templates/kept.html.gwirl"]; !ok {
+		t.Fatalf("Expected the other template to stay in the manifest")
+	}
+}
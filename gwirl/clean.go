@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -15,8 +16,8 @@ func cleanDir(filetype string, d []os.DirEntry) {
 	}
 }
 
-func clean() {
-	fmt.Println("Cleaning views directories of Gwirl files...")
+func clean(out io.Writer) {
+	fmt.Fprintln(out, "Cleaning views directories of Gwirl files...")
 	fileTypes := [4]string{"html", "xml", "md", "txt"}
 	for _, ft := range fileTypes {
 		d, err := os.ReadDir(filepath.Join("views", ft))
@@ -25,5 +26,5 @@ func clean() {
 		}
 		cleanDir(ft, d)
 	}
-	fmt.Println("Clean!")
+	fmt.Fprintln(out, "Clean!")
 }
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/gamebox/gwirl/internal/gen"
+	"github.com/gamebox/gwirl/internal/parser"
+)
+
+// Diagnostic is a single problem found in a template.  Lines and columns are
+// 1-based, like the ones reported by the Go compiler, and are 0 when the
+// problem does not have a position in the template.
+type Diagnostic struct {
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	EndLine   int    `json:"endLine"`
+	EndColumn int    `json:"endColumn"`
+	Message   string `json:"message"`
+}
+
+// Formats the diagnostic the same way the Go compiler does, so that editors
+// and terminals can link to the position in the template.
+func (d Diagnostic) String() string {
+	if d.Line == 0 {
+		return fmt.Sprintf("%s: %s", d.File, d.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", d.File, d.Line, d.Column, d.Message)
+}
+
+// Diagnostics is the error returned when a build fails, holding every problem
+// found in the order of the templates they were found in.
+type Diagnostics []Diagnostic
+
+func (ds Diagnostics) Error() string {
+	lines := make([]string, len(ds))
+	for i, d := range ds {
+		lines[i] = d.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+func parseDiagnostics(f *File, errs []parser.ParseError) Diagnostics {
+	ds := make(Diagnostics, len(errs))
+	for i, e := range errs {
+		ds[i] = Diagnostic{
+			File:      filepath.ToSlash(f.path),
+			Line:      e.Start.Line(),
+			Column:    e.Start.Column() + 1,
+			EndLine:   e.End.Line(),
+			EndColumn: e.End.Column() + 1,
+			Message:   e.Err,
+		}
+	}
+	return ds
+}
+
+// Converts an error from building a template into diagnostics, using the
+// error's message as is when it did not come from a position in the template.
+func fileDiagnostics(f *File, err error) Diagnostics {
+	var ds Diagnostics
+	if errors.As(err, &ds) {
+		return ds
+	}
+	var genErr *gen.Error
+	if errors.As(err, &genErr) {
+		return Diagnostics{{
+			File:      filepath.ToSlash(f.path),
+			Line:      genErr.Line,
+			Column:    genErr.Column + 1,
+			EndLine:   genErr.Line,
+			EndColumn: genErr.Column + 1,
+			Message:   genErr.Message,
+		}}
+	}
+	return Diagnostics{{File: filepath.ToSlash(f.path), Message: err.Error()}}
+}
+
+// Writes the diagnostics for people, one per line, or as a JSON array for
+// tools.
+func writeDiagnostics(w io.Writer, ds Diagnostics, asJSON bool) error {
+	if asJSON {
+		if ds == nil {
+			ds = Diagnostics{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(ds)
+	}
+	for _, d := range ds {
+		if _, err := fmt.Fprintln(w, d.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
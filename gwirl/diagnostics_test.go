@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteDiagnostics(t *testing.T) {
+	ds := Diagnostics{
+		{File: "templates/a.html.gwirl", Line: 12, Column: 4, EndLine: 12, EndColumn: 5, Message: "Invalid '@' symbol"},
+		{File: "templates/b.html.gwirl", Message: "Could not open file"},
+	}
+
+	out := bytes.Buffer{}
+	writeDiagnostics(&out, ds, false)
+	expected := "templates/a.html.gwirl:12:4: Invalid '@' symbol\ntemplates/b.html.gwirl: Could not open file\n"
+	if out.String() != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, out.String())
+	}
+
+	out.Reset()
+	writeDiagnostics(&out, ds[:1], true)
+	expected = `[
+  {
+    "file": "templates/a.html.gwirl",
+    "line": 12,
+    "column": 4,
+    "endLine": 12,
+    "endColumn": 5,
+    "message": "Invalid '@' symbol"
+  }
+]
+`
+	if out.String() != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, out.String())
+	}
+
+	out.Reset()
+	writeDiagnostics(&out, nil, true)
+	if out.String() != "[]\n" {
+		t.Errorf("Expected an empty array, got %s", out.String())
+	}
+}
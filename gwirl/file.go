@@ -4,16 +4,20 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 type File struct {
 	name     string
 	filetype string
 	content  string
+	// The path of the template relative to the root directory.
+	path string
 }
 
 type FSAccessor interface {
@@ -33,6 +37,22 @@ type FSAccessor interface {
 	// Will remove the file at the path determined by joining the root directory
 	// with the path given.
 	Remove(name string) error
+	// Reads the file at the path determined by joining the root directory with
+	// the path given.
+	ReadFile(name string) ([]byte, error)
+	// Writes the file at the path determined by joining the root directory with
+	// the path given, replacing it if it exists.
+	WriteFile(name string, content []byte) error
+	// Collects the modification time of every template file found within the
+	// directory specified, keyed by the path of the file relative to the root
+	// directory.
+	TemplateModTimes(dir string, filters []string) map[string]time.Time
+	// Collects the packages of the Go module the root directory is in, keyed
+	// by their names, mapped to their import paths.
+	ModulePackages() map[string]string
+	// Collects the names declared at the top level of the Go files in the
+	// directory specified that aren't generated from templates.
+	PackageDeclarations(dir string) map[string]bool
 }
 
 type RealFSAccessor struct {
@@ -48,6 +68,22 @@ func (a *RealFSAccessor) Remove(name string) error {
 	return os.Remove(filepath.Join(a.rootDir, name))
 }
 
+func (a *RealFSAccessor) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(a.rootDir, name))
+}
+
+func (a *RealFSAccessor) WriteFile(name string, content []byte) error {
+	return os.WriteFile(filepath.Join(a.rootDir, name), content, 0644)
+}
+
+func (a *RealFSAccessor) ModulePackages() map[string]string {
+	return modulePackages(a.rootDir)
+}
+
+func (a *RealFSAccessor) PackageDeclarations(dir string) map[string]bool {
+	return packageDeclarations(filepath.Join(a.rootDir, dir))
+}
+
 func (a *RealFSAccessor) EnsureDirectoryExists(path string) {
 	_, err := os.Stat(filepath.Join(a.rootDir, path))
 	if err != nil {
@@ -59,7 +95,14 @@ func (a *RealFSAccessor) EnsureDirectoryExists(path string) {
 }
 
 func (a *RealFSAccessor) TemplateFiles(templateDir string, filters []string) []File {
-	return templateFiles(filepath.Join(a.rootDir, templateDir), filters)
+	files := templateFiles(filepath.Join(a.rootDir, templateDir), filters)
+	for i := range files {
+		path, err := filepath.Rel(a.rootDir, files[i].path)
+		if err == nil {
+			files[i].path = path
+		}
+	}
+	return files
 }
 
 func templateFiles(templateDir string, filters []string) []File {
@@ -77,23 +120,63 @@ func templateFiles(templateDir string, filters []string) []File {
 			if err != nil {
 				continue
 			}
-			filenameSegments := strings.Split(filepath.Base(dir.Name()), ".")
-			fileType := filenameSegments[len(filenameSegments)-2]
-			if fileType != "html" && fileType != "xml" && fileType != "md" && fileType != "txt" {
-				fileType = "txt"
-			}
-			files = append(files, File{
-				name:     strings.Split(dir.Name(), ".")[0],
-				content:  string(fileContent),
-				filetype: fileType,
-			})
+			f := newFile(filepath.Join(templateDir, dir.Name()))
+			f.content = string(fileContent)
+			files = append(files, f)
 		}
 	}
 	return files
 }
 
+// Creates a File for the template at the given path, without its content.
+func newFile(path string) File {
+	base := filepath.Base(path)
+	filenameSegments := strings.Split(base, ".")
+	fileType := "txt"
+	if len(filenameSegments) > 1 {
+		fileType = filenameSegments[len(filenameSegments)-2]
+	}
+	if fileType != "html" && fileType != "xml" && fileType != "md" && fileType != "txt" {
+		fileType = "txt"
+	}
+	return File{
+		name:     filenameSegments[0],
+		filetype: fileType,
+		path:     path,
+	}
+}
+
+func (a *RealFSAccessor) TemplateModTimes(templateDir string, filters []string) map[string]time.Time {
+	modTimes := make(map[string]time.Time)
+	filepath.WalkDir(filepath.Join(a.rootDir, templateDir), func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if !strings.HasSuffix(d.Name(), ".gwirl") || !matchesFilter(d.Name(), filters) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(a.rootDir, path)
+		if err != nil {
+			return nil
+		}
+		modTimes[rel] = info.ModTime()
+		return nil
+	})
+	return modTimes
+}
+
+// Returns the path of the generated file for a template, relative to the root
+// directory.
+func gwirlFilePath(f *File) string {
+	return filepath.Join("views", f.filetype, f.name+"_gwirl.go")
+}
+
 func (a *RealFSAccessor) CreateGwirlFile(f *File) (io.Writer, string, error) {
-	fileName := filepath.Join(a.rootDir, "views", f.filetype, f.name+"_gwirl.go")
+	fileName := filepath.Join(a.rootDir, gwirlFilePath(f))
 	fileWriter, err := os.Create(fileName)
 	if err != nil {
 		e := errors.New(fmt.Sprintf("Failed to open go file for template: %s\nERROR: %v", f.name, err))
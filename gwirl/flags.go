@@ -12,6 +12,10 @@ import (
 type Flags struct {
 	logger string
 	clean  bool
+	stream bool
+	escape bool
+	watch  bool
+	json   bool
 	filter Filters
 }
 
@@ -33,18 +37,28 @@ func (f *Filters) Set(s string) error {
 	return nil
 }
 
+// Returns where messages about the progress of the build should be written.
+// When diagnostics are written as JSON these messages are dropped, so that the
+// JSON is the only thing written to stdout.
+func (flags *Flags) Output() io.Writer {
+	if flags.json {
+		return io.Discard
+	}
+	return os.Stdout
+}
+
 func (flags *Flags) Logger() io.Writer {
 	var writerName string
 	if flags.logger == "stdout" {
-		fmt.Printf("Will log parsing output to stdout\n")
+		fmt.Fprintf(flags.Output(), "Will log parsing output to stdout\n")
 		return os.Stdout
 	}
 	if flags.logger == "" {
-		fmt.Printf("No log output\n")
+		fmt.Fprintf(flags.Output(), "No log output\n")
 		writerName = os.DevNull
 	} else {
 		writerName = flags.logger
-		fmt.Printf("Will log parsing output to file: %s\n", writerName)
+		fmt.Fprintf(flags.Output(), "Will log parsing output to file: %s\n", writerName)
 	}
 	file, err := os.OpenFile(writerName, os.O_RDWR|os.O_CREATE, 0o777)
 	file.Seek(0, 0)
@@ -61,15 +75,32 @@ func NewFlags() *Flags {
 	flag.Var(&filters, "filter", "Filter the templates that are generated")
 	logger := flag.String("logTo", "", "A file to output logs to.  Use \"stdout\" to have the logs just be printed to stdout")
 	clean := flag.Bool("clean", false, "Clean Gwirl output")
+	escape := flag.Bool("escape", false, "Escape all @ expressions by default, use @raw(...) to output a value without escaping")
+	stream := flag.Bool("stream", false, "Also generate a Write<Name>(io.Writer, ...) error function for each template")
+
+	watch := flag.Bool("watch", false, "Keep running and regenerate templates as they are changed")
+	json := flag.Bool("json", false, "Print errors in templates as JSON")
 
 	flag.Parse()
 	flags.filter = filters
+	if json != nil {
+		flags.json = *json
+	}
 	if logger != nil {
-		fmt.Printf("Logger is %s", *logger)
+		fmt.Fprintf(flags.Output(), "Logger is %s", *logger)
 		flags.logger = *logger
 	}
 	if clean != nil {
 		flags.clean = *clean
 	}
+	if stream != nil {
+		flags.stream = *stream
+	}
+	if escape != nil {
+		flags.escape = *escape
+	}
+	if watch != nil {
+		flags.watch = *watch
+	}
 	return &flags
 }
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"os"
 )
@@ -12,8 +14,26 @@ func main() {
 	cwd, _ := os.Getwd()
 	accessor := NewRealFSAccessor(cwd)
 	builder := NewBuilder(flags, accessor, parserLogger)
+	if flags.watch {
+		watcher := NewWatcher(builder, accessor, os.Stdout)
+		err := builder.build()
+		if err != nil {
+			fmt.Printf("%v\n", err)
+		}
+		watcher.Watch(nil)
+		return
+	}
 	err := builder.build()
-	if err != nil {
-		log.Fatalf("Build failed due to the following errors: %e", err)
+	var ds Diagnostics
+	if err != nil && !errors.As(err, &ds) {
+		log.Fatalf("Build failed due to the following errors: %v", err)
+	}
+	if flags.json {
+		writeDiagnostics(os.Stdout, ds, true)
+	} else {
+		writeDiagnostics(os.Stderr, ds, false)
+	}
+	if len(ds) > 0 {
+		os.Exit(1)
 	}
 }
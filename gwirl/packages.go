@@ -0,0 +1,164 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Collects the packages of the module the directory is in, keyed by their
+// names, so that templates can use them without importing them.  Packages that
+// can't be imported, and names that more than one package has, are left out.
+// The generated views are left out too, since they are what is being built.
+func modulePackages(dir string) map[string]string {
+	root, modulePath := findModule(dir)
+	if root == "" {
+		return map[string]string{}
+	}
+	views := filepath.Join(dir, "views")
+	paths := map[string][]string{}
+	filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		name := d.Name()
+		if p != root && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata" || name == "vendor" || name == "node_modules") {
+			return filepath.SkipDir
+		}
+		if p == views {
+			return filepath.SkipDir
+		}
+		if p != root {
+			if _, err := os.Stat(filepath.Join(p, "go.mod")); err == nil {
+				// Nested modules are packages of their own module.
+				return filepath.SkipDir
+			}
+		}
+		pkg := packageName(p)
+		if pkg == "" || pkg == "main" {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return nil
+		}
+		paths[pkg] = append(paths[pkg], path.Join(modulePath, filepath.ToSlash(rel)))
+		return nil
+	})
+	packages := map[string]string{}
+	for name, ps := range paths {
+		if len(ps) == 1 {
+			packages[name] = ps[0]
+		}
+	}
+	return packages
+}
+
+// Returns the directory of the module the directory is in, along with the path
+// of the module, or empty strings if it isn't in a module.
+func findModule(dir string) (string, string) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", ""
+	}
+	for {
+		content, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+		if err == nil {
+			for _, line := range strings.Split(string(content), "\n") {
+				if rest, ok := strings.CutPrefix(strings.TrimSpace(line), "module"); ok {
+					return dir, strings.Trim(strings.TrimSpace(rest), "\"`")
+				}
+			}
+			return "", ""
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", ""
+		}
+		dir = parent
+	}
+}
+
+// Returns the name of the package in the directory, from the first of its Go
+// files that isn't a test, or an empty string if it has none.
+func packageName(dir string) string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	names := []string{}
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".go") && !strings.HasSuffix(e.Name(), "_test.go") {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		file, err := parser.ParseFile(token.NewFileSet(), filepath.Join(dir, name), nil, parser.PackageClauseOnly)
+		if err == nil {
+			return file.Name.Name
+		}
+	}
+	return ""
+}
+
+// Collects the names declared at the top level of the Go files in the directory
+// that aren't generated from templates or tests.
+func packageDeclarations(dir string) map[string]bool {
+	declarations := map[string]bool{}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return declarations
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_gwirl.go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(token.NewFileSet(), filepath.Join(dir, name), nil, parser.SkipObjectResolution)
+		if err != nil {
+			continue
+		}
+		for _, decl := range file.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				if decl.Recv == nil {
+					declarations[decl.Name.Name] = true
+				}
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					switch spec := spec.(type) {
+					case *ast.ValueSpec:
+						for _, id := range spec.Names {
+							declarations[id.Name] = true
+						}
+					case *ast.TypeSpec:
+						declarations[spec.Name.Name] = true
+					}
+				}
+			}
+		}
+	}
+	return declarations
+}
+
+// Returns a hash of the packages of the module, which changes whenever a
+// package is added, removed, or renamed.
+func packagesFingerprint(packages map[string]string) string {
+	names := make([]string, 0, len(packages))
+	for name := range packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	sb := strings.Builder{}
+	for _, name := range names {
+		sb.WriteString(name + " " + packages[name] + "\n")
+	}
+	return hashContent(sb.String())
+}
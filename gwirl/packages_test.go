@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestModulePackages(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"go.mod":                      "module example.com/app\n\ngo 1.20\n",
+		"main.go":                     "package main\n",
+		"todo/todo.go":                "package todo\n",
+		"todo/todo_test.go":           "package todo_test\n",
+		"internal/format/format.go":   "package format\n",
+		"a/util/util.go":              "package util\n",
+		"b/util/util.go":              "package util\n",
+		"views/html/page_gwirl.go":    "package html\n",
+		"testdata/fixture/fixture.go": "package fixture\n",
+		"tools/go.mod":                "module example.com/tools\n",
+		"tools/gen/gen.go":            "package gen\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := map[string]string{
+		"todo":   "example.com/app/todo",
+		"format": "example.com/app/internal/format",
+	}
+	packages := NewRealFSAccessor(root).ModulePackages()
+	if !reflect.DeepEqual(packages, expected) {
+		t.Errorf("Expected packages %v, got %v", expected, packages)
+	}
+}
+
+func TestPackageDeclarations(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"config.go":     "package html\n\nvar config, site = 1, 2\n\nconst Title = \"x\"\n\ntype Layout struct{}\n\nfunc helper() {}\n\nfunc (Layout) Method() {}\n",
+		"page_gwirl.go": "package html\n\nfunc Page() {}\n",
+		"page_test.go":  "package html\n\nvar fixture = 1\n",
+		"notes.txt":     "var ignored = 1\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := map[string]bool{"config": true, "site": true, "Title": true, "Layout": true, "helper": true}
+	declarations := packageDeclarations(dir)
+	if !reflect.DeepEqual(declarations, expected) {
+		t.Errorf("Expected declarations %v, got %v", expected, declarations)
+	}
+}
@@ -1,16 +1,17 @@
 package html
 
-import "github.com/gamebox/gwirl/gwirl-example/flash"
-import "fmt"
 import (
-    "github.com/gamebox/gwirl"
-)
+	"fmt"
+
+	"github.com/gamebox/gwirl/gwirl-example/flash"
 
+	"github.com/gamebox/gwirl"
+)
 
-func Base(flash *flash.Flash, title string, path string, embed string) string {
-    sb_ := gwirl.TemplateBuilder{}
+func Base(flash *flash.Flash, title string, path string, embed gwirl.HTML) gwirl.HTML {
+	sb_ := gwirl.TemplateBuilder{}
 
-    sb_.WriteString(`
+	sb_.WriteString(`
 <html lang="en" class="min-h-full">
     <head>
         <meta charset="UTF-8" />
@@ -18,9 +19,9 @@ func Base(flash *flash.Flash, title string, path string, embed string) string {
         <link rel="icon" type="image/svg+xml" href="/logo.svg" />
         <title>`)
 
-    sb_.WriteString(title)
+	gwirl.WriteRawHTML(&sb_, ( /*line ../../templates/base.html.gwirl:11:16*/ title /*line base_gwirl.go:22:111*/))
 
-    sb_.WriteString(`</title>
+	sb_.WriteString(`</title>
         <link rel="stylesheet" href="/assets/styles.css">
         <link rel="stylesheet" href="https://rsms.me/inter/inter.css">
         <style>
@@ -34,22 +35,22 @@ func Base(flash *flash.Flash, title string, path string, embed string) string {
     <body class="dark:bg-black min-h-full">
         `)
 
-    sb_.WriteString(Nav(path))
+	gwirl.WriteRawHTML(&sb_, ( /*line ../../templates/base.html.gwirl:23:9*/ Nav(path) /*line base_gwirl.go:38:114*/))
 
-    sb_.WriteString(`
+	sb_.WriteString(`
         `)
 
-    if  flash != nil  {
-        sb_.WriteString(`
+	if /*line ../../templates/base.html.gwirl:24:12*/ flash != nil /*line base_gwirl.go:43:93*/ {
+		sb_.WriteString(`
             <dialog id="flash" class="flash flash-`)
 
-        sb_.WriteString(fmt.Sprint(flash.Type))
+		gwirl.WriteRawHTML(&sb_, ( /*line ../../templates/base.html.gwirl:25:51*/ fmt.Sprint(flash.Type) /*line base_gwirl.go:47:129*/))
 
-        sb_.WriteString(`" open>`)
+		sb_.WriteString(`" open>`)
 
-        sb_.WriteString(flash.Message)
+		gwirl.WriteRawHTML(&sb_, ( /*line ../../templates/base.html.gwirl:25:81*/ flash.Message /*line base_gwirl.go:51:120*/))
 
-        sb_.WriteString(`</dialog>
+		sb_.WriteString(`</dialog>
             <script>
                 document.addEventListener("DOMContentLoaded", () => {
                     const flash = document.body.querySelector("#flash");
@@ -60,19 +61,19 @@ func Base(flash *flash.Flash, title string, path string, embed string) string {
             </script>
         `)
 
-    }
+	}
 
-    sb_.WriteString(`
+	sb_.WriteString(`
 
         `)
 
-    sb_.WriteString(embed)
+	gwirl.WriteRawHTML(&sb_, ( /*line ../../templates/base.html.gwirl:36:9*/ embed /*line base_gwirl.go:70:110*/))
 
-    sb_.WriteString(`
+	sb_.WriteString(`
         <script src="https://unpkg.com/htmx.org@1.9.10/dist/htmx.min.js"></script>
     </body>
 </html>
 `)
 
-    return sb_.String()
+	return sb_.HTML()
 }
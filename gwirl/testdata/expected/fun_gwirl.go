@@ -1,21 +1,20 @@
 package html
 
 import (
-    "github.com/gamebox/gwirl"
+	"github.com/gamebox/gwirl"
 )
 
+func Fun(msg string) gwirl.HTML {
+	sb_ := gwirl.TemplateBuilder{}
 
-func Fun(msg string) string {
-    sb_ := gwirl.TemplateBuilder{}
-
-    sb_.WriteString(`<section>
+	sb_.WriteString(`<section>
     <strong>`)
 
-    gwirl.WriteEscapedHTML(&sb_, msg)
+	gwirl.WriteEscapedHTML(&sb_, ( /*line ../../templates/fun.html.gwirl:4:14*/ msg /*line fun_gwirl.go:13:110*/))
 
-    sb_.WriteString(`</strong>
+	sb_.WriteString(`</strong>
 </section>
 `)
 
-    return sb_.String()
+	return sb_.HTML()
 }
@@ -1,42 +1,41 @@
 package html
 
-import "github.com/gamebox/gwirl/gwirl-example/model"
 import (
-    "github.com/gamebox/gwirl"
-)
+	"github.com/gamebox/gwirl/gwirl-example/model"
 
+	"github.com/gamebox/gwirl"
+)
 
-func Index(participants []model.Participant) string {
-    sb_ := gwirl.TemplateBuilder{}
+func Index(participants []model.Participant) gwirl.HTML {
+	sb_ := gwirl.TemplateBuilder{}
 
-    sb_.WriteString(`
+	sb_.WriteString(`
 `)
 
-    var transclusion__5__1 string
-    {
-        sb_ := gwirl.TemplateBuilder{}
-        sb_.WriteString(`
+	var transclusion__5__1__0 gwirl.HTML
+	{
+		sb_ := gwirl.TemplateBuilder{}
+		sb_.WriteString(`
     <main class="bg-gray-500">
         `)
 
-        sb_.WriteString(ManageParticipants(participants))
+		gwirl.WriteRawHTML(&sb_, ( /*line ../../templates/index.html.gwirl:7:9*/ ManageParticipants(participants) /*line index_gwirl.go:22:139*/))
 
-        sb_.WriteString(`
+		sb_.WriteString(`
         `)
 
-        sb_.WriteString(Fun("This is a message"))
+		gwirl.WriteRawHTML(&sb_, ( /*line ../../templates/index.html.gwirl:8:9*/ Fun("This is a message") /*line index_gwirl.go:27:131*/))
 
-        sb_.WriteString(`
+		sb_.WriteString(`
     </main>
 `)
 
-        transclusion__5__1 = sb_.String()
-    }
-    sb_.WriteString(Base(nil, "Gwirl HTML Example", "/", transclusion__5__1))
-
+		transclusion__5__1__0 = sb_.HTML()
+	}
+	gwirl.WriteRawHTML(&sb_, ( /*line ../../templates/index.html.gwirl:5:1*/ Base(nil, "Gwirl HTML Example", "/", transclusion__5__1__0) /*line index_gwirl.go:35:165*/))
 
-    sb_.WriteString(`
+	sb_.WriteString(`
 `)
 
-    return sb_.String()
+	return sb_.HTML()
 }
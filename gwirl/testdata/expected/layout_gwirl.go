@@ -1,41 +1,40 @@
 package html
 
 import (
-    "github.com/gamebox/gwirl"
+	"github.com/gamebox/gwirl"
 )
 
+func Layout(content gwirl.HTML) gwirl.HTML {
+	sb_ := gwirl.TemplateBuilder{}
 
-func Layout(content string) string {
-    sb_ := gwirl.TemplateBuilder{}
-
-    if  content == ""  {
-        sb_.WriteString(`
+	if /*line ../../templates/layout.html.gwirl:3:4*/ content == "" /*line layout_gwirl.go:10:96*/ {
+		sb_.WriteString(`
     <html></html>
 `)
 
-    }
+	}
 
-    sb_.WriteString(`
+	sb_.WriteString(`
 <html>
     <head>
         <title>`)
 
-    gwirl.WriteEscapedHTML(&sb_, content)
+	gwirl.WriteEscapedHTML(&sb_, ( /*line ../../templates/layout.html.gwirl:8:17*/ content /*line layout_gwirl.go:22:120*/))
 
-    sb_.WriteString(`</title>
+	sb_.WriteString(`</title>
     </head>
     <body hx-boost="true">
         <nav class="nav"></nav>
         <main>`)
 
-    sb_.WriteString(content)
+	gwirl.WriteRawHTML(&sb_, ( /*line ../../templates/layout.html.gwirl:12:15*/ content /*line layout_gwirl.go:30:117*/))
 
-    sb_.WriteString(`</main>
+	sb_.WriteString(`</main>
         <footer></footer>
         <script src="https://unpkg.com/htmx.org@1.9.10/dist/htmx.min.js"></script>
     </body>
 </html>
 `)
 
-    return sb_.String()
+	return sb_.HTML()
 }
@@ -1,15 +1,15 @@
 package html
 
-import "github.com/gamebox/gwirl/gwirl-example/model"
 import (
-    "github.com/gamebox/gwirl"
-)
+	"github.com/gamebox/gwirl/gwirl-example/model"
 
+	"github.com/gamebox/gwirl"
+)
 
-func ManageParticipants(participants []model.Participant) string {
-    sb_ := gwirl.TemplateBuilder{}
+func ManageParticipants(participants []model.Participant) gwirl.HTML {
+	sb_ := gwirl.TemplateBuilder{}
 
-    sb_.WriteString(`
+	sb_.WriteString(`
 <div class="md:flex md:items-center md:justify-between">
     <h1 class="text-2xl font-bold leading-7 text-white sm:truncate sm:text-3xl sm:tracking-tight">Participant Manager</h1>
     <a class="ml-3 inline-flex items-center rounded-md bg-green-600 px-3 py-2 text-sm font-semibold text-white shadow-sm focus-visible:outline focus-visible:outline-2 focus-visible:outline-offset-2 focus-visible:outline-green-500 hover:bg-green-500" target="_blank" href="/cpc/">New</a>
@@ -27,53 +27,53 @@ func ManageParticipants(participants []model.Participant) string {
     <tbody>
         `)
 
-    if  len(participants) == 0  {
-        sb_.WriteString(`
+	if /*line ../../templates/manageParticipants.html.gwirl:20:12*/ len(participants) == 0 /*line manageParticipants_gwirl.go:30:132*/ {
+		sb_.WriteString(`
             <tr>
                 <td colspan="4">No participants</td>
             </tr>
         `)
 
-    }
+	}
 
-    sb_.WriteString(`
+	sb_.WriteString(`
         `)
 
-    for  _, participant := range participants  {
-        sb_.WriteString(`
+	for /*line ../../templates/manageParticipants.html.gwirl:25:13*/ _, participant := range participants /*line manageParticipants_gwirl.go:42:147*/ {
+		sb_.WriteString(`
             <tr>
                 <td>`)
 
-        gwirl.WriteEscapedHTML(&sb_, participant.FirstName)
+		gwirl.WriteEscapedHTML(&sb_, ( /*line ../../templates/manageParticipants.html.gwirl:27:22*/ participant.FirstName /*line manageParticipants_gwirl.go:47:160*/))
 
-        sb_.WriteString(`</td>
+		sb_.WriteString(`</td>
                 <td>`)
 
-        gwirl.WriteEscapedHTML(&sb_, participant.LastName)
+		gwirl.WriteEscapedHTML(&sb_, ( /*line ../../templates/manageParticipants.html.gwirl:28:22*/ participant.LastName /*line manageParticipants_gwirl.go:52:159*/))
 
-        sb_.WriteString(`</td>
+		sb_.WriteString(`</td>
                 <td>`)
 
-        gwirl.WriteEscapedHTML(&sb_, participant.Email)
+		gwirl.WriteEscapedHTML(&sb_, ( /*line ../../templates/manageParticipants.html.gwirl:29:22*/ participant.Email /*line manageParticipants_gwirl.go:57:156*/))
 
-        sb_.WriteString(`</td>
+		sb_.WriteString(`</td>
                 <td>
                     <a href="#" class="action">Delete</a>
                     <a href="/client/participant/`)
 
-        sb_.WriteString(participant.Id)
+		gwirl.WriteRawHTML(&sb_, ( /*line ../../templates/manageParticipants.html.gwirl:32:50*/ participant.Id /*line manageParticipants_gwirl.go:64:149*/))
 
-        sb_.WriteString(`" class="action">Edit</a>
+		sb_.WriteString(`" class="action">Edit</a>
                 </td>
             </tr>
         `)
 
-    }
+	}
 
-    sb_.WriteString(`
+	sb_.WriteString(`
     </tbody>
 </table>
 `)
 
-    return sb_.String()
+	return sb_.HTML()
 }
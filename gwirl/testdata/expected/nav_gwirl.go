@@ -1,49 +1,48 @@
 package html
 
 import (
-    "github.com/gamebox/gwirl"
+	"github.com/gamebox/gwirl"
 )
 
+func Nav(path string) gwirl.HTML {
+	sb_ := gwirl.TemplateBuilder{}
 
-func Nav(path string) string {
-    sb_ := gwirl.TemplateBuilder{}
+//line ../../templates/nav.html.gwirl:3:4
+	routes := []struct {
+		label string
+		path  string
+	}{
+		{"Home", "/"},
+	}
+//line nav_gwirl.go:18:1
 
-    routes := []struct {    
-    label string    
-    path string    
-    }{    
-    { "Home", "/" },    
-    }    
-        
-
-
-    sb_.WriteString(`
+	sb_.WriteString(`
 <nav class="navbar">
     <ul>
         `)
 
-    for  _, route := range routes  {
-        sb_.WriteString(`
+	for /*line ../../templates/nav.html.gwirl:12:13*/ _, route := range routes /*line nav_gwirl.go:24:105*/ {
+		sb_.WriteString(`
         <li `)
 
-        if  route.path == path  {
-            sb_.WriteString(` class="selected" `)
+		if /*line ../../templates/nav.html.gwirl:13:16*/ route.path == path /*line nav_gwirl.go:28:98*/ {
+			sb_.WriteString(` class="selected" `)
 
-        }
+		}
 
-        sb_.WriteString(`>`)
+		sb_.WriteString(`>`)
 
-        sb_.WriteString(route.label)
+		gwirl.WriteRawHTML(&sb_, ( /*line ../../templates/nav.html.gwirl:13:57*/ route.label /*line nav_gwirl.go:35:116*/))
 
-        sb_.WriteString(`</li>
+		sb_.WriteString(`</li>
         `)
 
-    }
+	}
 
-    sb_.WriteString(`
+	sb_.WriteString(`
     </ul>
 </nav>
 `)
 
-    return sb_.String()
+	return sb_.HTML()
 }
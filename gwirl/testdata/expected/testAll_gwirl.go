@@ -1,38 +1,38 @@
 package html
 
 import (
-    "github.com/gamebox/gwirl"
+	"github.com/gamebox/gwirl"
 )
 
+// TestAll: Test Component
+func TestAll(name string, index int) gwirl.HTML {
+	sb_ := gwirl.TemplateBuilder{}
 
-func TestAll(name string, index int) string {
-    sb_ := gwirl.TemplateBuilder{}
+	sb_.WriteString(`<div `)
 
-    sb_.WriteString(`<div `)
+	if /*line ../../templates/testAll.html.gwirl:6:9*/ index == 0 /*line testAll_gwirl.go:13:95*/ {
+		sb_.WriteString(` class="first" `)
 
-    if  index == 0  {
-        sb_.WriteString(` class="first" `)
+	}
 
-    }
-
-    sb_.WriteString(`>
+	sb_.WriteString(`>
     `)
 
-    if  index > 0  {
-        sb_.WriteString(`
+	if /*line ../../templates/testAll.html.gwirl:7:8*/ index > 0 /*line testAll_gwirl.go:21:94*/ {
+		sb_.WriteString(`
         <hr />
     `)
 
-    }
+	}
 
-    sb_.WriteString(`
+	sb_.WriteString(`
     <h2>`)
 
-    sb_.WriteString(name)
+	gwirl.WriteRawHTML(&sb_, ( /*line ../../templates/testAll.html.gwirl:10:9*/ name /*line testAll_gwirl.go:31:115*/))
 
-    sb_.WriteString(`</h2>
+	sb_.WriteString(`</h2>
 </div>
 `)
 
-    return sb_.String()
+	return sb_.HTML()
 }
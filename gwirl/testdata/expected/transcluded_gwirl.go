@@ -1,63 +1,67 @@
 package html
 
 import (
-    "github.com/gamebox/gwirl"
+	"github.com/gamebox/gwirl"
 )
 
-
-func Transcluded(name string, index int) string {
-    sb_ := gwirl.TemplateBuilder{}
-
-    var foo string    
-    if index % 2 == 0 {    
-    foo = "even"    
-    } else {    
-    foo = "odd"    
-    }    
-        
-
-
-    sb_.WriteString(`
+func Transcluded(name string, index int) gwirl.HTML {
+	sb_ := gwirl.TemplateBuilder{}
+
+//line ../../templates/transcluded.html.gwirl:4:4
+	var foo string
+//line ../../templates/transcluded.html.gwirl:5:4
+	if index%2 == 0 {
+//line ../../templates/transcluded.html.gwirl:6:7
+		foo = "even"
+//line ../../templates/transcluded.html.gwirl:7:4
+	} else {
+//line ../../templates/transcluded.html.gwirl:8:7
+		foo = "odd"
+//line ../../templates/transcluded.html.gwirl:9:4
+	}
+//line transcluded_gwirl.go:23:1
+
+	sb_.WriteString(`
 
 `)
 
-    var transclusion__12__1 string
-    {
-        sb_ := gwirl.TemplateBuilder{}
-        sb_.WriteString(`
+	var transclusion__12__1__0 gwirl.HTML
+	{
+		sb_ := gwirl.TemplateBuilder{}
+		sb_.WriteString(`
     <div>
         `)
 
-        if  index > 0  {
-            sb_.WriteString(`
+		if /*line ../../templates/transcluded.html.gwirl:14:12*/ index > 0 /*line transcluded_gwirl.go:35:106*/ {
+			sb_.WriteString(`
             <hr />
         `)
 
-        }
+		}
 
-        sb_.WriteString(`
+		sb_.WriteString(`
         <h2>`)
 
-        sb_.WriteString(name)
+		gwirl.WriteRawHTML(&sb_, ( /*line ../../templates/transcluded.html.gwirl:17:13*/ name /*line transcluded_gwirl.go:45:125*/))
 
-        sb_.WriteString(`</h2>
+		sb_.WriteString(`</h2>
         <h3>`)
 
-        sb_.WriteString(foo)
+		gwirl.WriteRawHTML(&sb_, ( /*line ../../templates/transcluded.html.gwirl:18:13*/ foo /*line transcluded_gwirl.go:50:124*/))
 
-        sb_.WriteString(`</h3>
+		sb_.WriteString(`</h3>
         <script>
-            document.body.addEventListener("load", () => {`)
-
-        transclusion__12__1 = sb_.String()
-    }
-    sb_.WriteString(Layout(transclusion__12__1))
-
-
-    sb_.WriteString(`)
+            document.body.addEventListener("load", () => {})
         </script>
     </div>
 `)
 
-    return sb_.String()
+		transclusion__12__1__0 = sb_.HTML()
+	}
+	gwirl.WriteRawHTML(&sb_, ( /*line ../../templates/transcluded.html.gwirl:12:1*/ Layout(transclusion__12__1__0) /*line transcluded_gwirl.go:61:149*/))
+
+	sb_.WriteString(`
+`)
+
+	return sb_.HTML()
 }
@@ -1,30 +1,29 @@
 package html
 
 import (
-    "github.com/gamebox/gwirl"
+	"github.com/gamebox/gwirl"
 )
 
+func UseOther(names []string) gwirl.HTML {
+	sb_ := gwirl.TemplateBuilder{}
 
-func UseOther(names []string) string {
-    sb_ := gwirl.TemplateBuilder{}
-
-    sb_.WriteString(`<div>
+	sb_.WriteString(`<div>
 `)
 
-    for  i, name := range names  {
-        sb_.WriteString(`
+	for /*line ../../templates/useOther.html.gwirl:4:5*/ i, name := range names /*line useOther_gwirl.go:13:111*/ {
+		sb_.WriteString(`
     `)
 
-        sb_.WriteString(TestAll(name, i))
+		gwirl.WriteRawHTML(&sb_, ( /*line ../../templates/useOther.html.gwirl:5:5*/ TestAll(name, i) /*line useOther_gwirl.go:17:129*/))
 
-        sb_.WriteString(`
+		sb_.WriteString(`
 `)
 
-    }
+	}
 
-    sb_.WriteString(`
+	sb_.WriteString(`
 </div>
 `)
 
-    return sb_.String()
+	return sb_.HTML()
 }
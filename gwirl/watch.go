@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	watchInterval = 500 * time.Millisecond
+	watchDebounce = 100 * time.Millisecond
+)
+
+// Watcher polls the templates directory and regenerates the templates that are
+// created or changed, and removes the generated files of templates that are
+// deleted.
+type Watcher struct {
+	builder  *Builder
+	accessor FSAccessor
+	out      io.Writer
+	interval time.Duration
+	debounce time.Duration
+	modTimes map[string]time.Time
+}
+
+func NewWatcher(builder *Builder, accessor FSAccessor, out io.Writer) *Watcher {
+	w := Watcher{
+		builder:  builder,
+		accessor: accessor,
+		out:      out,
+		interval: watchInterval,
+		debounce: watchDebounce,
+	}
+	w.modTimes = w.snapshot()
+	return &w
+}
+
+func (w *Watcher) snapshot() map[string]time.Time {
+	return w.accessor.TemplateModTimes("templates", w.builder.flags.filter.filters)
+}
+
+// Compares the templates directory to the last time it was polled.  The result
+// maps the path of every template that changed to whether it still exists.
+func (w *Watcher) poll() map[string]bool {
+	modTimes := w.snapshot()
+	changes := make(map[string]bool)
+	for path, modTime := range modTimes {
+		if prev, ok := w.modTimes[path]; !ok || !prev.Equal(modTime) {
+			changes[path] = true
+		}
+	}
+	for path := range w.modTimes {
+		if _, ok := modTimes[path]; !ok {
+			changes[path] = false
+		}
+	}
+	w.modTimes = modTimes
+	return changes
+}
+
+// Removes the generated files of deleted templates, and then builds the
+// templates again.  Only the templates that changed, or that call templates
+// whose parameters changed, are generated again.  Errors are reported for each
+// file and do not stop the rest from being processed.
+func (w *Watcher) apply(changes map[string]bool) {
+	paths := make([]string, 0, len(changes))
+	for path := range changes {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if changes[path] {
+			continue
+		}
+		f := newFile(path)
+		err := w.builder.removeFile(&f)
+		if err != nil {
+			fmt.Fprintf(w.out, "%s: could not remove generated file: %v\n", path, err)
+		} else {
+			fmt.Fprintf(w.out, "%s: removed\n", path)
+		}
+	}
+
+	err := w.builder.buildAll()
+	w.builder.saveManifest()
+	var ds Diagnostics
+	if err != nil && !errors.As(err, &ds) {
+		fmt.Fprintf(w.out, "%v\n", err)
+		return
+	}
+	failed := map[string]bool{}
+	for _, d := range ds {
+		failed[d.File] = true
+	}
+	writeDiagnostics(w.out, ds, false)
+	for _, path := range paths {
+		if changes[path] && !failed[filepath.ToSlash(path)] {
+			fmt.Fprintf(w.out, "%s: regenerated\n", path)
+		}
+	}
+}
+
+// Watches for changes until stop is closed.  Once a change is seen, the
+// directory is polled again until it settles, since editors will often write
+// a file more than once when saving it.
+func (w *Watcher) Watch(stop <-chan struct{}) {
+	fmt.Fprintf(w.out, "Watching templates for changes...\n")
+	for {
+		select {
+		case <-stop:
+			return
+		case <-time.After(w.interval):
+		}
+		changes := w.poll()
+		if len(changes) == 0 {
+			continue
+		}
+		for {
+			time.Sleep(w.debounce)
+			more := w.poll()
+			if len(more) == 0 {
+				break
+			}
+			for path, exists := range more {
+				changes[path] = exists
+			}
+		}
+		w.apply(changes)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWatcherApplyChanges(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "templates"), 0755)
+	accessor := NewRealFSAccessor(root)
+	b := NewBuilder(&Flags{}, accessor, nil)
+	out := bytes.Buffer{}
+	w := NewWatcher(b, accessor, &out)
+
+	template := filepath.Join("templates", "hello.html.gwirl")
+	generated := filepath.Join(root, "views", "html", "hello_gwirl.go")
+	os.WriteFile(filepath.Join(root, template), []byte("@(name string)\n<h1>@name</h1>\n"), 0644)
+	changes := w.poll()
+	if exists, ok := changes[template]; !ok || !exists {
+		t.Fatalf("Expected %s to be seen as created, got %v", template, changes)
+	}
+	w.apply(changes)
+	if _, err := os.Stat(generated); err != nil {
+		t.Fatalf("Expected %s to be generated: %v", generated, err)
+	}
+
+	if changes := w.poll(); len(changes) != 0 {
+		t.Fatalf("Expected no changes, got %v", changes)
+	}
+
+	broken := filepath.Join("templates", "broken.html.gwirl")
+	os.WriteFile(filepath.Join(root, broken), []byte("@(name string)\n@if name {\n"), 0644)
+	os.Remove(filepath.Join(root, template))
+	changes = w.poll()
+	if exists, ok := changes[template]; !ok || exists {
+		t.Fatalf("Expected %s to be seen as deleted, got %v", template, changes)
+	}
+	w.apply(changes)
+	if _, err := os.Stat(generated); !os.IsNotExist(err) {
+		t.Fatalf("Expected %s to be removed", generated)
+	}
+	if !strings.Contains(out.String(), broken+":") {
+		t.Fatalf("Expected an error to be reported for %s, got:\n%s", broken, out.String())
+	}
+}
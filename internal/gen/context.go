@@ -0,0 +1,475 @@
+package gen
+
+import (
+	"strings"
+)
+
+// The part of an HTML document that output is currently being written to.
+type htmlState int
+
+const (
+	stateText htmlState = iota
+	stateTagName
+	stateEndTag
+	stateTag
+	stateAttrName
+	stateAfterAttrName
+	stateBeforeValue
+	stateAttr
+	stateScript
+	stateStyle
+	stateComment
+)
+
+type attrKind int
+
+const (
+	attrNormal attrKind = iota
+	attrURL
+	attrJS
+	attrCSS
+)
+
+type urlPart int
+
+const (
+	urlStart urlPart = iota
+	urlPath
+	urlQuery
+)
+
+// htmlContext tracks where in an HTML document the generator currently is, so
+// that escaped expressions can use the escaping rules for their position. It
+// is advanced over the plain text of a template in the same way html/template
+// tracks the context of its actions.
+type htmlContext struct {
+	state    htmlState
+	tagName  string
+	attrName string
+	attr     attrKind
+	delim    byte
+	url      urlPart
+	quote    byte
+	escaped  bool
+	// The kind of comment in JavaScript or CSS, '/' for a line comment and
+	// '*' for a block comment.
+	comment byte
+	// Whether a JavaScript regular expression literal is open, and whether
+	// it is in a character class, where a slash doesn't end it.
+	regexp      bool
+	regexpClass bool
+	// Whether a slash in JavaScript would be a division, rather than the
+	// start of a regular expression.
+	divOp bool
+	cdata bool
+}
+
+var urlAttrs = map[string]bool{
+	"action":     true,
+	"background": true,
+	"cite":       true,
+	"codebase":   true,
+	"data":       true,
+	"formaction": true,
+	"href":       true,
+	"icon":       true,
+	"longdesc":   true,
+	"manifest":   true,
+	"poster":     true,
+	"profile":    true,
+	"src":        true,
+	"usemap":     true,
+	"xmlns":      true,
+}
+
+func attrKindFor(name string) attrKind {
+	name = strings.ToLower(name)
+	name = strings.TrimPrefix(name, "data-")
+	switch {
+	case strings.HasPrefix(name, "on"):
+		return attrJS
+	case name == "style":
+		return attrCSS
+	case urlAttrs[name], strings.Contains(name, "src"), strings.Contains(name, "uri"), strings.Contains(name, "url"):
+		return attrURL
+	}
+	return attrNormal
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+func isLetter(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+func hasPrefixFold(s string, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
+// Returns the context that should be used for the content of the element whose
+// start tag was just closed.
+func (c htmlContext) enterBody() htmlContext {
+	switch strings.ToLower(c.tagName) {
+	case "script":
+		return htmlContext{state: stateScript}
+	case "style":
+		return htmlContext{state: stateStyle}
+	}
+	return htmlContext{state: stateText}
+}
+
+// Tracks string literals within JavaScript and CSS content.
+func (c *htmlContext) trackQuote(ch byte) {
+	if c.escaped {
+		c.escaped = false
+	} else if ch == '\\' {
+		c.escaped = true
+	} else if ch == c.quote {
+		c.quote = 0
+	}
+}
+
+// The keywords after which a slash starts a regular expression instead of
+// being a division.
+var regexpKeywords = map[string]bool{
+	"case":       true,
+	"delete":     true,
+	"do":         true,
+	"else":       true,
+	"in":         true,
+	"instanceof": true,
+	"new":        true,
+	"return":     true,
+	"throw":      true,
+	"typeof":     true,
+	"void":       true,
+	"yield":      true,
+}
+
+func isJSIdentifierPart(c byte) bool {
+	return isLetter(c) || ('0' <= c && c <= '9') || c == '_' || c == '$' || c >= 0x80
+}
+
+// Reports whether text ends with a keyword after which a slash starts a
+// regular expression, ignoring trailing whitespace.
+func endsWithRegexpKeyword(text string) bool {
+	text = strings.TrimRight(text, " \t\n\r\f")
+	start := len(text)
+	for start > 0 && isJSIdentifierPart(text[start-1]) {
+		start--
+	}
+	return regexpKeywords[text[start:]]
+}
+
+// Tracks the string literals, comments and regular expression literals within
+// JavaScript content, given the text and the index of the current byte.  It
+// returns the index of the last byte it consumed.
+func (c *htmlContext) trackJS(text string, i int) int {
+	ch := text[i]
+	switch {
+	case c.comment == '/':
+		if ch == '\n' || ch == '\r' {
+			c.comment = 0
+		}
+	case c.comment == '*':
+		if strings.HasPrefix(text[i:], "*/") {
+			c.comment = 0
+			return i + 1
+		}
+	case c.quote != 0:
+		c.trackQuote(ch)
+	case c.regexp:
+		if c.escaped {
+			c.escaped = false
+		} else if ch == '\\' {
+			c.escaped = true
+		} else if ch == '[' {
+			c.regexpClass = true
+		} else if ch == ']' {
+			c.regexpClass = false
+		} else if ch == '/' && !c.regexpClass {
+			c.regexp = false
+		}
+	case strings.HasPrefix(text[i:], "//"), strings.HasPrefix(text[i:], "/*"):
+		c.comment = text[i+1]
+		return i + 1
+	case ch == '/':
+		if c.divOp && !endsWithRegexpKeyword(text[:i]) {
+			c.divOp = false
+		} else {
+			c.regexp = true
+		}
+	case ch == '"' || ch == '\'' || ch == '`':
+		c.quote = ch
+		c.divOp = true
+	case isJSIdentifierPart(ch) || ch == ')' || ch == ']':
+		c.divOp = true
+	case !isSpace(ch):
+		c.divOp = false
+	}
+	return i
+}
+
+// Tracks the string literals and comments within CSS content, given the text
+// and the index of the current byte.  It returns the index of the last byte it
+// consumed.
+func (c *htmlContext) trackCSS(text string, i int) int {
+	ch := text[i]
+	switch {
+	case c.comment != 0:
+		if strings.HasPrefix(text[i:], "*/") {
+			c.comment = 0
+			return i + 1
+		}
+	case c.quote != 0:
+		c.trackQuote(ch)
+	case strings.HasPrefix(text[i:], "/*"):
+		c.comment = '*'
+		return i + 1
+	case ch == '"' || ch == '\'':
+		c.quote = ch
+	}
+	return i
+}
+
+// Reports whether the content of a script or style, or of an event handler or
+// style attribute, is in a comment or a literal that isn't finished yet.
+func (c htmlContext) inLiteral() bool {
+	return c.quote != 0 || c.comment != 0 || c.regexp
+}
+
+func (c htmlContext) startAttr(delim byte) htmlContext {
+	c.state = stateAttr
+	c.attr = attrKindFor(c.attrName)
+	c.delim = delim
+	c.url = urlStart
+	c.quote = 0
+	c.escaped = false
+	c.comment = 0
+	c.regexp = false
+	c.regexpClass = false
+	c.divOp = false
+	return c
+}
+
+// Returns the context after the given plain text has been output.
+func (c htmlContext) advance(text string) htmlContext {
+	for i := 0; i < len(text); i++ {
+		ch := text[i]
+		switch c.state {
+		case stateText:
+			if ch != '<' {
+				continue
+			}
+			rest := text[i:]
+			if strings.HasPrefix(rest, "<!--") {
+				c.state = stateComment
+				i += 3
+			} else if len(rest) > 1 && rest[1] == '/' {
+				c.state = stateEndTag
+				i += 1
+			} else if len(rest) > 1 && isLetter(rest[1]) {
+				c.state = stateTagName
+				c.tagName = ""
+			}
+		case stateTagName:
+			if isSpace(ch) {
+				c.state = stateTag
+			} else if ch == '>' {
+				c = c.enterBody()
+			} else if ch == '/' {
+				c.state = stateTag
+			} else {
+				c.tagName += string(ch)
+			}
+		case stateEndTag:
+			if ch == '>' {
+				c = htmlContext{state: stateText}
+			}
+		case stateTag:
+			if ch == '>' {
+				c = c.enterBody()
+			} else if !isSpace(ch) && ch != '/' {
+				c.state = stateAttrName
+				c.attrName = string(ch)
+			}
+		case stateAttrName:
+			if ch == '=' {
+				c.state = stateBeforeValue
+			} else if ch == '>' {
+				c = c.enterBody()
+			} else if isSpace(ch) {
+				c.state = stateAfterAttrName
+			} else {
+				c.attrName += string(ch)
+			}
+		case stateAfterAttrName:
+			if ch == '=' {
+				c.state = stateBeforeValue
+			} else if ch == '>' {
+				c = c.enterBody()
+			} else if !isSpace(ch) && ch != '/' {
+				c.state = stateAttrName
+				c.attrName = string(ch)
+			}
+		case stateBeforeValue:
+			if ch == '"' || ch == '\'' {
+				c = c.startAttr(ch)
+			} else if ch == '>' {
+				c = c.enterBody()
+			} else if !isSpace(ch) {
+				c = c.startAttr(0)
+				i -= 1
+			}
+		case stateAttr:
+			if (c.delim != 0 && ch == c.delim) || (c.delim == 0 && isSpace(ch)) {
+				c.state = stateTag
+				continue
+			}
+			if c.delim == 0 && ch == '>' {
+				c = c.enterBody()
+				continue
+			}
+			switch c.attr {
+			case attrURL:
+				if ch == '?' || ch == '#' {
+					c.url = urlQuery
+				} else if c.url == urlStart {
+					c.url = urlPath
+				}
+			case attrJS:
+				i = c.trackJS(text, i)
+			case attrCSS:
+				i = c.trackCSS(text, i)
+			}
+		case stateScript:
+			if ch == '<' && hasPrefixFold(text[i:], "</script") {
+				c.state = stateEndTag
+				i += 1
+				continue
+			}
+			i = c.trackJS(text, i)
+		case stateStyle:
+			if ch == '<' && hasPrefixFold(text[i:], "</style") {
+				c.state = stateEndTag
+				i += 1
+				continue
+			}
+			i = c.trackCSS(text, i)
+		case stateComment:
+			if strings.HasPrefix(text[i:], "-->") {
+				c.state = stateText
+				i += 2
+			}
+		}
+	}
+	return c
+}
+
+// Returns the context after the given plain text of an XML template has been
+// output.  The only thing that changes how XML is escaped is whether the output
+// is inside of a CDATA section.
+func (c htmlContext) advanceXML(text string) htmlContext {
+	for text != "" {
+		if c.cdata {
+			i := strings.Index(text, "]]>")
+			if i < 0 {
+				break
+			}
+			text = text[i+3:]
+			c.cdata = false
+		} else {
+			i := strings.Index(text, "<![CDATA[")
+			if i < 0 {
+				break
+			}
+			text = text[i+9:]
+			c.cdata = true
+		}
+	}
+	return c
+}
+
+// Returns the context after a dynamic value has been output.  A value right
+// after the = of an attribute starts an unquoted value, and a value in
+// JavaScript is an expression, so a slash after it is a division.
+func (c htmlContext) afterValue() htmlContext {
+	if c.state == stateBeforeValue {
+		c = c.startAttr(0)
+	}
+	if c.state == stateAttr && c.attr == attrURL && c.url == urlStart {
+		c.url = urlPath
+	}
+	if (c.state == stateScript || (c.state == stateAttr && c.attr == attrJS)) && !c.inLiteral() {
+		c.divOp = true
+	}
+	return c
+}
+
+// Reports whether output is inside of a tag, but not in an attribute value,
+// where an escaped value could only be a tag or attribute name.
+func (c htmlContext) inTag() bool {
+	switch c.state {
+	case stateTagName, stateEndTag, stateTag, stateAttrName, stateAfterAttrName:
+		return true
+	}
+	return false
+}
+
+// Returns the escaping function for a value in JavaScript.
+func (c htmlContext) jsEscaper() string {
+	switch {
+	case c.regexp:
+		return "gwirl.EscapeJSRegexp("
+	case c.inLiteral():
+		return "gwirl.EscapeJSString("
+	}
+	return "gwirl.EscapeJS("
+}
+
+// Returns the escaping function for a value in CSS.
+func (c htmlContext) cssEscaper() string {
+	if c.inLiteral() {
+		return "gwirl.EscapeCSSString("
+	}
+	return "gwirl.EscapeCSS("
+}
+
+// Returns the code that should surround an escaped expression so that its
+// value is escaped for this context.
+func (c htmlContext) escaper() (string, string) {
+	if c.state == stateBeforeValue {
+		c = c.startAttr(0)
+	}
+	switch c.state {
+	case stateAttr:
+		// Unquoted values end at whitespace, so they are escaped more.
+		writer := "gwirl.WriteEscapedAttr(&sb_, "
+		if c.delim == 0 {
+			writer = "gwirl.WriteEscapedUnquotedAttr(&sb_, "
+		}
+		switch c.attr {
+		case attrURL:
+			switch c.url {
+			case urlStart:
+				return writer + "gwirl.EscapeURL(", "))"
+			case urlPath:
+				return writer + "gwirl.EscapeURLPath(", "))"
+			default:
+				return writer + "gwirl.EscapeURLQuery(", "))"
+			}
+		case attrJS:
+			return writer + c.jsEscaper(), "))"
+		case attrCSS:
+			return writer + c.cssEscaper(), "))"
+		}
+		return writer, ")"
+	case stateScript:
+		return "gwirl.WriteRawHTML(&sb_, " + c.jsEscaper(), "))"
+	case stateStyle:
+		return "gwirl.WriteRawHTML(&sb_, " + c.cssEscaper(), "))"
+	}
+	return "gwirl.WriteEscapedHTML(&sb_, ", ")"
+}
@@ -0,0 +1,91 @@
+package gen
+
+import "testing"
+
+var contextTests = []struct {
+	name     string
+	text     string
+	expected string
+}{
+	{"text", "<div>", "gwirl.WriteEscapedHTML(&sb_, "},
+	{"attribute", `<div class="`, "gwirl.WriteEscapedAttr(&sb_, "},
+	{"closed attribute", `<div class="a" id="b">`, "gwirl.WriteEscapedHTML(&sb_, "},
+	{"url attribute", `<a href="`, "gwirl.WriteEscapedAttr(&sb_, gwirl.EscapeURL("},
+	{"url path", `<a href="/users/`, "gwirl.WriteEscapedAttr(&sb_, gwirl.EscapeURLPath("},
+	{"url query", `<a href="/todo?id=`, "gwirl.WriteEscapedAttr(&sb_, gwirl.EscapeURLQuery("},
+	{"event handler", `<button onclick="go(`, "gwirl.WriteEscapedAttr(&sb_, gwirl.EscapeJS("},
+	{"event handler string", `<button onclick="go('`, "gwirl.WriteEscapedAttr(&sb_, gwirl.EscapeJSString("},
+	{"style attribute", `<div style="color: `, "gwirl.WriteEscapedAttr(&sb_, gwirl.EscapeCSS("},
+	{"script", "<script>\n  const x = ", "gwirl.WriteRawHTML(&sb_, gwirl.EscapeJS("},
+	{"script string", "<script>\n  const x = \"a\\\"", "gwirl.WriteRawHTML(&sb_, gwirl.EscapeJSString("},
+	{"after script", "<script>const x = 1;</script>\n<p>", "gwirl.WriteEscapedHTML(&sb_, "},
+	{"style", "<style>\n  body { color: ", "gwirl.WriteRawHTML(&sb_, gwirl.EscapeCSS("},
+	{"style string", "<style>\n  body { font-family: '", "gwirl.WriteRawHTML(&sb_, gwirl.EscapeCSSString("},
+	{"comment", "<!-- <script> -->", "gwirl.WriteEscapedHTML(&sb_, "},
+	{"unquoted attribute", "<div class=", "gwirl.WriteEscapedUnquotedAttr(&sb_, "},
+	{"unquoted attribute value", "<div class=a", "gwirl.WriteEscapedUnquotedAttr(&sb_, "},
+	{"unquoted url attribute", "<a href=", "gwirl.WriteEscapedUnquotedAttr(&sb_, gwirl.EscapeURL("},
+	{"after unquoted attribute", "<div class=a ", "gwirl.WriteEscapedHTML(&sb_, "},
+	{"script after line comment", "<script>// don't\n  var a = ", "gwirl.WriteRawHTML(&sb_, gwirl.EscapeJS("},
+	{"script line comment", "<script>// the name is ", "gwirl.WriteRawHTML(&sb_, gwirl.EscapeJSString("},
+	{"script after block comment", "<script>/* it's */ var a = ", "gwirl.WriteRawHTML(&sb_, gwirl.EscapeJS("},
+	{"script block comment", "<script>/* it's ", "gwirl.WriteRawHTML(&sb_, gwirl.EscapeJSString("},
+	{"script after regexp", "<script>var r = /[/']/; var a = ", "gwirl.WriteRawHTML(&sb_, gwirl.EscapeJS("},
+	{"script regexp", "<script>var r = /^", "gwirl.WriteRawHTML(&sb_, gwirl.EscapeJSRegexp("},
+	{"script regexp after keyword", "<script>return /", "gwirl.WriteRawHTML(&sb_, gwirl.EscapeJSRegexp("},
+	{"script division", "<script>var a = b / 2 + '", "gwirl.WriteRawHTML(&sb_, gwirl.EscapeJSString("},
+	{"event handler comment", `<button onclick="go() // it's `, "gwirl.WriteEscapedAttr(&sb_, gwirl.EscapeJSString("},
+	{"style after comment", "<style>\n  /* it's */ body { color: ", "gwirl.WriteRawHTML(&sb_, gwirl.EscapeCSS("},
+	{"style comment", "<style>\n  /* the color is ", "gwirl.WriteRawHTML(&sb_, gwirl.EscapeCSSString("},
+}
+
+func TestHTMLContext(t *testing.T) {
+	for _, test := range contextTests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := htmlContext{}.advance(test.text)
+			start, _ := ctx.escaper()
+			if start != test.expected {
+				t.Fatalf("Expected \"%s\", got \"%s\"", test.expected, start)
+			}
+		})
+	}
+}
+
+func TestHTMLContextAcrossSegments(t *testing.T) {
+	ctx := htmlContext{}.advance("<a hr")
+	ctx = ctx.advance("ef=\"")
+	start, _ := ctx.escaper()
+	if start != "gwirl.WriteEscapedAttr(&sb_, gwirl.EscapeURL(" {
+		t.Fatalf("Expected a URL escaper, got \"%s\"", start)
+	}
+	ctx = ctx.afterValue()
+	start, _ = ctx.escaper()
+	if start != "gwirl.WriteEscapedAttr(&sb_, gwirl.EscapeURLPath(" {
+		t.Fatalf("Expected a URL path escaper after a value, got \"%s\"", start)
+	}
+}
+
+func TestHTMLContextAfterValue(t *testing.T) {
+	ctx := htmlContext{}.advance("<script>var a = ").afterValue()
+	ctx = ctx.advance(" / 2; var b = '")
+	start, _ := ctx.escaper()
+	if start != "gwirl.WriteRawHTML(&sb_, gwirl.EscapeJSString(" {
+		t.Fatalf("Expected a slash after a value to be a division, got \"%s\"", start)
+	}
+	ctx = htmlContext{}.advance("<div class=").afterValue().advance(" ")
+	start, _ = ctx.escaper()
+	if !ctx.inTag() || start != "gwirl.WriteEscapedHTML(&sb_, " {
+		t.Fatalf("Expected an unquoted value to end at a space, got \"%s\"", start)
+	}
+}
+
+func TestXMLContext(t *testing.T) {
+	ctx := htmlContext{}.advanceXML("<item><description><![CDATA[")
+	if !ctx.cdata {
+		t.Fatal("Expected to be in a CDATA section")
+	}
+	ctx = ctx.advanceXML("]]></description><title>")
+	if ctx.cdata {
+		t.Fatal("Expected to be out of the CDATA section")
+	}
+}
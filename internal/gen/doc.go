@@ -0,0 +1,146 @@
+package gen
+
+import (
+	"strings"
+
+	"github.com/gamebox/gwirl/internal/parser"
+)
+
+// Returns the lines of a template comment as they should appear in a Go
+// comment.  Borders made of asterisks, like the ones around a boxed comment,
+// are removed, as is the indentation the lines have in common, so that
+// indented lines like lists of parameters keep their structure.
+func commentLines(text string) []string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	boxed := true
+	for i, line := range lines {
+		line = strings.TrimRight(line, " \t")
+		if strings.Trim(line, "* \t") == "" {
+			line = ""
+		} else if !strings.HasPrefix(strings.TrimLeft(line, " \t"), "*") {
+			boxed = false
+		}
+		lines[i] = line
+	}
+	for i, line := range lines {
+		if boxed && line != "" {
+			line = strings.TrimPrefix(strings.TrimLeft(line, " \t"), "*")
+			if strings.HasSuffix(line, " *") {
+				line = strings.TrimRight(strings.TrimSuffix(line, "*"), " \t")
+			}
+			lines[i] = line
+		}
+	}
+	indent := -1
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+		n := len(line) - len(strings.TrimLeft(line, " \t"))
+		if indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = line[indent:]
+		}
+	}
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// Writes the lines of a template comment as Go line comments.
+func (G *Generator) genComment(text string) {
+	G.writeComment(commentLines(text))
+}
+
+// Writes lines as Go line comments.
+func (G *Generator) writeComment(lines []string) {
+	for _, line := range lines {
+		if line == "" {
+			G.writeln("//")
+		} else {
+			G.writeln("// " + line)
+		}
+	}
+}
+
+// Returns the lines of the doc comment of a template, which starts with the
+// name of the template the way Go doc comments do, and has its lists written as
+// Go doc lists.
+func docLines(name string, text string) []string {
+	lines := listLines(commentLines(text))
+	if len(lines) == 0 {
+		return lines
+	}
+	first := lines[0]
+	word, _, _ := strings.Cut(first, " ")
+	switch {
+	case strings.HasPrefix(first, " "):
+		lines = append([]string{name + ":", ""}, lines...)
+	case word == name:
+	case word == "A" || word == "An" || word == "The":
+		lines[0] = name + " renders " + strings.ToLower(word) + first[len(word):]
+	default:
+		lines[0] = name + ": " + first
+	}
+	return lines
+}
+
+// Returns comment lines with their list items written the way gofmt writes the
+// items of a Go doc list, whatever their indentation and marker, so that go doc
+// doesn't take them for a code block or for text.  The lines that continue an
+// item are indented along with it.
+func listLines(lines []string) []string {
+	result := make([]string, len(lines))
+	// The indentation of the marker of the current item, or -1 outside of a
+	// list.
+	item := -1
+	for i, line := range lines {
+		trimmed := strings.TrimLeft(line, " \t")
+		indent := len(line) - len(trimmed)
+		marker := listMarker(trimmed)
+		switch {
+		case line == "":
+			item = -1
+		case marker != "":
+			line = "  " + marker + " " + strings.TrimSpace(trimmed[len(marker):])
+			item = indent
+		case item >= 0 && indent > item:
+			line = "    " + trimmed
+		default:
+			item = -1
+		}
+		result[i] = line
+	}
+	return result
+}
+
+// Returns the marker a list item starts with, which is "-", "*", "+" or a
+// number followed by "." or ")", or "" if the line isn't a list item.
+func listMarker(line string) string {
+	for _, bullet := range []string{"- ", "* ", "+ "} {
+		if strings.HasPrefix(line, bullet) {
+			return bullet[:1]
+		}
+	}
+	digits := len(line) - len(strings.TrimLeft(line, "0123456789"))
+	if digits > 0 && (strings.HasPrefix(line[digits:], ". ") || strings.HasPrefix(line[digits:], ") ")) {
+		return line[:digits+1]
+	}
+	return ""
+}
+
+// Writes the comment before a template as the doc comment of its function.
+func (G *Generator) genDocComment(template parser.Template2) {
+	if template.Comment == nil {
+		return
+	}
+	G.writeComment(docLines(template.Name.Str, template.Comment.Text))
+}
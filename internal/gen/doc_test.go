@@ -0,0 +1,71 @@
+package gen
+
+import (
+	"go/ast"
+	"go/doc"
+	"go/doc/comment"
+	goparser "go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+// Returns the blocks go doc finds in the doc comment generated for a template.
+func docBlocks(t *testing.T, name string, text string) []comment.Block {
+	src := "package views\n\n"
+	for _, line := range docLines(name, text) {
+		src += strings.TrimRight("// "+line, " ") + "\n"
+	}
+	src += "func " + name + "() {}\n"
+	fset := token.NewFileSet()
+	file, err := goparser.ParseFile(fset, "views.go", src, goparser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := doc.NewFromFiles(fset, []*ast.File{file}, "example.com/views")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pkg.Funcs) != 1 {
+		t.Fatalf("Expected a single function, got %d", len(pkg.Funcs))
+	}
+	return pkg.Parser().Parse(pkg.Funcs[0].Doc).Content
+}
+
+func TestDocComment(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		synopsis string
+		items    int
+	}{
+		{"boxed", "****************************\n * A card with a title.     *\n *                          *\n * Parameters:              *\n *   - title: the heading   *\n *   - body: the content    *\n ****************************", "Card renders a card with a title.", 2},
+		{"unindented list", " The card.\n Parameters:\n - title: the heading,\n   which is escaped\n * body: the content ", "Card renders the card.", 2},
+		{"numbered list", "Card shows, in order:\n\n  1. the title\n  2. the body", "Card shows, in order:", 2},
+		{"no article", "Shows a card.", "Card: Shows a card.", 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			blocks := docBlocks(t, "Card", test.text)
+			p, ok := blocks[0].(*comment.Paragraph)
+			if !ok {
+				t.Fatalf("Expected the comment to start with a paragraph, got %T", blocks[0])
+			}
+			if text, _ := p.Text[0].(comment.Plain); !strings.HasPrefix(string(text), test.synopsis) {
+				t.Fatalf("Expected the comment to start with `%s`, got `%v`", test.synopsis, p.Text[0])
+			}
+			items := 0
+			for _, block := range blocks {
+				switch b := block.(type) {
+				case *comment.Code:
+					t.Fatalf("Expected no code blocks, got `%s`", b.Text)
+				case *comment.List:
+					items += len(b.Items)
+				}
+			}
+			if items != test.items {
+				t.Fatalf("Expected %d list items, got %d", test.items, items)
+			}
+		})
+	}
+}
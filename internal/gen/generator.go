@@ -1,9 +1,17 @@
 package gen
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"go/ast"
+	"go/format"
+	goparser "go/parser"
+	"go/scanner"
+	"go/token"
 	"io"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/gamebox/gwirl/internal/parser"
@@ -19,10 +27,50 @@ type Generator struct {
 	indentLevel int
 	indentStyle string
 	writer      io.Writer
+	streaming   bool
+	// Whether the streaming variant of a template is being written, whose
+	// calls to other templates can write to the same writer.
+	writing    bool
+	escape     bool
+	filetype   string
+	sourcePath string
+	outputName string
+	signatures map[string]string
+	// The templates of the package that failed to parse, whose signatures
+	// aren't known.
+	unparsed map[string]bool
+	// The names looked up in the signatures while generating, which are the
+	// templates the generated code depends on.
+	calls        map[string]bool
+	packages     map[string]string
+	declarations map[string]bool
+	ctx          htmlContext
+}
+
+// Error is a problem with a template that is found while generating its code.
+// The line is 1-based and the column is 0-based, like the positions of the
+// trees the template is parsed into.
+type Error struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column+1, e.Message)
+}
+
+// ErrUnparsedCallee is returned when a template passes named slots to a template
+// of the package that failed to parse, since the slots can't be bound to its
+// parameters until its errors are fixed.
+var ErrUnparsedCallee = errors.New("The called template failed to parse")
+
+func errorAt(tree parser.TemplateTree2, format string, args ...any) *Error {
+	return &Error{Line: tree.Line(), Column: tree.Column(), Message: fmt.Sprintf(format, args...)}
 }
 
 func NewGenerator(useTabs bool) Generator {
-	g := Generator{}
+	g := Generator{filetype: "html"}
 	if useTabs {
 		g.indentStyle = tabIndent
 	} else {
@@ -31,65 +79,377 @@ func NewGenerator(useTabs bool) Generator {
 	return g
 }
 
+// When streaming is enabled, every template also gets a Write<Name> variant that
+// renders straight to an io.Writer instead of building a string.
+func (G *Generator) SetStreaming(streaming bool) {
+	G.streaming = streaming
+}
+
+// When escaping by default, bare `@expr` expressions are escaped as if they
+// were written `@!expr`, and only `@raw(expr)` is output as is.
+func (G *Generator) SetEscapeByDefault(escape bool) {
+	G.escape = escape
+}
+
+// Sets the type of content the template produces, one of "html", "xml", "md",
+// or "txt".  This decides how escaped expressions are escaped.
+func (G *Generator) SetFiletype(filetype string) {
+	G.filetype = filetype
+}
+
+// Sets the path of the template being generated, relative to the directory the
+// generated file is written to, and the name of the generated file.  When set,
+// line directives are written so that compiler errors and panics point at the
+// template instead of the generated code, for the code that comes from the
+// template, and at the generated file for the rest.
+func (G *Generator) SetSourcePath(path string, outputName string) {
+	G.sourcePath = path
+	G.outputName = outputName
+}
+
+// Sets the parameters of the templates that can be called with named slots,
+// keyed by the name of the template.  These should be every template in the
+// same package as the template being generated.
+func (G *Generator) SetSignatures(signatures map[string]string) {
+	G.signatures = signatures
+}
+
+// Sets the names of the templates of the package that failed to parse, so that
+// calls to them aren't reported as calls to templates that don't exist.
+func (G *Generator) SetUnparsed(unparsed map[string]bool) {
+	G.unparsed = unparsed
+}
+
+// Returns the names of the templates the last generated template calls, whose
+// signatures changed its code, sorted.  This includes the names that were not
+// found in the signatures, since adding a template with that name changes the
+// code too.
+func (G *Generator) Calls() []string {
+	calls := make([]string, 0, len(G.calls))
+	for name := range G.calls {
+		calls = append(calls, name)
+	}
+	sort.Strings(calls)
+	return calls
+}
+
+func (G *Generator) signature(name string) (string, bool) {
+	if G.calls == nil {
+		G.calls = map[string]bool{}
+	}
+	G.calls[name] = true
+	signature, ok := G.signatures[name]
+	return signature, ok
+}
+
+// Sets the packages of the module the template is generated in, keyed by their
+// names.  When the code of a template uses one of these packages without
+// importing it, it is imported automatically, like the packages of the
+// standard library are.
+func (G *Generator) SetPackages(packages map[string]string) {
+	G.packages = packages
+}
+
+// Sets the names declared at the top level of the package the template is
+// generated in by the Go files that aren't generated from templates.  Since the
+// code of a template can use them, they are never taken to be packages.
+func (G *Generator) SetDeclarations(declarations map[string]bool) {
+	G.declarations = declarations
+}
+
+// The comments that mark where the positions of the generated file should be
+// restored after code from the template.  They are replaced with line
+// directives once the code is formatted, since the lines of the generated file
+// are only known then.
+const (
+	restoreComment     = "/*gwirl:restore*/"
+	restoreLineComment = "//gwirl:restore"
+)
+
+// Returns the code of a Go block with an inline line directive before each of
+// its statements.  gofmt spreads the statements of one-line blocks over several
+// lines, so a directive for the whole block would map the statements after them
+// to the wrong lines.  Code that can't be parsed gets a directive before the
+// first token of each of its lines instead, to report where it is invalid.
+func (G *Generator) mapStatements(tree parser.TemplateTree2) string {
+	if G.sourcePath == "" || tree.Line() == 0 {
+		return tree.Text
+	}
+	src := []byte(tree.Text)
+	offsets := map[int]bool{}
+	statements, ok := statementOffsets(tree.Text)
+	for _, offset := range statements {
+		offsets[offset] = true
+	}
+	if !ok {
+		file := token.NewFileSet().AddFile("", -1, len(src))
+		s := scanner.Scanner{}
+		s.Init(file, src, nil, 0)
+		prevLine := 0
+		for {
+			pos, tok, lit := s.Scan()
+			if tok == token.EOF {
+				break
+			}
+			// Semicolons inserted at the ends of lines aren't in the code.
+			if tok == token.SEMICOLON && lit == "\n" {
+				continue
+			}
+			if line := file.Line(pos); line != prevLine {
+				offsets[file.Offset(pos)] = true
+				prevLine = line
+			}
+		}
+	}
+
+	sb := strings.Builder{}
+	last := 0
+	for offset := range src {
+		// The braces of the block itself are left out of the generated code.
+		if !offsets[offset] || offset == 0 || offset == len(src)-1 {
+			continue
+		}
+		line := tree.Line() + strings.Count(tree.Text[:offset], "\n")
+		column := offset - strings.LastIndexByte(tree.Text[:offset], '\n') - 1
+		if line == tree.Line() {
+			column += tree.Column()
+		}
+		// The directive maps the space gofmt puts after it, and a directive
+		// can't give column 0, so code at the start of a line of the template
+		// is one column off.
+		if column == 0 {
+			column = 1
+		}
+		sb.Write(src[last:offset])
+		sb.WriteString(fmt.Sprintf("/*line %s:%d:%d*/ ", G.sourcePath, line, column))
+		last = offset
+	}
+	sb.Write(src[last:])
+	return sb.String()
+}
+
+// Returns the offsets of the statements of a Go block, and of the closing braces
+// of the blocks in it, leaving out the statements in the headers of other
+// statements.  Reports false if the block can't be parsed.
+func statementOffsets(block string) ([]int, bool) {
+	prefix := "package p\nfunc _() "
+	fset := token.NewFileSet()
+	file, err := goparser.ParseFile(fset, "", prefix+block, 0)
+	if err != nil {
+		return nil, false
+	}
+	base := fset.File(file.Pos()).Base() + len(prefix)
+	offsets := []int{}
+	header := map[ast.Node]bool{}
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.IfStmt:
+			header[n.Init] = true
+			header[n.Else] = true
+		case *ast.ForStmt:
+			header[n.Init] = true
+			header[n.Post] = true
+		case *ast.SwitchStmt:
+			header[n.Init] = true
+		case *ast.TypeSwitchStmt:
+			header[n.Init] = true
+			header[n.Assign] = true
+		case *ast.CommClause:
+			header[n.Comm] = true
+		case *ast.LabeledStmt:
+			header[n.Stmt] = true
+		}
+		switch n := n.(type) {
+		case *ast.BlockStmt:
+			offsets = append(offsets, int(n.Rbrace)-base)
+		case ast.Stmt:
+			if !header[n] {
+				offsets = append(offsets, int(n.Pos())-base)
+			}
+		}
+		return true
+	})
+	return offsets, true
+}
+
+// Places the line directives of Go blocks once the code is formatted.  gofmt
+// leaves a directive at the end of a line when it moves the statement after it
+// to a line of its own, so the directive is moved to the start of that line.
+// gofmt doesn't keep a comment in front of a statement that follows a blank
+// line, so a directive at the start of a line becomes a //line directive on
+// the line before, giving the column of the indentation that the statement
+// follows, when that column is in the line of the template.
+func (G *Generator) placeDirectives(lines []string) []string {
+	prefix := "/*line " + G.sourcePath + ":"
+	result := make([]string, 0, len(lines))
+	pending := ""
+	for _, line := range lines {
+		code := strings.TrimLeft(line, " \t")
+		if pending != "" && code != "" {
+			if !strings.HasPrefix(code, "/*line ") {
+				line = line[:len(line)-len(code)] + pending + " " + code
+			}
+			pending = ""
+		}
+		trimmed := strings.TrimRight(line, " \t")
+		idx := strings.LastIndex(trimmed, prefix)
+		if idx >= 0 && strings.HasSuffix(trimmed, "*/") && !strings.Contains(trimmed[idx:len(trimmed)-2], "*/") {
+			pending = trimmed[idx:]
+			line = strings.TrimRight(trimmed[:idx], " \t")
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+		}
+		code = strings.TrimLeft(line, " \t")
+		indent := len(line) - len(code)
+		end := strings.Index(code, "*/")
+		if strings.HasPrefix(code, prefix) && !strings.Contains(code, restoreComment) {
+			position := code[len(prefix):end]
+			if cut := strings.LastIndex(position, ":"); cut > 0 {
+				column, err := strconv.Atoi(position[cut+1:])
+				// The directive gives the column of the space before the
+				// statement.
+				if err == nil && column+1-indent >= 1 {
+					result = append(result, fmt.Sprintf("//line %s:%s:%d", G.sourcePath, position[:cut], column+1-indent))
+					line = line[:indent] + strings.TrimLeft(code[end+len("*/"):], " ")
+				}
+			}
+		}
+		result = append(result, line)
+	}
+	return result
+}
+
+// Returns Go code from the template preceded by an inline line directive that
+// maps it to the given position in the template, and followed by a comment that
+// restores the position in the generated file.  Since gofmt always puts a
+// single space between a comment and the code after it, the leading whitespace
+// of the code is replaced by that space, and the directive maps the space.
+func (G *Generator) mapped(line int, column int, code string) string {
+	if G.sourcePath == "" || line == 0 {
+		return code
+	}
+	trimmed := strings.TrimLeft(code, " \t")
+	column += len(code) - len(trimmed)
+	trimmed = strings.TrimRight(trimmed, " \t")
+	return fmt.Sprintf("/*line %s:%d:%d*/ %s %s", G.sourcePath, line, column, trimmed, restoreComment)
+}
+
+// Replaces the comments that restore the positions of the generated file with
+// line directives for the lines and columns they are on.
+func (G *Generator) restorePositions(code []byte) []byte {
+	lines := G.placeDirectives(strings.Split(string(code), "\n"))
+	for i, line := range lines {
+		if strings.TrimSpace(line) == restoreLineComment {
+			// A //line directive gives the line of the line after it.
+			lines[i] = fmt.Sprintf("//line %s:%d:1", G.outputName, i+2)
+			continue
+		}
+		for {
+			idx := strings.Index(line, restoreComment)
+			if idx < 0 {
+				break
+			}
+			// An inline directive gives the 1-based column of the character
+			// right after it, which depends on the length of the directive.
+			prefix := fmt.Sprintf("/*line %s:%d:", G.outputName, i+1)
+			column := idx + len(prefix) + len("*/") + 1
+			for column != idx+len(prefix)+len(strconv.Itoa(column))+len("*/")+1 {
+				column = idx + len(prefix) + len(strconv.Itoa(column)) + len("*/") + 1
+			}
+			directive := prefix + strconv.Itoa(column) + "*/"
+			line = line[:idx] + directive + line[idx+len(restoreComment):]
+		}
+		lines[i] = line
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// Returns the code of an expression mapped to its position in the template.
+// gofmt moves a comment that follows a comma in front of the comma, where the
+// directive would map the wrong code, so an expression that is an argument
+// after another one is put in parentheses.
+func (G *Generator) mappedExpression(tree parser.TemplateTree2, start string, code string) string {
+	mapped := G.mapped(tree.Line(), tree.Column(), code)
+	if mapped != code && strings.HasSuffix(start, ", ") {
+		return "(" + mapped + ")"
+	}
+	return mapped
+}
+
 func (G *Generator) GenTemplateTree(tree parser.TemplateTree2) error {
 	switch tree.Type {
 	case parser.TT2Plain:
+		switch G.filetype {
+		case "html":
+			G.ctx = G.ctx.advance(tree.Text)
+		case "xml":
+			G.ctx = G.ctx.advanceXML(tree.Text)
+		}
 		G.write("sb_.WriteString(`")
 		G.writeNoIndent(tree.Text)
 		G.writeNoIndent("`)")
 		G.newlines()
 	case parser.TT2BlockComment:
-		G.write("/")
-		for i, line := range strings.Split(tree.Text, "\n") {
-			if i == 0 {
-				G.writeNoIndent(line)
-			}
-			G.write(line)
-		}
-		G.writeNoIndent("/")
-		G.newlines()
+		G.genComment(tree.Text)
+		G.writer.Write(newline)
 	case parser.TT2GoBlock:
-		cleanedText := strings.TrimLeft(tree.Text, "{")
-		cleanedText = strings.TrimLeft(cleanedText, "\n")
-		cleanedText = strings.TrimRight(cleanedText, "}")
-		cleanedText = strings.TrimLeft(cleanedText, "\n")
+		cleanedText := G.mapStatements(tree)
+		mapped := cleanedText != tree.Text
+		cleanedText = strings.TrimPrefix(cleanedText, "{")
+		cleanedText = strings.TrimSuffix(cleanedText, "}")
+		cleanedText = strings.Trim(cleanedText, "\n")
 		for _, line := range strings.Split(cleanedText, "\n") {
 			G.write(strings.TrimLeft(line, " \t"))
 			G.write("\n")
 		}
+		if mapped {
+			G.writeln(restoreLineComment)
+		}
 		G.newlines()
 	case parser.TT2If:
 		G.write("if ")
-		G.writeNoIndent(tree.Text)
+		G.writeNoIndent(G.mapped(tree.Line(), tree.Column()+2, tree.Text))
 		G.writeNoIndent(" {\n")
 		G.indent()
+		// Every branch starts in the context before the if, and the context of
+		// the main block is the one that continues after it.
+		ctx := G.ctx
 		// Content of main block in tree.Children[0]
 		if len(tree.Children) > 0 {
-			for _, child := range tree.Children[0] {
-				G.GenTemplateTree(child)
+			if err := G.genContent(tree.Children[0]); err != nil {
+				return err
 			}
 		}
+		ctxAfter := G.ctx
 		G.dedent()
 		G.write("}")
 		// Else ifs in tree.Children[1]
 		if len(tree.Children) > 1 {
 			for _, elseIf := range tree.Children[1] {
-				G.GenTemplateTree(elseIf)
+				G.ctx = ctx
+				if err := G.GenTemplateTree(elseIf); err != nil {
+					return err
+				}
 			}
 		}
 		// Else is tree.Children[2][0]
 		if len(tree.Children) > 2 && len(tree.Children[2]) > 0 {
-			G.GenTemplateTree(tree.Children[2][0])
+			G.ctx = ctx
+			if err := G.GenTemplateTree(tree.Children[2][0]); err != nil {
+				return err
+			}
 		}
+		G.ctx = ctxAfter
 		G.newlines()
 	case parser.TT2ElseIf:
 		G.writeNoIndent(" else if ")
-		G.writeNoIndent(tree.Text)
+		G.writeNoIndent(G.mapped(tree.Line(), tree.Column()+7, tree.Text))
 		G.writeNoIndent(" {\n")
 		G.indent()
 		if len(tree.Children) > 0 {
-			for _, child := range tree.Children[0] {
-				G.GenTemplateTree(child)
+			if err := G.genContent(tree.Children[0]); err != nil {
+				return err
 			}
 		}
 		G.dedent()
@@ -98,82 +458,401 @@ func (G *Generator) GenTemplateTree(tree parser.TemplateTree2) error {
 		G.writeNoIndent(" else {\n")
 		G.indent()
 		if len(tree.Children) > 0 {
-			for _, child := range tree.Children[0] {
-				G.GenTemplateTree(child)
+			if err := G.genContent(tree.Children[0]); err != nil {
+				return err
 			}
 		}
 		G.dedent()
 		G.write("}")
+	case parser.TT2Switch:
+		G.write("switch ")
+		G.writeNoIndent(G.mapped(tree.Line(), tree.Column()+6, tree.Text))
+		G.writeNoIndent(" {\n")
+		// Like the branches of an if, every case starts in the context before
+		// the switch, and the context of the first case continues after it.
+		ctx := G.ctx
+		ctxAfter := ctx
+		if len(tree.Children) > 0 {
+			for i, c := range tree.Children[0] {
+				G.ctx = ctx
+				if err := G.GenTemplateTree(c); err != nil {
+					return err
+				}
+				if i == 0 {
+					ctxAfter = G.ctx
+				}
+			}
+		}
+		G.ctx = ctxAfter
+		G.write("}")
+		G.newlines()
+	case parser.TT2Case:
+		G.write("case ")
+		G.writeNoIndent(G.mapped(tree.Line(), tree.Column()+4, tree.Text))
+		G.writeNoIndent(":\n")
+		G.indent()
+		if len(tree.Children) > 0 {
+			if err := G.genContent(tree.Children[0]); err != nil {
+				return err
+			}
+		}
+		G.dedent()
+	case parser.TT2Default:
+		G.write("default:\n")
+		G.indent()
+		if len(tree.Children) > 0 {
+			if err := G.genContent(tree.Children[0]); err != nil {
+				return err
+			}
+		}
+		G.dedent()
+	case parser.TT2TemplateDef:
+		name, typeParams, params := tree.Declaration()
+		if parser.HasDefaults(parser.ParseParams(params)) {
+			return errorAt(tree, "Parameters of %s can't have default values, only templates defined at the top of the file can", name)
+		}
+		if typeParams != "" {
+			return errorAt(tree, "%s can't have type parameters, only templates defined at the top of the file can", name)
+		}
+		// Declaring the variable first lets the template call itself.
+		G.write("var ")
+		G.writeNoIndent(name)
+		G.writeNoIndent(" func")
+		G.writeNoIndent(G.mapped(tree.Line(), tree.Column()+len(name), params))
+		G.writeNoIndent(" gwirl.HTML\n")
+		G.write(name)
+		G.writeNoIndent(" = func")
+		G.writeNoIndent(G.mapped(tree.Line(), tree.Column()+len(name), params))
+		G.writeNoIndent(" gwirl.HTML {\n")
+		G.indent()
+		G.write("sb_ := gwirl.TemplateBuilder{}\n")
+		// Like transclusions, defined templates are rendered on their own.
+		ctx := G.ctx
+		G.ctx = htmlContext{}
+		if len(tree.Children) > 0 {
+			if err := G.genContent(tree.Children[0]); err != nil {
+				return err
+			}
+		}
+		G.ctx = ctx
+		G.write("return sb_.HTML()\n")
+		G.dedent()
+		G.write("}\n")
+		G.write("_ = ")
+		G.writeNoIndent(name)
+		G.writeNoIndent("\n")
+		G.newlines()
 	case parser.TT2For:
 		G.write("for ")
-		G.writeNoIndent(tree.Text)
+		G.writeNoIndent(G.mapped(tree.Line(), tree.Column()+3, tree.Text))
 		G.writeNoIndent(" {\n")
 		G.indent()
 		// Content of main block in tree.Children[0]
 		if len(tree.Children) > 0 {
-			for _, child := range tree.Children[0] {
-				G.GenTemplateTree(child)
+			if err := G.genContent(tree.Children[0]); err != nil {
+				return err
 			}
 		}
 		G.dedent()
 		G.write("}")
 		G.newlines()
 	case parser.TT2GoExp:
+		if G.escapes(tree) && G.filetype == "html" && G.ctx.inTag() {
+			return errorAt(tree, "%s can't be escaped here, escaped values can only be output in text and attribute values, not as the name of a tag or attribute", tree.Text)
+		}
 		if len(tree.Children) > 0 {
-			transclusionParams := strings.Builder{}
-			for i, transclusion := range tree.Children {
-				varName := fmt.Sprintf("transclusion__%d__%d__%d", tree.Line(), tree.Column(), i)
-				if i > 0 {
-					transclusionParams.WriteString(", ")
-				}
-				transclusionParams.WriteString(varName)
-				G.write("var ")
-				G.writeNoIndent(varName)
-				G.writeNoIndent(" string\n")
-				G.write("{\n")
-				G.indent()
-				G.write("sb_ := gwirl.TemplateBuilder{}\n")
-				for _, child := range transclusion {
-					G.GenTemplateTree(child)
-				}
-				G.write(varName)
-				G.writeNoIndent(" = sb_.String()\n")
-				G.dedent()
-				G.write("}\n")
-			}
-			if tree.Metadata.Has(parser.TTMDEscape) {
-				fmt.Printf("GoExp %v\n", tree)
-				G.write("gwirl.WriteEscapedHTML(&sb_, ")
-			} else {
-				G.write("gwirl.WriteRawHTML(&sb_, ")
-			}
-			transclusionParamsStr := transclusionParams.String()
-			if strings.HasSuffix(tree.Text, "()") {
-				text, _ := strings.CutSuffix(tree.Text, ")")
-				text = text + transclusionParamsStr + ")"
-				G.writeNoIndent(text)
-			} else if strings.HasSuffix(tree.Text, ")") {
-				text, _ := strings.CutSuffix(tree.Text, ")")
-				text = text + ", " + transclusionParamsStr + ")"
-				G.writeNoIndent(text)
-			} else {
+			if !strings.HasSuffix(tree.Text, ")") {
 				return errors.New("Transclusion can only occur with a method call")
 			}
-			G.writeNoIndent(")\n")
-		} else {
-			if tree.Metadata.Has(parser.TTMDEscape) {
-				G.write("gwirl.WriteEscapedHTML(&sb_, ")
+			var args, options []string
+			var err error
+			streams := G.streamsCall(tree)
+			if tree.Metadata.Has(parser.TTMDSlots) {
+				args, options, err = G.genSlots(tree, streams)
+			} else {
+				args, err = G.genTransclusions(tree, streams)
+			}
+			if err != nil {
+				return err
+			}
+			text := tree.Text
+			if streams {
+				text = G.contentCall(text)
+			}
+			call := callText(text, args, options, G.calleeOptions(tree.Text))
+			if streams {
+				G.write(G.mapped(tree.Line(), tree.Column(), writeCall(call)))
+				G.writeNoIndent("\n")
 			} else {
-				G.write("gwirl.WriteRawHTML(&sb_, ")
+				start, end := G.expressionWrapper(tree)
+				G.write(start)
+				G.writeNoIndent(G.mappedExpression(tree, start, call))
+				G.writeNoIndent(end)
+				G.writeNoIndent("\n")
 			}
-			G.writeNoIndent(tree.Text)
-			G.writeNoIndent(")")
+		} else {
+			start, end := G.expressionWrapper(tree)
+			G.write(start)
+			G.writeNoIndent(G.mappedExpression(tree, start, tree.Text))
+			G.writeNoIndent(end)
 		}
+		G.ctx = G.ctx.afterValue()
 		G.newlines()
 	}
 	return nil
 }
 
+// Writes a variable holding the rendered content of a transclusion.
+func (G *Generator) genTransclusion(varName string, content []parser.TemplateTree2) error {
+	G.write("var ")
+	G.writeNoIndent(varName)
+	G.writeNoIndent(" gwirl.HTML\n")
+	G.write("{\n")
+	G.indent()
+	G.write("sb_ := gwirl.TemplateBuilder{}\n")
+	// Transclusions are rendered on their own, so they start out as regular
+	// HTML content.
+	ctx := G.ctx
+	G.ctx = htmlContext{}
+	if err := G.genContent(content); err != nil {
+		return err
+	}
+	G.ctx = ctx
+	G.write(varName)
+	G.writeNoIndent(" = sb_.HTML()\n")
+	G.dedent()
+	G.write("}\n")
+	return nil
+}
+
+// Writes a variable holding a transclusion as gwirl.Content that renders it
+// when it is written, which is how transclusions are passed to the streaming
+// variant of a template.
+func (G *Generator) genContentFunc(varName string, content []parser.TemplateTree2) error {
+	G.write(varName)
+	G.writeNoIndent(" := gwirl.ContentFunc(func(w_ io.Writer) error {\n")
+	G.indent()
+	G.write("sb_ := gwirl.TemplateWriter{Writer: w_}\n")
+	ctx := G.ctx
+	G.ctx = htmlContext{}
+	if err := G.genContent(content); err != nil {
+		return err
+	}
+	G.ctx = ctx
+	G.write("return sb_.Err()\n")
+	G.dedent()
+	G.write("})\n")
+	return nil
+}
+
+// Writes the transclusions of a call and returns the variables to append to
+// its arguments, in the order the transclusions were written.  When the call
+// streams, the transclusions are only rendered once the called template writes
+// them.
+func (G *Generator) genTransclusions(tree parser.TemplateTree2, streams bool) ([]string, error) {
+	args := make([]string, 0, len(tree.Children))
+	for i, transclusion := range tree.Children {
+		varName := fmt.Sprintf("transclusion__%d__%d__%d", tree.Line(), tree.Column(), i)
+		gen := G.genTransclusion
+		if streams {
+			gen = G.genContentFunc
+		}
+		if err := gen(varName, transclusion); err != nil {
+			return nil, err
+		}
+		args = append(args, varName)
+	}
+	return args, nil
+}
+
+// Writes the named slots of a call and returns the arguments to append to it,
+// in the order of the parameters of the template being called, along with the
+// options for the slots of optional parameters.  Required parameters without a
+// slot are given empty content.  When the call streams, the slots of required
+// parameters are only rendered once the called template writes them, while the
+// slots of optional parameters are still rendered up front, since options are
+// shared with the function that returns HTML.
+func (G *Generator) genSlots(tree parser.TemplateTree2, streams bool) ([]string, []string, error) {
+	callee, argList, _ := strings.Cut(strings.TrimSuffix(tree.Text, ")"), "(")
+	name, typeArgs := calleeName(callee)
+	signature, ok := G.signature(name)
+	if !ok && G.unparsed[name] {
+		return nil, nil, fmt.Errorf("%w: %s", ErrUnparsedCallee, name)
+	}
+	if !ok {
+		return nil, nil, errorAt(tree, "Named slots can only be passed to templates in the same package, %s was not found", name)
+	}
+	params := parser.ParseParams(signature)
+	funcs := optionFuncs(name, signature)
+	positional, givenOptions := callArgs(callee, argList, funcs)
+	given := len(positional)
+	slots := map[string]parser.TemplateTree2{}
+	for _, slot := range tree.Children[0] {
+		idx := -1
+		for i, param := range params {
+			if param.Name == slot.Text {
+				idx = i
+				break
+			}
+		}
+		switch {
+		case idx < 0:
+			return nil, nil, errorAt(slot, "%s has no parameter named %s", name, slot.Text)
+		case idx < given:
+			return nil, nil, errorAt(slot, "Parameter %s of %s is already passed as an argument", slot.Text, name)
+		case isPassedOption(name, params[idx].Name, givenOptions, funcs):
+			return nil, nil, errorAt(slot, "Parameter %s of %s is already passed as an argument", slot.Text, name)
+		case params[idx].Type != "gwirl.HTML":
+			return nil, nil, errorAt(slot, "Parameter %s of %s is a %s, slots can only be passed to gwirl.HTML parameters", slot.Text, name, params[idx].Type)
+		}
+		slots[slot.Text] = slot
+	}
+	args := []string{}
+	options := []string{}
+	if given > len(params) {
+		given = len(params)
+	}
+	for _, param := range params[given:] {
+		slot, ok := slots[param.Name]
+		switch {
+		case ok && param.Default != "":
+			varName := fmt.Sprintf("slot__%d__%d__%s", tree.Line(), tree.Column(), param.Name)
+			if err := G.genTransclusion(varName, slot.Children[0]); err != nil {
+				return nil, nil, err
+			}
+			options = append(options, optionFunc(name, param.Name)+typeArgs+"("+varName+")")
+		case param.Default != "":
+		case ok:
+			varName := fmt.Sprintf("slot__%d__%d__%s", tree.Line(), tree.Column(), param.Name)
+			gen := G.genTransclusion
+			if streams {
+				gen = G.genContentFunc
+			}
+			if err := gen(varName, slot.Children[0]); err != nil {
+				return nil, nil, err
+			}
+			args = append(args, varName)
+		case param.Type == "gwirl.HTML" && streams:
+			args = append(args, "gwirl.HTML(\"\")")
+		case param.Type == "gwirl.HTML":
+			args = append(args, "\"\"")
+		default:
+			return nil, nil, errorAt(tree, "Missing argument %s for %s", param.Name, name)
+		}
+	}
+	return args, options, nil
+}
+
+// Reports whether the option for a parameter is one of the options passed to a
+// call.
+func isPassedOption(name string, param string, options []string, funcs map[string]string) bool {
+	for _, option := range options {
+		if set, ok := optionArg(name, option, funcs); ok && set == param {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the option functions of the template a call calls, or nil when the
+// template is not one of the package.
+func (G *Generator) calleeOptions(text string) map[string]string {
+	callee, _, _ := strings.Cut(text, "(")
+	name, _ := calleeName(callee)
+	signature, ok := G.signature(name)
+	if !ok {
+		return nil
+	}
+	return optionFuncs(name, signature)
+}
+
+// Reports whether the value of an expression is escaped when it is output.
+func (G *Generator) escapes(tree parser.TemplateTree2) bool {
+	return tree.Metadata.Has(parser.TTMDEscape) || (G.escape && !tree.Metadata.Has(parser.TTMDRaw))
+}
+
+// Reports whether a call with transclusions can be written as a call to the
+// streaming variant of the template it calls, which renders straight to the
+// writer instead of returning its content.  This is only possible for the
+// templates of the same package, and where the content the template returns
+// would have been written as is.
+func (G *Generator) streamsCall(tree parser.TemplateTree2) bool {
+	if !G.writing {
+		return false
+	}
+	callee, _, _ := strings.Cut(tree.Text, "(")
+	name, _ := calleeName(callee)
+	if _, ok := G.signature(name); !ok {
+		return false
+	}
+	if !G.escapes(tree) {
+		return true
+	}
+	switch G.filetype {
+	case "xml":
+		return !G.ctx.cdata
+	case "md", "txt":
+		return true
+	}
+	return G.ctx.state == stateText
+}
+
+// Returns a call to a template of the package with the string literals it passes
+// to gwirl.HTML parameters converted to gwirl.HTML, since the streaming variant
+// of the template takes gwirl.Content, which untyped strings can't be passed as.
+func (G *Generator) contentCall(text string) string {
+	callee, argList, _ := strings.Cut(strings.TrimSuffix(text, ")"), "(")
+	name, _ := calleeName(callee)
+	signature, ok := G.signature(name)
+	if !ok {
+		return text
+	}
+	params := parser.ParseParams(signature)
+	positional, options := callArgs(callee, argList, optionFuncs(name, signature))
+	changed := false
+	for i, arg := range positional {
+		if i < len(params) && params[i].Type == "gwirl.HTML" && (strings.HasPrefix(arg, "\"") || strings.HasPrefix(arg, "`")) {
+			positional[i] = "gwirl.HTML(" + arg + ")"
+			changed = true
+		}
+	}
+	if !changed {
+		return text
+	}
+	return callee + "(" + strings.Join(append(positional, options...), ", ") + ")"
+}
+
+// Returns a call of a template as a call of its streaming variant that writes
+// to sb_.
+func writeCall(call string) string {
+	callee, args, _ := strings.Cut(call, "(")
+	if strings.HasPrefix(args, ")") {
+		return "Write" + callee + "(&sb_" + args
+	}
+	return "Write" + callee + "(&sb_, " + args
+}
+
+// Returns the code to write before and after a Go expression to output its
+// value.  Escaped expressions are escaped for the filetype of the template and
+// the context they appear in.
+func (G *Generator) expressionWrapper(tree parser.TemplateTree2) (string, string) {
+	if !G.escapes(tree) {
+		return "gwirl.WriteRawHTML(&sb_, ", ")"
+	}
+	switch G.filetype {
+	case "xml":
+		if G.ctx.cdata {
+			return "gwirl.WriteEscapedCDATA(&sb_, ", ")"
+		}
+		return "gwirl.WriteEscapedXML(&sb_, ", ")"
+	case "md":
+		return "gwirl.WriteEscapedMarkdown(&sb_, ", ")"
+	case "txt":
+		return "gwirl.WriteEscapedText(&sb_, ", ")"
+	}
+	return G.ctx.escaper()
+}
+
 func (G *Generator) write(str string) {
 	indentation := strings.Repeat(G.indentStyle, G.indentLevel)
 	G.writer.Write([]byte(indentation + str))
@@ -200,45 +879,273 @@ func (G *Generator) dedent() {
 	G.indentLevel -= 1
 }
 
+func (G *Generator) genContent(content []parser.TemplateTree2) error {
+	for _, tree := range content {
+		err := G.GenTemplateTree(tree)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the imports of a template without the ones the generated code
+// already has, which are the gwirl package, and the io package when streaming.
+func (G *Generator) userImports(imports []parser.Import) []parser.Import {
+	result := make([]parser.Import, 0, len(imports))
+	for _, i := range imports {
+		name := i.Name.Str
+		switch path := i.PackagePath(); {
+		case path == "github.com/gamebox/gwirl" && (name == "" || name == "gwirl"):
+			continue
+		case path == "io" && G.streaming && (name == "" || name == "io"):
+			continue
+		}
+		result = append(result, i)
+	}
+	return result
+}
+
+// Returns the parameters of a template with gwirl.Content in place of
+// gwirl.HTML, for its streaming variant.
+func contentParams(params string) string {
+	parsed := parser.ParseParams(params)
+	changed := false
+	list := make([]string, len(parsed))
+	for i, param := range parsed {
+		if param.Type == "gwirl.HTML" {
+			param.Type = "gwirl.Content"
+			changed = true
+		}
+		list[i] = param.String()
+	}
+	if !changed {
+		return params
+	}
+	return "(" + strings.Join(list, ", ") + ")"
+}
+
+// Returns the required gwirl.HTML parameters of a template that its code does
+// more with than write, like comparing them or passing them on, which the
+// streaming variant of the template needs as gwirl.HTML rather than
+// gwirl.Content.
+func renderedParams(template parser.Template2) []string {
+	rendered := []string{}
+	for _, param := range parser.ParseParams(template.Params.Str) {
+		if param.Type == "gwirl.HTML" && param.Default == "" && !onlyWritten(template.Content, param.Name) {
+			rendered = append(rendered, param.Name)
+		}
+	}
+	return rendered
+}
+
+// Reports whether the code of some content uses a name only to write it, as in
+// `@body`.
+func onlyWritten(content []parser.TemplateTree2, name string) bool {
+	for _, tree := range content {
+		switch {
+		case tree.Type == parser.TT2Plain || tree.Type == parser.TT2BlockComment || tree.Type == parser.TT2LineComment || tree.Type == parser.TT2Slot:
+			// The text of these isn't Go code.
+		case tree.Type == parser.TT2GoExp && len(tree.Children) == 0 && strings.TrimSpace(tree.Text) == name:
+			// Content can be written as is.
+		case refersTo(tree.Text, name):
+			return false
+		}
+		for _, children := range tree.Children {
+			if !onlyWritten(children, name) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Reports whether Go code refers to a name, other than as the field or method
+// selected from a value.
+func refersTo(code string, name string) bool {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", -1, len(code))
+	s := scanner.Scanner{}
+	s.Init(file, []byte(code), nil, 0)
+	prev := token.ILLEGAL
+	for {
+		_, tok, lit := s.Scan()
+		if tok == token.EOF {
+			return false
+		}
+		if tok == token.IDENT && lit == name && prev != token.PERIOD {
+			return true
+		}
+		prev = tok
+	}
+}
+
+func streamingParams(params string) string {
+	inner := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(params, "("), ")"))
+	if inner == "" {
+		return "(w_ io.Writer)"
+	}
+	return "(w_ io.Writer, " + inner + ")"
+}
+
 func (G *Generator) Generate(template parser.Template2, pkg string, writer io.Writer) error {
-	G.writer = writer
+	// The templates are generated first, since the imports depend on the
+	// packages their code uses.
+	body := bytes.Buffer{}
+	G.writer = &body
+	G.calls = nil
+	err := G.genTemplate(template)
+	if err != nil {
+		return err
+	}
+	for _, t := range template.Templates {
+		G.newlines()
+		err = G.genTemplate(t)
+		if err != nil {
+			return err
+		}
+	}
 
+	output := bytes.Buffer{}
+	G.writer = &output
 	pkgLine := fmt.Sprintf("package %s\n\n", pkg)
 	G.write(pkgLine)
 
-	// Write imports
-	for _, i := range template.TopImports {
-		G.write(i.Str)
-		G.write("\n")
+	// Write imports, the standard library first and gwirl last
+	stdlib := []string{}
+	if G.streaming {
+		stdlib = append(stdlib, "\"io\"")
+	}
+	others := []string{}
+	for _, i := range G.resolveImports(template.TopImports, body.String()) {
+		if stdlibPath(i.PackagePath()) {
+			stdlib = append(stdlib, i.String())
+		} else {
+			others = append(others, i.String())
+		}
 	}
 	G.writeln("import (")
 	G.indent()
+	for _, group := range [][]string{stdlib, others} {
+		for _, i := range group {
+			G.writeln(i)
+		}
+		if len(group) > 0 {
+			G.writer.Write(newline)
+		}
+	}
 	G.writeln("\"github.com/gamebox/gwirl\"")
 	G.dedent()
 	G.writeln(")")
 
 	G.newlines()
+	output.Write(body.Bytes())
+
+	formatted, err := format.Source(output.Bytes())
+	if err != nil {
+		return G.formatError(err)
+	}
+	if G.sourcePath != "" {
+		formatted = G.restorePositions(formatted)
+	}
+	_, err = writer.Write(formatted)
+	return err
+}
 
-	// Write comment as doc comment
+// Returns the error for generated code that can't be formatted, which only
+// happens when the code of a template isn't valid Go.  The error points at the
+// template when the line directives place the problem in it.
+func (G *Generator) formatError(err error) error {
+	var list scanner.ErrorList
+	if errors.As(err, &list) && len(list) > 0 {
+		pos := list[0].Pos
+		if G.sourcePath != "" && pos.Filename == G.sourcePath {
+			return &Error{Line: pos.Line, Column: pos.Column - 1, Message: "Invalid Go code: " + list[0].Msg}
+		}
+	}
+	return fmt.Errorf("The generated code is not valid Go: %w", err)
+}
 
+// Writes the function for a template, along with its streaming variant when
+// streaming is enabled.
+func (G *Generator) genTemplate(template parser.Template2) error {
 	// Write Template boilerplate start
-	funcStart := fmt.Sprintf("func %s%s string {\n", template.Name.Str, template.Params.Str)
+	params := templateParams(template)
+	G.genDocComment(template)
+	funcStart := fmt.Sprintf("func %s%s%s gwirl.HTML {\n", template.Name.Str, template.TypeParams.Str, params)
 	G.write(funcStart)
 
 	G.indent()
+	G.genOptionsPrelude(template)
 	G.write("sb_ := gwirl.TemplateBuilder{}")
 	G.newlines()
 
 	// Write content
-	for _, tree := range template.Content {
-		G.GenTemplateTree(tree)
+	G.ctx = htmlContext{}
+	err := G.genContent(template.Content)
+	if err != nil {
+		return err
 	}
 
-	G.write("return sb_.String()\n")
+	G.write("return sb_.HTML()\n")
 	G.dedent()
 
 	// Write Template boilerplate end
 	G.writeln("}")
 
+	if G.streaming {
+		if err := G.genStreamingTemplate(template, params); err != nil {
+			return err
+		}
+	}
+	G.genOptions(template)
+	return nil
+}
+
+// Writes the streaming variant of the function for a template.
+func (G *Generator) genStreamingTemplate(template parser.Template2, params string) error {
+	G.newlines()
+	if template.Comment != nil {
+		G.writeln(fmt.Sprintf("// Write%s renders %s straight to a writer instead of returning it.", template.Name.Str, template.Name.Str))
+	}
+	funcStart := fmt.Sprintf("func Write%s%s%s error {\n", template.Name.Str, template.TypeParams.Str, streamingParams(contentParams(params)))
+	G.write(funcStart)
+
+	G.indent()
+	G.genOptionsPrelude(template)
+	G.write("sb_ := gwirl.TemplateWriter{Writer: w_}")
+	G.newlines()
+
+	// The content parameters the template does more with than write are
+	// rendered up front, under their own names.
+	rendered := renderedParams(template)
+	if len(rendered) > 0 {
+		G.write("{\n")
+		G.indent()
+		for _, name := range rendered {
+			G.write(fmt.Sprintf("%s := gwirl.Render(%s)\n", name, name))
+		}
+		G.writer.Write(newline)
+	}
+
+	G.ctx = htmlContext{}
+	G.writing = true
+	err := G.genContent(template.Content)
+	G.writing = false
+	if err != nil {
+		return err
+	}
+
+	if len(rendered) > 0 {
+		G.dedent()
+		G.write("}")
+		G.newlines()
+	}
+
+	G.write("return sb_.Err()\n")
+	G.dedent()
+
+	G.writeln("}")
+
 	return nil
 }
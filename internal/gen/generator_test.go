@@ -2,6 +2,11 @@ package gen
 
 import (
 	_ "embed"
+	"go/ast"
+	"go/importer"
+	goparser "go/parser"
+	"go/token"
+	"go/types"
 	"strings"
 	"testing"
 
@@ -17,6 +22,57 @@ var simple string
 //go:embed testdata/testAll_gwirl.go
 var testAll string
 
+//go:embed testdata/streaming_gwirl.go
+var streaming string
+
+//go:embed testdata/escaped_gwirl.go
+var escaped string
+
+//go:embed testdata/escapedXml_gwirl.go
+var escapedXml string
+
+//go:embed testdata/escapedMd_gwirl.go
+var escapedMd string
+
+//go:embed testdata/escapedTxt_gwirl.go
+var escapedTxt string
+
+//go:embed testdata/attributes_gwirl.go
+var attributes string
+
+//go:embed testdata/lines_gwirl.go
+var lines string
+
+//go:embed testdata/badge_gwirl.go
+var badge string
+
+//go:embed testdata/table_gwirl.go
+var table string
+
+//go:embed testdata/tableRow_gwirl.go
+var tableRow string
+
+//go:embed testdata/slots_gwirl.go
+var slots string
+
+//go:embed testdata/streamedContent_gwirl.go
+var streamedContent string
+
+//go:embed testdata/defaults_gwirl.go
+var defaults string
+
+//go:embed testdata/list_gwirl.go
+var list string
+
+//go:embed testdata/card_gwirl.go
+var card string
+
+//go:embed testdata/imports_gwirl.go
+var imports string
+
+//go:embed testdata/resolvedImports_gwirl.go
+var resolvedImports string
+
 type SimplePosition struct {
 	line   int
 	column int
@@ -38,10 +94,54 @@ func withPos(t *parser.TemplateTree2, pos SimplePosition) parser.TemplateTree2 {
 	return *t
 }
 
+// Marks a call as passing named slots, which the parser does when the content
+// of the call is made of slots.
+func withSlots(t parser.TemplateTree2) parser.TemplateTree2 {
+	t.Metadata.Set(parser.TTMDSlots)
+	return t
+}
+
+func withTypeParams(t parser.Template2, typeParams string) parser.Template2 {
+	t.TypeParams = parser.NewPosString(typeParams)
+	return t
+}
+
+func withTemplates(t parser.Template2, templates ...parser.Template2) parser.Template2 {
+	t.Templates = templates
+	return t
+}
+
+func escapedTemplate() parser.Template2 {
+	return parser.NewTemplate2(
+		parser.NewPosString("Escaped"),
+		nil,
+		parser.NewPosString("(name string)"),
+		[]parser.Import{},
+		[]parser.TemplateTree2{
+			parser.NewTT2Plain("<title>"),
+			parser.NewTT2GoExp("name", true, nil),
+			parser.NewTT2Plain("</title>\n"),
+		},
+	)
+}
+
+func filetype(filetype string) func(*Generator) {
+	return func(g *Generator) {
+		g.SetFiletype(filetype)
+	}
+}
+
+func streamingGenerator(g *Generator) {
+	g.SetStreaming(true)
+}
+
+var cardDocComment = parser.NewTT2BlockComment("****************************\n * A card with a title.     *\n *                          *\n * Parameters:              *\n *   - title: the heading   *\n ****************************")
+
 var tests = []struct {
-	filename string
-	template parser.Template2
-	expected string
+	filename  string
+	template  parser.Template2
+	configure func(*Generator)
+	expected  string
 }{
 	{
 		"testdata/simple_gwirl.go",
@@ -49,10 +149,10 @@ var tests = []struct {
 			parser.NewPosString("Testing"),
 			nil,
 			parser.NewPosString("(name string, index int)"),
-			[]parser.PosString{},
+			[]parser.Import{},
 			[]parser.TemplateTree2{
 				parser.NewTT2Plain("<div>\n\t"),
-				parser.NewTT2If("if index > 0", []parser.TemplateTree2{
+				parser.NewTT2If(" index > 0 ", []parser.TemplateTree2{
 					parser.NewTT2Plain("\n\t\t<hr />\n\t"),
 				}, nil, nil),
 				parser.NewTT2Plain("\n\t<h2>"),
@@ -60,6 +160,7 @@ var tests = []struct {
 				parser.NewTT2Plain("</h2>\n"),
 			},
 		),
+		nil,
 		simple,
 	},
 	{
@@ -68,7 +169,7 @@ var tests = []struct {
 			parser.NewPosString("TestAll"),
 			ptr(parser.NewTT2BlockComment("*****************\n* Test Component *\n*****************")),
 			parser.NewPosString("(name string, index int)"),
-			[]parser.PosString{},
+			[]parser.Import{},
 			[]parser.TemplateTree2{
 				parser.NewTT2Plain("<div "),
 				parser.NewTT2If(" index == 0 ", []parser.TemplateTree2{
@@ -105,16 +206,336 @@ var tests = []struct {
 				parser.NewTT2Plain("\n</div>\n"),
 			},
 		),
+		nil,
 		testAll,
 	},
+	{
+		"testdata/streaming_gwirl.go",
+		parser.NewTemplate2(
+			parser.NewPosString("Streaming"),
+			nil,
+			parser.NewPosString("(name string)"),
+			[]parser.Import{},
+			[]parser.TemplateTree2{
+				parser.NewTT2Plain("<h2>"),
+				parser.NewTT2GoExp("name", true, nil),
+				parser.NewTT2Plain("</h2>\n"),
+			},
+		),
+		streamingGenerator,
+		streaming,
+	},
+	{
+		"testdata/escaped_gwirl.go",
+		parser.NewTemplate2(
+			parser.NewPosString("Escaped"),
+			nil,
+			parser.NewPosString("(name string, body string)"),
+			[]parser.Import{},
+			[]parser.TemplateTree2{
+				parser.NewTT2Plain("<h2>"),
+				parser.NewTT2GoExp("name", false, nil),
+				parser.NewTT2Plain("</h2>\n"),
+				parser.NewTT2GoExpRaw("body", nil),
+			},
+		),
+		func(g *Generator) {
+			g.SetEscapeByDefault(true)
+		},
+		escaped,
+	},
+	{"testdata/escapedXml_gwirl.go", escapedTemplate(), filetype("xml"), escapedXml},
+	{"testdata/escapedMd_gwirl.go", escapedTemplate(), filetype("md"), escapedMd},
+	{"testdata/escapedTxt_gwirl.go", escapedTemplate(), filetype("txt"), escapedTxt},
+	{
+		"testdata/attributes_gwirl.go",
+		parser.NewTemplate2(
+			parser.NewPosString("Attributes"),
+			nil,
+			parser.NewPosString("(class string, name string)"),
+			[]parser.Import{},
+			[]parser.TemplateTree2{
+				parser.NewTT2Plain("<div class="),
+				parser.NewTT2GoExp("class", true, nil),
+				parser.NewTT2Plain(" id=\"a\">\n<script>// don't\nvar a = "),
+				parser.NewTT2GoExp("name", true, nil),
+				parser.NewTT2Plain(";</script>\n</div>\n"),
+			},
+		),
+		nil,
+		attributes,
+	},
+	{
+		"testdata/lines_gwirl.go",
+		parser.NewTemplate2(
+			parser.NewPosString("Lines"),
+			nil,
+			parser.NewPosString("(name string, index int)"),
+			[]parser.Import{},
+			[]parser.TemplateTree2{
+				withPos(ptr(parser.NewTT2GoBlock("{\n    x := 1\n}")), SimplePosition{3, 1}),
+				withPos(ptr(parser.NewTT2If(" index > 0 ", []parser.TemplateTree2{
+					parser.NewTT2Plain("<hr />"),
+				}, nil, nil)), SimplePosition{6, 1}),
+				withPos(ptr(parser.NewTT2GoExp("name", false, nil)), SimplePosition{7, 5}),
+			},
+		),
+		func(g *Generator) {
+			g.SetSourcePath("../../templates/lines.html.gwirl", "lines_gwirl.go")
+		},
+		lines,
+	},
+	{
+		"testdata/badge_gwirl.go",
+		parser.NewTemplate2(
+			parser.NewPosString("Badge"),
+			nil,
+			parser.NewPosString("(status string)"),
+			[]parser.Import{},
+			[]parser.TemplateTree2{
+				parser.NewTT2Switch(" status ", []parser.TemplateTree2{
+					parser.NewTT2Case(" \"ok\", \"done\" ", []parser.TemplateTree2{parser.NewTT2Plain("<b>ok</b>")}),
+					parser.NewTT2Default([]parser.TemplateTree2{parser.NewTT2GoExp("status", true, nil)}),
+				}),
+			},
+		),
+		nil,
+		badge,
+	},
+	{
+		"testdata/table_gwirl.go",
+		parser.NewTemplate2(
+			parser.NewPosString("Table"),
+			nil,
+			parser.NewPosString("(items []string)"),
+			[]parser.Import{},
+			[]parser.TemplateTree2{
+				parser.NewTT2TemplateDef("row(item string)", []parser.TemplateTree2{
+					parser.NewTT2Plain("<tr>"),
+					parser.NewTT2GoExp("item", true, nil),
+					parser.NewTT2Plain("</tr>"),
+				}),
+				parser.NewTT2Plain("<table>"),
+				parser.NewTT2GoExp("row(items[0])", false, nil),
+				parser.NewTT2Plain("</table>"),
+			},
+		),
+		nil,
+		table,
+	},
+	{
+		"testdata/tableRow_gwirl.go",
+		withTemplates(
+			parser.NewTemplate2(
+				parser.NewPosString("Table"),
+				nil,
+				parser.NewPosString("(names []string)"),
+				[]parser.Import{},
+				[]parser.TemplateTree2{
+					parser.NewTT2Plain("<table>"),
+					parser.NewTT2GoExp("TableRow(names[0])", false, nil),
+					parser.NewTT2Plain("</table>"),
+				},
+			),
+			parser.NewTemplate2(
+				parser.NewPosString("TableRow"),
+				nil,
+				parser.NewPosString("(name string)"),
+				[]parser.Import{},
+				[]parser.TemplateTree2{
+					parser.NewTT2Plain("<tr>"),
+					parser.NewTT2GoExp("name", true, nil),
+					parser.NewTT2Plain("</tr>"),
+				},
+			),
+		),
+		streamingGenerator,
+		tableRow,
+	},
+	{
+		"testdata/slots_gwirl.go",
+		parser.NewTemplate2(
+			parser.NewPosString("Page"),
+			nil,
+			parser.NewPosString("()"),
+			[]parser.Import{},
+			[]parser.TemplateTree2{
+				withPos(ptr(withSlots(parser.NewTT2GoExp("Card(\"t\")", false, [][]parser.TemplateTree2{{
+					parser.NewTT2Slot("footer", []parser.TemplateTree2{parser.NewTT2Plain("<button>Ok</button>")}),
+				}}))), SimplePosition{2, 1}),
+			},
+		),
+		func(g *Generator) {
+			g.SetSignatures(map[string]string{
+				"Card": "(title string, body gwirl.HTML, footer gwirl.HTML)",
+			})
+		},
+		slots,
+	},
+	{
+		"testdata/streamedContent_gwirl.go",
+		parser.NewTemplate2(
+			parser.NewPosString("Page"),
+			nil,
+			parser.NewPosString("(aside gwirl.HTML, body gwirl.HTML)"),
+			[]parser.Import{},
+			[]parser.TemplateTree2{
+				withPos(ptr(parser.NewTT2GoExp("Layout(\"Home\")", false, [][]parser.TemplateTree2{{
+					parser.NewTT2GoExp("body", true, nil),
+				}})), SimplePosition{2, 1}),
+				withPos(ptr(withSlots(parser.NewTT2GoExp("Card(\"t\")", false, [][]parser.TemplateTree2{{
+					parser.NewTT2Slot("body", []parser.TemplateTree2{parser.NewTT2Plain("<p>Hi</p>")}),
+				}}))), SimplePosition{3, 1}),
+				parser.NewTT2If("aside != \"\"", []parser.TemplateTree2{
+					parser.NewTT2GoExp("aside", true, nil),
+				}, nil, nil),
+			},
+		),
+		func(g *Generator) {
+			g.SetStreaming(true)
+			g.SetSignatures(map[string]string{
+				"Card":   "(title string, body gwirl.HTML, footer gwirl.HTML = \"\")",
+				"Layout": "(title gwirl.HTML, content gwirl.HTML)",
+			})
+		},
+		streamedContent,
+	},
+	{
+		"testdata/defaults_gwirl.go",
+		parser.NewTemplate2(
+			parser.NewPosString("Page"),
+			nil,
+			parser.NewPosString("(body gwirl.HTML, title string = \"Untitled\", compact bool = false)"),
+			[]parser.Import{},
+			[]parser.TemplateTree2{
+				parser.NewTT2GoExp("title", true, nil),
+				withPos(ptr(parser.NewTT2GoExp("Badge(\"new\", BadgeWithCompact(true))", false, [][]parser.TemplateTree2{{
+					parser.NewTT2Plain("<b>New</b>"),
+				}})), SimplePosition{2, 1}),
+				withPos(ptr(withSlots(parser.NewTT2GoExp("Card(\"t\")", false, [][]parser.TemplateTree2{{
+					parser.NewTT2Slot("footer", []parser.TemplateTree2{parser.NewTT2Plain("<button>Ok</button>")}),
+				}}))), SimplePosition{3, 1}),
+			},
+		),
+		func(g *Generator) {
+			g.SetStreaming(true)
+			g.SetSignatures(map[string]string{
+				"Card": "(title string, body gwirl.HTML, footer gwirl.HTML = \"\")",
+			})
+		},
+		defaults,
+	},
+	{
+		"testdata/list_gwirl.go",
+		withTypeParams(parser.NewTemplate2(
+			parser.NewPosString("List"),
+			nil,
+			parser.NewPosString("(items []T, render func(T) string, class string = \"list\")"),
+			[]parser.Import{},
+			[]parser.TemplateTree2{
+				withPos(ptr(withSlots(parser.NewTT2GoExp("Table[int](rows)", false, [][]parser.TemplateTree2{{
+					parser.NewTT2Slot("caption", []parser.TemplateTree2{parser.NewTT2Plain("Rows")}),
+				}}))), SimplePosition{2, 1}),
+			},
+		), "[T any]"),
+		func(g *Generator) {
+			g.SetStreaming(true)
+			g.SetSignatures(map[string]string{
+				"Table": "(rows []T, caption gwirl.HTML = \"\")",
+			})
+		},
+		list,
+	},
+	{
+		"testdata/card_gwirl.go",
+		parser.NewTemplate2(
+			parser.NewPosString("Card"),
+			&cardDocComment,
+			parser.NewPosString("(title string)"),
+			[]parser.Import{},
+			[]parser.TemplateTree2{
+				parser.NewTT2BlockComment(" the heading\n   goes first "),
+				parser.NewTT2GoExp("title", true, nil),
+			},
+		),
+		streamingGenerator,
+		card,
+	},
+	{
+		"testdata/imports_gwirl.go",
+		parser.NewTemplate2(
+			parser.NewPosString("Page"),
+			nil,
+			parser.NewPosString("()"),
+			[]parser.Import{
+				parser.NewImport("", `"fmt"`),
+				parser.NewImport("", `"github.com/gamebox/gwirl"`),
+				parser.NewImport("h", "`example.com/helpers`"),
+				parser.NewImport("", `"io"`),
+				parser.NewImport("g", `"github.com/gamebox/gwirl"`),
+			},
+			[]parser.TemplateTree2{
+				parser.NewTT2GoExp("fmt.Sprint(1)", true, nil),
+				parser.NewTT2GoExp("h.Upper(\"a\")", true, nil),
+				parser.NewTT2GoExp("g.HTML(\"b\")", false, nil),
+			},
+		),
+		streamingGenerator,
+		imports,
+	},
+	{
+		"testdata/resolvedImports_gwirl.go",
+		parser.NewTemplate2(
+			parser.NewPosString("Page"),
+			nil,
+			parser.NewPosString("(items []todo.Item)"),
+			[]parser.Import{
+				parser.NewImport("", `"fmt"`),
+				parser.NewImport("tpl", `"text/template"`),
+				parser.NewImport("_", `"example.com/registers"`),
+				parser.NewImport("", `"k8s.io/api/core/v1"`),
+				parser.NewImport("", `"example.com/app/ui-kit"`),
+				parser.NewImport("h", `"example.com/helpers"`),
+				parser.NewImport("", `"example.com/app/config"`),
+				parser.NewImport("", `"example.com/unknown/go-thing"`),
+			},
+			[]parser.TemplateTree2{
+				parser.NewTT2For(" _, item := range items ", []parser.TemplateTree2{
+					parser.NewTT2GoExp("v1.PodRunning", true, nil),
+					parser.NewTT2GoExp("kit.Button(item.Name)", false, nil),
+					parser.NewTT2GoExp("strings.ToUpper(item.Name)", true, nil),
+					parser.NewTT2GoExp("tpl.HTMLEscapeString(item.Name)", false, nil),
+					parser.NewTT2GoExp("rand.Int()", true, nil),
+					parser.NewTT2GoExp("unknown.Value", true, nil),
+					parser.NewTT2GoExp("config.Title", true, nil),
+				}),
+			},
+		),
+		func(g *Generator) {
+			g.SetPackages(map[string]string{
+				"todo":   "example.com/app/todo",
+				"rand":   "example.com/app/rand",
+				"kit":    "example.com/app/ui-kit",
+				"config": "example.com/app/config",
+			})
+			g.SetDeclarations(map[string]bool{"config": true})
+		},
+		resolvedImports,
+	},
 }
 
 func TestGenerator(t *testing.T) {
 	for _, test := range tests {
 		res := t.Run(test.filename, func(t *testing.T) {
 			gen := NewGenerator(false)
+			if test.configure != nil {
+				test.configure(&gen)
+			}
 			writer := strings.Builder{}
-			gen.Generate(test.template, "views", &writer)
+			err := gen.Generate(test.template, "views", &writer)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
 			if writer.String() != test.expected {
 				edits := myers.ComputeEdits(span.URI(test.filename), test.expected, writer.String())
 				diff := gotextdiff.ToUnified("expected", "received", test.expected, edits)
@@ -126,3 +547,146 @@ func TestGenerator(t *testing.T) {
 		}
 	}
 }
+
+func TestGeneratorInvalidGo(t *testing.T) {
+	template := parser.NewTemplate2(
+		parser.NewPosString("Broken"),
+		nil,
+		parser.NewPosString("(items []string)"),
+		[]parser.Import{},
+		[]parser.TemplateTree2{
+			withPos(ptr(parser.NewTT2For(" _, item := range items) ", []parser.TemplateTree2{
+				parser.NewTT2Plain("<li></li>"),
+			})), SimplePosition{2, 1}),
+		},
+	)
+	gen := NewGenerator(false)
+	gen.SetSourcePath("../../templates/broken.html.gwirl", "broken_gwirl.go")
+	err := gen.Generate(template, "views", &strings.Builder{})
+	genErr, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("Expected a generator error, got %v", err)
+	}
+	if genErr.Line != 2 || !strings.HasPrefix(genErr.Message, "Invalid Go code: ") {
+		t.Fatalf("Expected an invalid Go code error on line 2, got %v", genErr)
+	}
+
+	gen = NewGenerator(false)
+	err = gen.Generate(template, "views", &strings.Builder{})
+	if err == nil {
+		t.Fatalf("Expected an error without a source path")
+	}
+	if _, ok := err.(*Error); ok {
+		t.Fatalf("Expected an error without a position, got %v", err)
+	}
+}
+
+func TestGeneratorGoBlockPositions(t *testing.T) {
+	source := "@(completed bool)\n" +
+		"\n" +
+		"@{\n" +
+		"    classes := \"\"\n" +
+		"    if completed { classes += doneClass }\n" +
+		"    if !completed { classes += \"todo\" }\n" +
+		"    classes += extraClass\n" +
+		"}\n" +
+		"<p class=\"@classes\"></p>\n"
+	p := parser.NewParser2("")
+	result := p.Parse(source, "Item")
+	if len(result.Errors) > 0 {
+		t.Fatalf("Unexpected parse errors: %v", result.Errors)
+	}
+	gen := NewGenerator(false)
+	gen.SetSourcePath("item.html.gwirl", "item_gwirl.go")
+	output := strings.Builder{}
+	if err := gen.Generate(result.Template, "views", &output); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	fset := token.NewFileSet()
+	file, err := goparser.ParseFile(fset, "item_gwirl.go", output.String(), 0)
+	if err != nil {
+		t.Fatalf("Expected the generated code to parse: %v\n%s", err, output.String())
+	}
+	positions := map[string]string{}
+	config := types.Config{
+		Importer: importer.ForCompiler(fset, "source", nil),
+		Error: func(err error) {
+			typeErr := err.(types.Error)
+			positions[typeErr.Msg] = typeErr.Fset.Position(typeErr.Pos).String()
+		},
+	}
+	config.Check("views", fset, []*ast.File{file}, nil)
+	expected := map[string]string{
+		"undefined: doneClass":  "item.html.gwirl:5:31",
+		"undefined: extraClass": "item.html.gwirl:7:16",
+	}
+	for msg, position := range expected {
+		if positions[msg] != position {
+			t.Errorf("Expected %q at %s, got %v\n%s", msg, position, positions, output.String())
+		}
+	}
+}
+
+func TestGeneratorEscapedTagName(t *testing.T) {
+	template := parser.NewTemplate2(
+		parser.NewPosString("Attrs"),
+		nil,
+		parser.NewPosString("(attrs string)"),
+		[]parser.Import{},
+		[]parser.TemplateTree2{
+			parser.NewTT2Plain("<div "),
+			withPos(ptr(parser.NewTT2GoExp("attrs", true, nil)), SimplePosition{2, 6}),
+			parser.NewTT2Plain(">"),
+		},
+	)
+	gen := NewGenerator(false)
+	err := gen.Generate(template, "views", &strings.Builder{})
+	genErr, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("Expected a generator error, got %v", err)
+	}
+	if genErr.Line != 2 || !strings.HasPrefix(genErr.Message, "attrs can't be escaped here") {
+		t.Fatalf("Expected an error for the attribute name on line 2, got %v", genErr)
+	}
+}
+
+func TestGeneratorNamedSlotErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		call     string
+		slot     string
+		expected string
+	}{
+		{"unknown template", "Other()", "body", "Named slots can only be passed to templates in the same package, Other was not found"},
+		{"unknown parameter", "Card(\"t\")", "header", "Card has no parameter named header"},
+		{"passed as an argument", "Card(\"t\", body)", "body", "Parameter body of Card is already passed as an argument"},
+		{"not html", "Card()", "title", "Parameter title of Card is a string, slots can only be passed to gwirl.HTML parameters"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			slot := withPos(ptr(parser.NewTT2Slot(test.slot, []parser.TemplateTree2{})), SimplePosition{3, 5})
+			call := parser.NewTT2GoExp(test.call, false, [][]parser.TemplateTree2{{slot}})
+			call.Metadata.Set(parser.TTMDSlots)
+			template := parser.NewTemplate2(
+				parser.NewPosString("Page"),
+				nil,
+				parser.NewPosString("(body gwirl.HTML)"),
+				[]parser.Import{},
+				[]parser.TemplateTree2{withPos(&call, SimplePosition{2, 1})},
+			)
+			gen := NewGenerator(false)
+			gen.SetSignatures(map[string]string{
+				"Card": "(title string, body gwirl.HTML, footer gwirl.HTML)",
+			})
+			err := gen.Generate(template, "views", &strings.Builder{})
+			genErr, ok := err.(*Error)
+			if !ok {
+				t.Fatalf("Expected a generator error, got %v", err)
+			}
+			if genErr.Message != test.expected || genErr.Line == 0 {
+				t.Fatalf("Expected \"%s\" with a position, got %v", test.expected, genErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package gen
+
+import (
+	"go/ast"
+	goparser "go/parser"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gamebox/gwirl/internal/parser"
+)
+
+// The packages of the standard library that are imported automatically, keyed
+// by their names.  When more than one package has the same name, the one most
+// likely to be used in a template is chosen, and the others need an @import.
+var stdlibPackages = map[string]string{
+	"adler32":         "hash/adler32",
+	"ascii85":         "encoding/ascii85",
+	"asn1":            "encoding/asn1",
+	"atomic":          "sync/atomic",
+	"base32":          "encoding/base32",
+	"base64":          "encoding/base64",
+	"big":             "math/big",
+	"binary":          "encoding/binary",
+	"bits":            "math/bits",
+	"bufio":           "bufio",
+	"bytes":           "bytes",
+	"cmp":             "cmp",
+	"cmplx":           "math/cmplx",
+	"color":           "image/color",
+	"context":         "context",
+	"crc32":           "hash/crc32",
+	"csv":             "encoding/csv",
+	"errors":          "errors",
+	"filepath":        "path/filepath",
+	"fmt":             "fmt",
+	"fnv":             "hash/fnv",
+	"fs":              "io/fs",
+	"hex":             "encoding/hex",
+	"html":            "html",
+	"http":            "net/http",
+	"image":           "image",
+	"io":              "io",
+	"json":            "encoding/json",
+	"log":             "log",
+	"mail":            "net/mail",
+	"maps":            "maps",
+	"math":            "math",
+	"md5":             "crypto/md5",
+	"mime":            "mime",
+	"net":             "net",
+	"netip":           "net/netip",
+	"os":              "os",
+	"path":            "path",
+	"pem":             "encoding/pem",
+	"quotedprintable": "mime/quotedprintable",
+	"rand":            "math/rand",
+	"reflect":         "reflect",
+	"regexp":          "regexp",
+	"sha1":            "crypto/sha1",
+	"sha256":          "crypto/sha256",
+	"sha512":          "crypto/sha512",
+	"slices":          "slices",
+	"slog":            "log/slog",
+	"sort":            "sort",
+	"strconv":         "strconv",
+	"strings":         "strings",
+	"sync":            "sync",
+	"tabwriter":       "text/tabwriter",
+	"template":        "html/template",
+	"time":            "time",
+	"unicode":         "unicode",
+	"url":             "net/url",
+	"utf16":           "unicode/utf16",
+	"utf8":            "unicode/utf8",
+	"xml":             "encoding/xml",
+}
+
+// Returns the name a package is most likely to have, based on its import path.
+// This is the last element of the path, ignoring a major version suffix like
+// "/v2" and the "go-" prefix and ".go" suffix some repositories use.
+func assumedPackageName(path string) string {
+	elems := strings.Split(path, "/")
+	name := elems[len(elems)-1]
+	if len(elems) > 1 && len(name) > 1 && name[0] == 'v' && strings.Trim(name[1:], "0123456789") == "" {
+		name = elems[len(elems)-2]
+	}
+	name = strings.TrimPrefix(name, "go-")
+	if idx := strings.IndexFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	}); idx >= 0 {
+		name = name[:idx]
+	}
+	return name
+}
+
+// Reports whether a package is in the standard library, whose paths don't start
+// with a domain name.
+func stdlibPath(path string) bool {
+	first, _, _ := strings.Cut(path, "/")
+	return !strings.Contains(first, ".")
+}
+
+// Returns the names of the packages the generated code refers to, which are
+// the identifiers that are selected from without being declared.  Reports
+// false if the code can't be parsed, in which case nothing can be known about
+// the packages it uses.
+func usedPackages(code string) (map[string]bool, bool) {
+	file, err := goparser.ParseFile(token.NewFileSet(), "", "package p\n"+code, 0)
+	if err != nil {
+		return nil, false
+	}
+	used := map[string]bool{}
+	ast.Inspect(file, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if id, ok := sel.X.(*ast.Ident); ok && id.Obj == nil {
+			used[id.Name] = true
+		}
+		return true
+	})
+	return used, true
+}
+
+// Returns the imports the generated code needs, besides the ones the generator
+// adds itself.  The imports of the template are left out when the code doesn't
+// use them, unless they are blank or dot imports or the names of their packages
+// can't be known, and the packages the code uses that aren't imported are
+// looked up in the standard library and then the module.  If the code can't be
+// parsed, the imports of the template are returned as written.
+func (G *Generator) resolveImports(imports []parser.Import, code string) []parser.Import {
+	imports = G.userImports(imports)
+	used, ok := usedPackages(code)
+	if !ok {
+		return imports
+	}
+	// The names declared by the other files of the package are values and
+	// types, not packages.
+	for name := range used {
+		if _, ok := G.signature(name); ok || G.declarations[name] {
+			delete(used, name)
+		}
+	}
+	own := map[string]bool{"gwirl": true}
+	if G.streaming {
+		own["io"] = true
+	}
+	// The names of the packages of the module are known, and so are the names
+	// of the packages of the standard library, which are the last elements of
+	// their paths.  The names of other packages can only be assumed.
+	moduleNames := make(map[string]string, len(G.packages))
+	for name, path := range G.packages {
+		moduleNames[path] = name
+	}
+	result := make([]parser.Import, 0, len(imports))
+	imported := map[string]bool{}
+	for _, i := range imports {
+		name := i.Name.Str
+		known := name != ""
+		if name == "" {
+			path := i.PackagePath()
+			name, known = moduleNames[path]
+			if !known {
+				name = assumedPackageName(path)
+				known = stdlibPath(path)
+			}
+		}
+		if name == "_" || name == "." || !known || used[name] {
+			result = append(result, i)
+			imported[name] = true
+		}
+	}
+	missing := []string{}
+	for name := range used {
+		if !imported[name] && !own[name] {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	for _, name := range missing {
+		path, ok := stdlibPackages[name]
+		if !ok {
+			path, ok = G.packages[name]
+		}
+		if !ok {
+			continue
+		}
+		imp := parser.NewImport("", strconv.Quote(path))
+		if assumedPackageName(path) != name {
+			imp.Name.Str = name
+		}
+		result = append(result, imp)
+	}
+	return result
+}
@@ -0,0 +1,243 @@
+package gen
+
+import (
+	"fmt"
+	"go/ast"
+	goparser "go/parser"
+	"go/token"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gamebox/gwirl/internal/parser"
+)
+
+// Templates with default values for some of their parameters take those
+// parameters as functional options, so that callers only pass what they need.
+// A template
+//
+//	@(body gwirl.HTML, title string = "Untitled")
+//
+// is generated as `func Card(body gwirl.HTML, options_ ...CardOption)`, and
+// is called like `Card(body, CardWithTitle("Home"))`.  The options of generic
+// templates are generic as well, taking the same type parameters.
+
+// Returns the name of the option type of a template, and of the struct that
+// its options set.
+func optionTypes(name string) (string, string) {
+	first, size := utf8.DecodeRuneInString(name)
+	return name + "Option", string(unicode.ToLower(first)) + name[size:] + "Options"
+}
+
+// Returns the name of the function that sets an optional parameter of a
+// template.
+func optionFunc(name string, param string) string {
+	first, size := utf8.DecodeRuneInString(param)
+	return name + "With" + string(unicode.ToUpper(first)) + param[size:]
+}
+
+// Returns the parameters of the function for a template, with the parameters
+// that have default values replaced by its options.
+func templateParams(template parser.Template2) string {
+	params := parser.ParseParams(template.Params.Str)
+	if !parser.HasDefaults(params) {
+		return template.Params.Str
+	}
+	optionType, _ := optionTypes(template.Name.Str)
+	optionType += parser.TypeArgs(template.TypeParams.Str)
+	required := []string{}
+	for _, param := range params {
+		if param.Default == "" {
+			required = append(required, param.String())
+		}
+	}
+	required = append(required, "options_ ..."+optionType)
+	return "(" + strings.Join(required, ", ") + ")"
+}
+
+// Writes the start of the function for a template with options, which applies
+// them over the default values and declares each optional parameter as a
+// variable.
+func (G *Generator) genOptionsPrelude(template parser.Template2) {
+	params := parser.ParseParams(template.Params.Str)
+	if !parser.HasDefaults(params) {
+		return
+	}
+	_, optionsStruct := optionTypes(template.Name.Str)
+	G.write("o_ := ")
+	G.writeNoIndent(optionsStruct)
+	G.writeNoIndent(parser.TypeArgs(template.TypeParams.Str))
+	G.writeNoIndent("{\n")
+	G.indent()
+	for _, param := range params {
+		if param.Default != "" {
+			G.write(fmt.Sprintf("%s: %s,\n", param.Name, param.Default))
+		}
+	}
+	G.dedent()
+	G.write("}\n")
+	G.write("for _, option_ := range options_ {\n")
+	G.indent()
+	G.write("option_(&o_)\n")
+	G.dedent()
+	G.write("}\n")
+	for _, param := range params {
+		if param.Default != "" {
+			G.write(fmt.Sprintf("%s := o_.%s\n", param.Name, param.Name))
+			G.write(fmt.Sprintf("_ = %s\n", param.Name))
+		}
+	}
+}
+
+// Writes the option type of a template, and a function to set each of its
+// optional parameters.
+func (G *Generator) genOptions(template parser.Template2) {
+	params := parser.ParseParams(template.Params.Str)
+	if !parser.HasDefaults(params) {
+		return
+	}
+	name := template.Name.Str
+	typeParams := template.TypeParams.Str
+	typeArgs := parser.TypeArgs(typeParams)
+	optionType, optionsStruct := optionTypes(name)
+	G.newlines()
+	G.writeln(fmt.Sprintf("// %s sets an optional parameter of %s.", optionType, name))
+	G.writeln(fmt.Sprintf("type %s%s func(*%s%s)", optionType, typeParams, optionsStruct, typeArgs))
+	G.newlines()
+	G.writeln(fmt.Sprintf("type %s%s struct {", optionsStruct, typeParams))
+	G.indent()
+	for _, param := range params {
+		if param.Default != "" {
+			G.writeln(param.String())
+		}
+	}
+	G.dedent()
+	G.writeln("}")
+	for _, param := range params {
+		if param.Default == "" {
+			continue
+		}
+		fn := optionFunc(name, param.Name)
+		G.newlines()
+		G.writeln(fmt.Sprintf("// %s sets the %s parameter of %s, which is %s by default.", fn, param.Name, name, param.Default))
+		G.writeln(fmt.Sprintf("func %s%s(%s) %s%s {", fn, typeParams, param.String(), optionType, typeArgs))
+		G.indent()
+		G.writeln(fmt.Sprintf("return func(o_ *%s%s) {", optionsStruct, typeArgs))
+		G.indent()
+		G.writeln(fmt.Sprintf("o_.%s = %s", param.Name, param.Name))
+		G.dedent()
+		G.writeln("}")
+		G.dedent()
+		G.writeln("}")
+	}
+}
+
+// Returns the name of the template being called, and the type arguments it is
+// called with, if any.
+func calleeName(callee string) (string, string) {
+	if !strings.HasSuffix(callee, "]") {
+		return callee, ""
+	}
+	idx := strings.Index(callee, "[")
+	if idx < 0 {
+		return callee, ""
+	}
+	return callee[:idx], callee[idx:]
+}
+
+// Returns the option functions of a template, keyed by their names, with the
+// parameters they set.
+func optionFuncs(name string, signature string) map[string]string {
+	funcs := map[string]string{}
+	for _, param := range parser.ParseParams(signature) {
+		if param.Default != "" {
+			funcs[optionFunc(name, param.Name)] = param.Name
+		}
+	}
+	return funcs
+}
+
+// Returns the parameter an argument of a call sets, when the argument is a call
+// of one of the option functions of the template being called.  When the option
+// functions are not known, since the template is in another package, any
+// function named like an option function of the template is taken to be one.
+func optionArg(name string, arg string, funcs map[string]string) (string, bool) {
+	expr, err := goparser.ParseExpr(arg)
+	if err != nil {
+		return "", false
+	}
+	call, ok := expr.(*ast.CallExpr)
+	if !ok {
+		return "", false
+	}
+	fun := call.Fun
+	switch index := fun.(type) {
+	case *ast.IndexExpr:
+		fun = index.X
+	case *ast.IndexListExpr:
+		fun = index.X
+	}
+	funName := ""
+	switch f := fun.(type) {
+	case *ast.Ident:
+		funName = f.Name
+	case *ast.SelectorExpr:
+		pkg, ok := f.X.(*ast.Ident)
+		if !ok {
+			return "", false
+		}
+		funName = pkg.Name + "." + f.Sel.Name
+	default:
+		return "", false
+	}
+	if funcs != nil {
+		param, ok := funcs[funName]
+		return param, ok
+	}
+	rest, ok := strings.CutPrefix(funName, name+"With")
+	if !ok || !token.IsExported(rest) {
+		return "", false
+	}
+	first, size := utf8.DecodeRuneInString(rest)
+	return string(unicode.ToLower(first)) + rest[size:], true
+}
+
+// Returns the arguments of a call, split into the positional arguments and the
+// options that follow them.  Options are the trailing arguments that call one
+// of the option functions of the template being called, given by funcs when
+// they are known.
+func callArgs(name string, argList string, funcs map[string]string) ([]string, []string) {
+	args := parser.SplitArgs(argList)
+	for i := range args {
+		args[i] = strings.TrimSpace(args[i])
+	}
+	name, _ = calleeName(name)
+	split := len(args)
+	for split > 0 {
+		if _, ok := optionArg(name, args[split-1], funcs); !ok {
+			break
+		}
+		split--
+	}
+	return args[:split], args[split:]
+}
+
+// Returns the text of a call with the given positional arguments and options
+// added to it.  Positional arguments go before any options already passed.
+func callText(text string, args []string, options []string, funcs map[string]string) string {
+	if len(args) == 0 && len(options) == 0 {
+		return text
+	}
+	name, argList, _ := strings.Cut(strings.TrimSuffix(text, ")"), "(")
+	given, givenOptions := callArgs(name, argList, funcs)
+	if len(givenOptions) == 0 && len(options) == 0 {
+		text, _ = strings.CutSuffix(text, ")")
+		if strings.HasSuffix(text, "(") {
+			return text + strings.Join(args, ", ") + ")"
+		}
+		return text + ", " + strings.Join(args, ", ") + ")"
+	}
+	all := make([]string, 0, len(given)+len(args)+len(givenOptions)+len(options))
+	all = append(append(append(append(all, given...), args...), givenOptions...), options...)
+	return name + "(" + strings.Join(all, ", ") + ")"
+}
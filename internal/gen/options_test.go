@@ -0,0 +1,34 @@
+package gen
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCallArgs(t *testing.T) {
+	funcs := optionFuncs("Card", "(body gwirl.HTML, title string = \"\", compact bool = false)")
+	tests := []struct {
+		name    string
+		callee  string
+		argList string
+		funcs   map[string]string
+		args    []string
+		options []string
+	}{
+		{"options", "Card", "body, CardWithTitle(\"Home\"), CardWithCompact(true)", funcs, []string{"body"}, []string{"CardWithTitle(\"Home\")", "CardWithCompact(true)"}},
+		{"generic option", "Card[int]", "body, CardWithTitle[int](\"Home\")", funcs, []string{"body"}, []string{"CardWithTitle[int](\"Home\")"}},
+		{"other function", "Card", "CardWithTitleCase(\"x\")", funcs, []string{"CardWithTitleCase(\"x\")"}, []string{}},
+		{"option in an expression", "Card", "CardWithTitle(\"x\").Render()", funcs, []string{"CardWithTitle(\"x\").Render()"}, []string{}},
+		{"option value", "Card", "CardWithTitle", funcs, []string{"CardWithTitle"}, []string{}},
+		{"template of another package", "ui.Card", "body, ui.CardWithTitle(\"Home\")", nil, []string{"body"}, []string{"ui.CardWithTitle(\"Home\")"}},
+		{"unexported function of another package", "ui.Card", "ui.CardWithtitle(\"Home\")", nil, []string{"ui.CardWithtitle(\"Home\")"}, []string{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			args, options := callArgs(test.callee, test.argList, test.funcs)
+			if !reflect.DeepEqual(args, test.args) || !reflect.DeepEqual(options, test.options) {
+				t.Fatalf("Expected %q and %q, got %q and %q", test.args, test.options, args, options)
+			}
+		})
+	}
+}
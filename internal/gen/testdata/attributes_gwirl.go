@@ -0,0 +1,25 @@
+package views
+
+import (
+	"github.com/gamebox/gwirl"
+)
+
+func Attributes(class string, name string) gwirl.HTML {
+	sb_ := gwirl.TemplateBuilder{}
+
+	sb_.WriteString(`<div class=`)
+
+	gwirl.WriteEscapedUnquotedAttr(&sb_, class)
+
+	sb_.WriteString(` id="a">
+<script>// don't
+var a = `)
+
+	gwirl.WriteRawHTML(&sb_, gwirl.EscapeJS(name))
+
+	sb_.WriteString(`;</script>
+</div>
+`)
+
+	return sb_.HTML()
+}
@@ -0,0 +1,20 @@
+package views
+
+import (
+	"github.com/gamebox/gwirl"
+)
+
+func Badge(status string) gwirl.HTML {
+	sb_ := gwirl.TemplateBuilder{}
+
+	switch status {
+	case "ok", "done":
+		sb_.WriteString(`<b>ok</b>`)
+
+	default:
+		gwirl.WriteEscapedHTML(&sb_, status)
+
+	}
+
+	return sb_.HTML()
+}
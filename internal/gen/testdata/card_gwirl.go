@@ -0,0 +1,34 @@
+package views
+
+import (
+	"io"
+
+	"github.com/gamebox/gwirl"
+)
+
+// Card renders a card with a title.
+//
+// Parameters:
+//   - title: the heading
+func Card(title string) gwirl.HTML {
+	sb_ := gwirl.TemplateBuilder{}
+
+	// the heading
+	//   goes first
+
+	gwirl.WriteEscapedHTML(&sb_, title)
+
+	return sb_.HTML()
+}
+
+// WriteCard renders Card straight to a writer instead of returning it.
+func WriteCard(w_ io.Writer, title string) error {
+	sb_ := gwirl.TemplateWriter{Writer: w_}
+
+	// the heading
+	//   goes first
+
+	gwirl.WriteEscapedHTML(&sb_, title)
+
+	return sb_.Err()
+}
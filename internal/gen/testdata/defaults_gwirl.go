@@ -0,0 +1,103 @@
+package views
+
+import (
+	"io"
+
+	"github.com/gamebox/gwirl"
+)
+
+func Page(body gwirl.HTML, options_ ...PageOption) gwirl.HTML {
+	o_ := pageOptions{
+		title:   "Untitled",
+		compact: false,
+	}
+	for _, option_ := range options_ {
+		option_(&o_)
+	}
+	title := o_.title
+	_ = title
+	compact := o_.compact
+	_ = compact
+	sb_ := gwirl.TemplateBuilder{}
+
+	gwirl.WriteEscapedHTML(&sb_, title)
+
+	var transclusion__2__1__0 gwirl.HTML
+	{
+		sb_ := gwirl.TemplateBuilder{}
+		sb_.WriteString(`<b>New</b>`)
+
+		transclusion__2__1__0 = sb_.HTML()
+	}
+	gwirl.WriteRawHTML(&sb_, Badge("new", transclusion__2__1__0, BadgeWithCompact(true)))
+
+	var slot__3__1__footer gwirl.HTML
+	{
+		sb_ := gwirl.TemplateBuilder{}
+		sb_.WriteString(`<button>Ok</button>`)
+
+		slot__3__1__footer = sb_.HTML()
+	}
+	gwirl.WriteRawHTML(&sb_, Card("t", "", CardWithFooter(slot__3__1__footer)))
+
+	return sb_.HTML()
+}
+
+func WritePage(w_ io.Writer, body gwirl.Content, options_ ...PageOption) error {
+	o_ := pageOptions{
+		title:   "Untitled",
+		compact: false,
+	}
+	for _, option_ := range options_ {
+		option_(&o_)
+	}
+	title := o_.title
+	_ = title
+	compact := o_.compact
+	_ = compact
+	sb_ := gwirl.TemplateWriter{Writer: w_}
+
+	gwirl.WriteEscapedHTML(&sb_, title)
+
+	var transclusion__2__1__0 gwirl.HTML
+	{
+		sb_ := gwirl.TemplateBuilder{}
+		sb_.WriteString(`<b>New</b>`)
+
+		transclusion__2__1__0 = sb_.HTML()
+	}
+	gwirl.WriteRawHTML(&sb_, Badge("new", transclusion__2__1__0, BadgeWithCompact(true)))
+
+	var slot__3__1__footer gwirl.HTML
+	{
+		sb_ := gwirl.TemplateBuilder{}
+		sb_.WriteString(`<button>Ok</button>`)
+
+		slot__3__1__footer = sb_.HTML()
+	}
+	WriteCard(&sb_, "t", gwirl.HTML(""), CardWithFooter(slot__3__1__footer))
+
+	return sb_.Err()
+}
+
+// PageOption sets an optional parameter of Page.
+type PageOption func(*pageOptions)
+
+type pageOptions struct {
+	title   string
+	compact bool
+}
+
+// PageWithTitle sets the title parameter of Page, which is "Untitled" by default.
+func PageWithTitle(title string) PageOption {
+	return func(o_ *pageOptions) {
+		o_.title = title
+	}
+}
+
+// PageWithCompact sets the compact parameter of Page, which is false by default.
+func PageWithCompact(compact bool) PageOption {
+	return func(o_ *pageOptions) {
+		o_.compact = compact
+	}
+}
@@ -0,0 +1,18 @@
+package views
+
+import (
+	"github.com/gamebox/gwirl"
+)
+
+func Escaped(name string) gwirl.HTML {
+	sb_ := gwirl.TemplateBuilder{}
+
+	sb_.WriteString(`<title>`)
+
+	gwirl.WriteEscapedMarkdown(&sb_, name)
+
+	sb_.WriteString(`</title>
+`)
+
+	return sb_.HTML()
+}
@@ -0,0 +1,20 @@
+package views
+
+import (
+	"github.com/gamebox/gwirl"
+)
+
+func Escaped(name string, body string) gwirl.HTML {
+	sb_ := gwirl.TemplateBuilder{}
+
+	sb_.WriteString(`<h2>`)
+
+	gwirl.WriteEscapedHTML(&sb_, name)
+
+	sb_.WriteString(`</h2>
+`)
+
+	gwirl.WriteRawHTML(&sb_, body)
+
+	return sb_.HTML()
+}
@@ -0,0 +1,35 @@
+package views
+
+import (
+	"fmt"
+	"io"
+
+	h "example.com/helpers"
+	g "github.com/gamebox/gwirl"
+
+	"github.com/gamebox/gwirl"
+)
+
+func Page() gwirl.HTML {
+	sb_ := gwirl.TemplateBuilder{}
+
+	gwirl.WriteEscapedHTML(&sb_, fmt.Sprint(1))
+
+	gwirl.WriteEscapedHTML(&sb_, h.Upper("a"))
+
+	gwirl.WriteRawHTML(&sb_, g.HTML("b"))
+
+	return sb_.HTML()
+}
+
+func WritePage(w_ io.Writer) error {
+	sb_ := gwirl.TemplateWriter{Writer: w_}
+
+	gwirl.WriteEscapedHTML(&sb_, fmt.Sprint(1))
+
+	gwirl.WriteEscapedHTML(&sb_, h.Upper("a"))
+
+	gwirl.WriteRawHTML(&sb_, g.HTML("b"))
+
+	return sb_.Err()
+}
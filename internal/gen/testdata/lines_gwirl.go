@@ -0,0 +1,22 @@
+package views
+
+import (
+	"github.com/gamebox/gwirl"
+)
+
+func Lines(name string, index int) gwirl.HTML {
+	sb_ := gwirl.TemplateBuilder{}
+
+//line ../../templates/lines.html.gwirl:4:4
+	x := 1
+//line lines_gwirl.go:13:1
+
+	if /*line ../../templates/lines.html.gwirl:6:4*/ index > 0 /*line lines_gwirl.go:14:90*/ {
+		sb_.WriteString(`<hr />`)
+
+	}
+
+	gwirl.WriteRawHTML(&sb_, ( /*line ../../templates/lines.html.gwirl:7:5*/ name /*line lines_gwirl.go:19:110*/))
+
+	return sb_.HTML()
+}
@@ -0,0 +1,67 @@
+package views
+
+import (
+	"io"
+
+	"github.com/gamebox/gwirl"
+)
+
+func List[T any](items []T, render func(T) string, options_ ...ListOption[T]) gwirl.HTML {
+	o_ := listOptions[T]{
+		class: "list",
+	}
+	for _, option_ := range options_ {
+		option_(&o_)
+	}
+	class := o_.class
+	_ = class
+	sb_ := gwirl.TemplateBuilder{}
+
+	var slot__2__1__caption gwirl.HTML
+	{
+		sb_ := gwirl.TemplateBuilder{}
+		sb_.WriteString(`Rows`)
+
+		slot__2__1__caption = sb_.HTML()
+	}
+	gwirl.WriteRawHTML(&sb_, Table[int](rows, TableWithCaption[int](slot__2__1__caption)))
+
+	return sb_.HTML()
+}
+
+func WriteList[T any](w_ io.Writer, items []T, render func(T) string, options_ ...ListOption[T]) error {
+	o_ := listOptions[T]{
+		class: "list",
+	}
+	for _, option_ := range options_ {
+		option_(&o_)
+	}
+	class := o_.class
+	_ = class
+	sb_ := gwirl.TemplateWriter{Writer: w_}
+
+	var slot__2__1__caption gwirl.HTML
+	{
+		sb_ := gwirl.TemplateBuilder{}
+		sb_.WriteString(`Rows`)
+
+		slot__2__1__caption = sb_.HTML()
+	}
+	WriteTable[int](&sb_, rows, TableWithCaption[int](slot__2__1__caption))
+
+	return sb_.Err()
+}
+
+// ListOption sets an optional parameter of List.
+type ListOption[T any] func(*listOptions[T])
+
+type listOptions[T any] struct {
+	class string
+}
+
+// ListWithClass sets the class parameter of List, which is "list" by default.
+func ListWithClass[T any](class string) ListOption[T] {
+	return func(o_ *listOptions[T]) {
+		o_.class = class
+	}
+}
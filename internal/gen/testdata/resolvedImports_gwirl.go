@@ -0,0 +1,38 @@
+package views
+
+import (
+	"math/rand"
+	"strings"
+	tpl "text/template"
+
+	"example.com/app/todo"
+	"example.com/app/ui-kit"
+	_ "example.com/registers"
+	"example.com/unknown/go-thing"
+	"k8s.io/api/core/v1"
+
+	"github.com/gamebox/gwirl"
+)
+
+func Page(items []todo.Item) gwirl.HTML {
+	sb_ := gwirl.TemplateBuilder{}
+
+	for _, item := range items {
+		gwirl.WriteEscapedHTML(&sb_, v1.PodRunning)
+
+		gwirl.WriteRawHTML(&sb_, kit.Button(item.Name))
+
+		gwirl.WriteEscapedHTML(&sb_, strings.ToUpper(item.Name))
+
+		gwirl.WriteRawHTML(&sb_, tpl.HTMLEscapeString(item.Name))
+
+		gwirl.WriteEscapedHTML(&sb_, rand.Int())
+
+		gwirl.WriteEscapedHTML(&sb_, unknown.Value)
+
+		gwirl.WriteEscapedHTML(&sb_, config.Title)
+
+	}
+
+	return sb_.HTML()
+}
@@ -1,30 +1,29 @@
 package views
 
 import (
-    "github.com/gamebox/gwirl"
+	"github.com/gamebox/gwirl"
 )
 
+func Testing(name string, index int) gwirl.HTML {
+	sb_ := gwirl.TemplateBuilder{}
 
-func Testing(name string, index int) string {
-    sb_ := gwirl.TemplateBuilder{}
-
-    sb_.WriteString(`<div>
+	sb_.WriteString(`<div>
 	`)
 
-    if if index > 0 {
-        sb_.WriteString(`
+	if index > 0 {
+		sb_.WriteString(`
 		<hr />
 	`)
 
-    }
+	}
 
-    sb_.WriteString(`
+	sb_.WriteString(`
 	<h2>`)
 
-    gwirl.WriteEscapedHTML(&sb_, name)
+	gwirl.WriteEscapedHTML(&sb_, name)
 
-    sb_.WriteString(`</h2>
+	sb_.WriteString(`</h2>
 `)
 
-    return sb_.String()
+	return sb_.HTML()
 }
@@ -0,0 +1,20 @@
+package views
+
+import (
+	"github.com/gamebox/gwirl"
+)
+
+func Page() gwirl.HTML {
+	sb_ := gwirl.TemplateBuilder{}
+
+	var slot__2__1__footer gwirl.HTML
+	{
+		sb_ := gwirl.TemplateBuilder{}
+		sb_.WriteString(`<button>Ok</button>`)
+
+		slot__2__1__footer = sb_.HTML()
+	}
+	gwirl.WriteRawHTML(&sb_, Card("t", "", slot__2__1__footer))
+
+	return sb_.HTML()
+}
@@ -0,0 +1,68 @@
+package views
+
+import (
+	"io"
+
+	"github.com/gamebox/gwirl"
+)
+
+func Page(aside gwirl.HTML, body gwirl.HTML) gwirl.HTML {
+	sb_ := gwirl.TemplateBuilder{}
+
+	var transclusion__2__1__0 gwirl.HTML
+	{
+		sb_ := gwirl.TemplateBuilder{}
+		gwirl.WriteEscapedHTML(&sb_, body)
+
+		transclusion__2__1__0 = sb_.HTML()
+	}
+	gwirl.WriteRawHTML(&sb_, Layout("Home", transclusion__2__1__0))
+
+	var slot__3__1__body gwirl.HTML
+	{
+		sb_ := gwirl.TemplateBuilder{}
+		sb_.WriteString(`<p>Hi</p>`)
+
+		slot__3__1__body = sb_.HTML()
+	}
+	gwirl.WriteRawHTML(&sb_, Card("t", slot__3__1__body))
+
+	if aside != "" {
+		gwirl.WriteEscapedHTML(&sb_, aside)
+
+	}
+
+	return sb_.HTML()
+}
+
+func WritePage(w_ io.Writer, aside gwirl.Content, body gwirl.Content) error {
+	sb_ := gwirl.TemplateWriter{Writer: w_}
+
+	{
+		aside := gwirl.Render(aside)
+
+		transclusion__2__1__0 := gwirl.ContentFunc(func(w_ io.Writer) error {
+			sb_ := gwirl.TemplateWriter{Writer: w_}
+			gwirl.WriteEscapedHTML(&sb_, body)
+
+			return sb_.Err()
+		})
+		WriteLayout(&sb_, gwirl.HTML("Home"), transclusion__2__1__0)
+
+		slot__3__1__body := gwirl.ContentFunc(func(w_ io.Writer) error {
+			sb_ := gwirl.TemplateWriter{Writer: w_}
+			sb_.WriteString(`<p>Hi</p>`)
+
+			return sb_.Err()
+		})
+		WriteCard(&sb_, "t", slot__3__1__body)
+
+		if aside != "" {
+			gwirl.WriteEscapedHTML(&sb_, aside)
+
+		}
+
+	}
+
+	return sb_.Err()
+}
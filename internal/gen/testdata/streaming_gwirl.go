@@ -0,0 +1,33 @@
+package views
+
+import (
+	"io"
+
+	"github.com/gamebox/gwirl"
+)
+
+func Streaming(name string) gwirl.HTML {
+	sb_ := gwirl.TemplateBuilder{}
+
+	sb_.WriteString(`<h2>`)
+
+	gwirl.WriteEscapedHTML(&sb_, name)
+
+	sb_.WriteString(`</h2>
+`)
+
+	return sb_.HTML()
+}
+
+func WriteStreaming(w_ io.Writer, name string) error {
+	sb_ := gwirl.TemplateWriter{Writer: w_}
+
+	sb_.WriteString(`<h2>`)
+
+	gwirl.WriteEscapedHTML(&sb_, name)
+
+	sb_.WriteString(`</h2>
+`)
+
+	return sb_.Err()
+}
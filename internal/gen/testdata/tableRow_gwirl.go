@@ -0,0 +1,55 @@
+package views
+
+import (
+	"io"
+
+	"github.com/gamebox/gwirl"
+)
+
+func Table(names []string) gwirl.HTML {
+	sb_ := gwirl.TemplateBuilder{}
+
+	sb_.WriteString(`<table>`)
+
+	gwirl.WriteRawHTML(&sb_, TableRow(names[0]))
+
+	sb_.WriteString(`</table>`)
+
+	return sb_.HTML()
+}
+
+func WriteTable(w_ io.Writer, names []string) error {
+	sb_ := gwirl.TemplateWriter{Writer: w_}
+
+	sb_.WriteString(`<table>`)
+
+	gwirl.WriteRawHTML(&sb_, TableRow(names[0]))
+
+	sb_.WriteString(`</table>`)
+
+	return sb_.Err()
+}
+
+func TableRow(name string) gwirl.HTML {
+	sb_ := gwirl.TemplateBuilder{}
+
+	sb_.WriteString(`<tr>`)
+
+	gwirl.WriteEscapedHTML(&sb_, name)
+
+	sb_.WriteString(`</tr>`)
+
+	return sb_.HTML()
+}
+
+func WriteTableRow(w_ io.Writer, name string) error {
+	sb_ := gwirl.TemplateWriter{Writer: w_}
+
+	sb_.WriteString(`<tr>`)
+
+	gwirl.WriteEscapedHTML(&sb_, name)
+
+	sb_.WriteString(`</tr>`)
+
+	return sb_.Err()
+}
@@ -0,0 +1,30 @@
+package views
+
+import (
+	"github.com/gamebox/gwirl"
+)
+
+func Table(items []string) gwirl.HTML {
+	sb_ := gwirl.TemplateBuilder{}
+
+	var row func(item string) gwirl.HTML
+	row = func(item string) gwirl.HTML {
+		sb_ := gwirl.TemplateBuilder{}
+		sb_.WriteString(`<tr>`)
+
+		gwirl.WriteEscapedHTML(&sb_, item)
+
+		sb_.WriteString(`</tr>`)
+
+		return sb_.HTML()
+	}
+	_ = row
+
+	sb_.WriteString(`<table>`)
+
+	gwirl.WriteRawHTML(&sb_, row(items[0]))
+
+	sb_.WriteString(`</table>`)
+
+	return sb_.HTML()
+}
@@ -0,0 +1,101 @@
+package parser
+
+import (
+	"path"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// An import of a Go package, from either a single `@import` or a block of
+// imports in parentheses.
+type Import struct {
+	// The name the package is imported as, which is "." for a dot import and
+	// "_" for a blank import.  It is empty when the package is imported by its
+	// own name.
+	Name PosString
+	// The path of the package, including its quotes.
+	Path PosString
+	// The "import" keyword of the declaration the import is in, which all of
+	// the imports of a block share.
+	Keyword PosString
+}
+
+func NewImport(name string, path string) Import {
+	return Import{
+		Name:    NewPosString(name),
+		Path:    NewPosString(path),
+		Keyword: NewPosString("import"),
+	}
+}
+
+// Returns the import as it is written in a Go import declaration, like
+// `h "example.com/helpers"`.
+func (i Import) String() string {
+	if i.Name.Str == "" {
+		return i.Path.Str
+	}
+	return i.Name.Str + " " + i.Path.Str
+}
+
+// Returns the range of the source of the import, from its name, if it has one,
+// to the end of its path.
+func (i Import) Range() Range {
+	if i.Name.Str == "" {
+		return i.Path.Range()
+	}
+	return Range{Start: i.Name.Range().Start, End: i.Path.Range().End}
+}
+
+// Returns the path of the imported package without its quotes.
+func (i Import) PackagePath() string {
+	path, err := strconv.Unquote(i.Path.Str)
+	if err != nil {
+		return i.Path.Str
+	}
+	return path
+}
+
+// Returns the name the package is imported as.  For a package imported by its
+// own name this is the last element of its path, skipping a major version
+// suffix like /v2, which is the name of most packages.
+func (i Import) PackageName() string {
+	if i.Name.Str != "" {
+		return i.Name.Str
+	}
+	pkgPath := i.PackagePath()
+	name := path.Base(pkgPath)
+	if dir := path.Dir(pkgPath); dir != "." && majorVersion(name) {
+		name = path.Base(dir)
+	}
+	return name
+}
+
+// Reports whether an element of an import path is a major version suffix.
+func majorVersion(elem string) bool {
+	digits, ok := strings.CutPrefix(elem, "v")
+	if !ok || digits == "" {
+		return false
+	}
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Reports whether a package can be imported with the given path, following the
+// restrictions the Go compiler places on import paths.
+func validImportPath(path string) bool {
+	if path == "" {
+		return false
+	}
+	for _, r := range path {
+		if !unicode.IsGraphic(r) || unicode.IsSpace(r) || r == utf8.RuneError || strings.ContainsRune("!\"#$%&'()*,:;<=>?[\\]^`{|}", r) {
+			return false
+		}
+	}
+	return true
+}
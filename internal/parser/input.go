@@ -1,9 +1,19 @@
 package parser
 
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// The source being parsed and the offset the parser is at.  Everything the
+// parser takes from the input is a slice of the source, and positions are
+// found with a line table that is built once per source, so that parsing takes
+// time in proportion to the size of the source.
 type input struct {
 	source_ string
 	offset_ int
 	length_ int
+	lines   *LineIndex
 }
 
 func (in *input) apply(length int) string {
@@ -14,15 +24,31 @@ func (in *input) apply(length int) string {
 }
 
 func (in *input) matches(str string) bool {
-	i := 0
-	l := len(str)
-	for i < l {
-		if in.source_[in.offset_+i] != str[i] {
-			return false
-		}
-		i = i + 1
+	return strings.HasPrefix(in.source_[in.offset_:], str)
+}
+
+// Returns the byte at the offset, or 0 at the end of the source.
+func (in *input) peek() byte {
+	if in.offset_ >= in.length_ {
+		return 0
 	}
-	return true
+	return in.source_[in.offset_]
+}
+
+// Returns the rune at the offset and its length in bytes, or utf8.RuneError
+// and 0 at the end of the source.
+func (in *input) peekRune() (rune, int) {
+	return runeAt(in.source_, in.offset_)
+}
+
+func runeAt(source string, offset int) (rune, int) {
+	if offset >= len(source) {
+		return utf8.RuneError, 0
+	}
+	if c := source[offset]; c < utf8.RuneSelf {
+		return rune(c), 1
+	}
+	return utf8.DecodeRuneInString(source[offset:])
 }
 
 func (in *input) advance(increment int) {
@@ -37,6 +63,49 @@ func (in *input) regressTo(offset int) {
 	in.offset_ = offset
 }
 
+// Advances while the runes satisfy a predicate and returns what was advanced
+// over.
+func (in *input) takeWhile(pred func(rune) bool) string {
+	start := in.offset_
+	for in.offset_ < in.length_ {
+		r, size := runeAt(in.source_, in.offset_)
+		if !pred(r) {
+			break
+		}
+		in.offset_ += size
+	}
+	return in.source_[start:in.offset_]
+}
+
+// Advances to the next occurrence of any of the bytes in chars, or to the end
+// of the source if there is none, and returns what was advanced over.
+func (in *input) takeUntilAny(chars string) string {
+	start := in.offset_
+	if i := strings.IndexAny(in.source_[start:], chars); i >= 0 {
+		in.offset_ = start + i
+	} else {
+		in.offset_ = in.length_
+	}
+	return in.source_[start:in.offset_]
+}
+
+// Advances to the next occurrence of stop, or to the end of the source if there
+// is none, and returns what was advanced over.
+func (in *input) takeUntil(stop string) string {
+	start := in.offset_
+	if i := strings.Index(in.source_[start:], stop); i >= 0 {
+		in.offset_ = start + i
+	} else {
+		in.offset_ = in.length_
+	}
+	return in.source_[start:in.offset_]
+}
+
+// Returns the source from an earlier offset up to the current one.
+func (in *input) since(start int) string {
+	return in.source_[start:in.offset_]
+}
+
 func (in *input) isPastEOF(len int) bool {
 	return (in.offset_ + (len - 1)) >= in.length_
 }
@@ -49,8 +118,19 @@ func (in *input) atEnd() bool {
 	return in.isEOF()
 }
 
-func (in *input) pos() {
+// Returns the position of an offset in the source.
+func (in *input) pos(offset int) OffsetPosition {
+	return in.lines.Position(offset)
+}
+
+// Returns the range of the source between two offsets.
+func (in *input) rangeOf(start int, end int) Range {
+	return in.lines.Range(start, end)
+}
 
+// Returns the offset of a line and column in the source.
+func (in *input) offsetOf(line int, column int) int {
+	return in.lines.Offset(line, column)
 }
 
 func (in *input) offset() int {
@@ -65,4 +145,5 @@ func (in *input) reset(source string) {
 	in.offset_ = 0
 	in.source_ = source
 	in.length_ = len(source)
+	in.lines = NewLineIndex(source)
 }
@@ -12,15 +12,28 @@ type Position interface {
 	Line() int
 }
 
+// A position that also knows its column in UTF-16 code units, which is how LSP
+// clients count columns.  Other columns are in bytes.
+type UTF16Position interface {
+	Position
+	UTF16Column() int
+}
+
 type Positional interface {
 	SetPos(pos Position)
 }
 
+// A node that knows the range of the source it was parsed from.
+type Ranged interface {
+	SetRange(r Range)
+}
+
 // posString -----------------------------------------------------------------
 
 type PosString struct {
 	Str string
 	pos Position
+	rng Range
 }
 
 func NewPosString(str string) PosString {
@@ -34,6 +47,19 @@ func (ps *PosString) SetPos(pos Position) {
 	ps.pos = pos
 }
 
+// Returns the range of the source the string was parsed from, which is empty
+// when it wasn't parsed from a source.
+func (ps *PosString) Range() Range {
+	return ps.rng
+}
+
+// Sets the range of the source the string was parsed from, along with its
+// position, which is the start of the range.
+func (ps *PosString) SetRange(r Range) {
+	ps.rng = r
+	ps.pos = r.Start
+}
+
 func (ps PosString) String() string {
 	if ps.pos != nil {
 		return fmt.Sprintf("posString{ str: \"%s\", pos: [%d,%d] }", ps.Str, ps.pos.Line(), ps.pos.Column())
@@ -55,6 +81,13 @@ func (ps *PosString) Column() int {
 	return ps.pos.Column()
 }
 
+func (ps *PosString) UTF16Column() int {
+	if pos, ok := ps.pos.(UTF16Position); ok {
+		return pos.UTF16Column()
+	}
+	return ps.Column()
+}
+
 // Constructor ---------------------------------------------------------------
 
 type Constructor struct {
@@ -93,6 +126,11 @@ const (
 	TT2GoExp
 	TT2BlockComment
 	TT2LineComment
+	TT2Switch
+	TT2Case
+	TT2Default
+	TT2TemplateDef
+	TT2Slot
 )
 
 type MetadataFlag int
@@ -100,6 +138,9 @@ type MetadataFlag int
 const (
 	TTMDEscape MetadataFlag = 1 << iota
     TTMDSafe = 2
+	TTMDRaw  = 4
+	// The transclusion of the expression is made of named slots.
+	TTMDSlots = 8
 )
 
 func (f MetadataFlag) Has(flag MetadataFlag) bool { return f&flag != 0 }
@@ -112,6 +153,11 @@ type TemplateTree2 struct {
 	Children [][]TemplateTree2
 	line     int
 	column   int
+	column16 int
+	// The source of the whole tree, from its '@' up to the end of its last
+	// block, and the source of its text.
+	rng     Range
+	textRng Range
 }
 
 func (tt *TemplateTree2) Line() int {
@@ -122,9 +168,38 @@ func (tt *TemplateTree2) Column() int {
 	return tt.column
 }
 
+func (tt *TemplateTree2) UTF16Column() int {
+	return tt.column16
+}
+
 func (tt *TemplateTree2) SetPos(pos Position) {
 	tt.line = pos.Line()
 	tt.column = pos.Column()
+	tt.column16 = pos.Column()
+	if pos, ok := pos.(UTF16Position); ok {
+		tt.column16 = pos.UTF16Column()
+	}
+}
+
+// Returns the range of the source the tree was parsed from, which is empty
+// when it wasn't parsed from a source.
+func (tt *TemplateTree2) Range() Range {
+	return tt.rng
+}
+
+func (tt *TemplateTree2) SetRange(r Range) {
+	tt.rng = r
+}
+
+// Returns the range of the source of the text of the tree, like the condition
+// of an if or the code of an expression.  Trees without text, like an else,
+// have an empty range at the end of their keyword.
+func (tt *TemplateTree2) TextRange() Range {
+	return tt.textRng
+}
+
+func (tt *TemplateTree2) SetTextRange(r Range) {
+	tt.textRng = r
 }
 
 func NewTT2GoBlock(content string) TemplateTree2 {
@@ -203,6 +278,82 @@ func NewTT2GoExpSafe(content string, escape bool) TemplateTree2 {
     }
 }
 
+func NewTT2GoExpRaw(content string, transclusions [][]TemplateTree2) TemplateTree2 {
+	var metadata MetadataFlag
+	metadata.Set(TTMDRaw)
+	return TemplateTree2{
+		Type:     TT2GoExp,
+		Text:     content,
+		Metadata: metadata,
+		Children: transclusions,
+	}
+}
+
+// The cases of a switch, which are TT2Case and TT2Default trees, are kept in
+// tree.Children[0].
+func NewTT2Switch(expression string, cases []TemplateTree2) TemplateTree2 {
+	return TemplateTree2{
+		Type:     TT2Switch,
+		Text:     expression,
+		Children: [][]TemplateTree2{cases},
+	}
+}
+
+func NewTT2Case(values string, content []TemplateTree2) TemplateTree2 {
+	return TemplateTree2{
+		Type:     TT2Case,
+		Text:     values,
+		Children: [][]TemplateTree2{content},
+	}
+}
+
+func NewTT2Default(content []TemplateTree2) TemplateTree2 {
+	return TemplateTree2{
+		Type:     TT2Default,
+		Children: [][]TemplateTree2{content},
+	}
+}
+
+// A template defined inside of another template.  The declaration is the name
+// of the template followed by its type parameters, if any, and its parameters,
+// like "row(item Item)" or "List[T any](items []T)".
+func NewTT2TemplateDef(declaration string, content []TemplateTree2) TemplateTree2 {
+	return TemplateTree2{
+		Type:     TT2TemplateDef,
+		Text:     declaration,
+		Children: [][]TemplateTree2{content},
+	}
+}
+
+// Returns the name of a defined template, its type parameters including the
+// surrounding brackets, and its parameters including the surrounding
+// parentheses.  The type parameters are empty if the template isn't generic.
+func (tt *TemplateTree2) Declaration() (string, string, string) {
+	idx := strings.IndexAny(tt.Text, "[(")
+	if idx < 0 {
+		return tt.Text, "", "()"
+	}
+	name, rest := tt.Text[:idx], tt.Text[idx:]
+	if rest[0] != '[' {
+		return name, "", rest
+	}
+	end := indexTopLevel(rest[1:], ']') + 2
+	if end < 2 {
+		return name, rest, "()"
+	}
+	return name, rest[:end], rest[end:]
+}
+
+// Content passed to the parameter of a template with the given name, in place
+// of a transclusion.
+func NewTT2Slot(name string, content []TemplateTree2) TemplateTree2 {
+	return TemplateTree2{
+		Type:     TT2Slot,
+		Text:     name,
+		Children: [][]TemplateTree2{content},
+	}
+}
+
 func NewTT2BlockComment(content string) TemplateTree2 {
 	return TemplateTree2{
 		Type: TT2BlockComment,
@@ -232,6 +383,16 @@ func (tt TemplateTree2) String() string {
 		sb.WriteString(fmt.Sprintf("GoElseIf(\"%s\", %v)", tt.Text, tt.Children))
 	case TT2Else:
 		sb.WriteString(fmt.Sprintf("GoElse(%v)", tt.Children))
+	case TT2Switch:
+		sb.WriteString(fmt.Sprintf("GoSwitch(\"%s\", %v)", tt.Text, tt.Children))
+	case TT2Case:
+		sb.WriteString(fmt.Sprintf("GoCase(\"%s\", %v)", tt.Text, tt.Children))
+	case TT2Default:
+		sb.WriteString(fmt.Sprintf("GoDefault(%v)", tt.Children))
+	case TT2TemplateDef:
+		sb.WriteString(fmt.Sprintf("TemplateDef(\"%s\", %v)", tt.Text, tt.Children))
+	case TT2Slot:
+		sb.WriteString(fmt.Sprintf("Slot(\"%s\", %v)", tt.Text, tt.Children))
 	case TT2BlockComment:
 		sb.WriteString(fmt.Sprintf("GoComment(\"%s\")", tt.Text))
 	}
@@ -2,48 +2,159 @@ package parser
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 )
 
 type OffsetPosition struct {
 	column int
 	line   int
+	offset int
 	source string
 }
 
+// Returns the column of the position, in bytes from the start of its line.
 func (op OffsetPosition) Column() int {
 	return op.column
 }
 
+// Returns the column of the position in UTF-16 code units from the start of
+// its line, which is how LSP clients count columns.
+func (op OffsetPosition) UTF16Column() int {
+	return utf16Len(op.source[:op.column])
+}
+
 func (op OffsetPosition) Line() int {
 	return op.line
 }
 
+// Returns the byte offset of the position in the source.
+func (op OffsetPosition) Offset() int {
+	return op.offset
+}
+
 func (op OffsetPosition) String() string {
 	return fmt.Sprintf("[%d:%d]", op.line, op.column)
 }
 
-func NewOffsetPosition(source string, offset int) OffsetPosition {
-	bytes := []byte(source)
-	lines := 1
-	column := 0
-	left := offset
-	for _, byte := range bytes {
-		if left == 0 {
+// An index of where the lines of a source start, so that the position of an
+// offset can be found without scanning the source up to it.
+type LineIndex struct {
+	source string
+	starts []int
+}
+
+func NewLineIndex(source string) *LineIndex {
+	starts := []int{0}
+	for i := strings.IndexByte(source, '\n'); i >= 0; {
+		starts = append(starts, starts[len(starts)-1]+i+1)
+		i = strings.IndexByte(source[starts[len(starts)-1]:], '\n')
+	}
+	return &LineIndex{source: source, starts: starts}
+}
+
+// Returns the position of an offset in the source, which is clamped to the
+// bounds of the source.
+func (li *LineIndex) Position(offset int) OffsetPosition {
+	if offset < 0 {
+		offset = 0
+	} else if offset > len(li.source) {
+		offset = len(li.source)
+	}
+	line := sort.Search(len(li.starts), func(i int) bool {
+		return li.starts[i] > offset
+	})
+	start := li.starts[line-1]
+	end := len(li.source)
+	if line < len(li.starts) {
+		end = li.starts[line] - 1
+	}
+	return OffsetPosition{
+		line:   line,
+		column: offset - start,
+		offset: offset,
+		source: li.source[start:end],
+	}
+}
+
+// Returns the offset of a line and column in the source, which is the inverse
+// of Position.
+func (li *LineIndex) Offset(line int, column int) int {
+	if line < 1 {
+		return 0
+	}
+	if line > len(li.starts) {
+		return len(li.source)
+	}
+	offset := li.starts[line-1] + column
+	if offset > len(li.source) {
+		return len(li.source)
+	}
+	return offset
+}
+
+// Returns the position of a line and a column in UTF-16 code units, which is
+// how LSP clients count columns.  A column in the middle of a character is the
+// position of the character, and one past the end of the line is the end of
+// the line.
+func (li *LineIndex) PositionUTF16(line int, column int) OffsetPosition {
+	offset := li.Offset(line, 0)
+	for column > 0 {
+		r, size := runeAt(li.source, offset)
+		if size == 0 || r == '\n' {
 			break
 		}
-		if byte == '\n' {
-			lines += 1
-			column = 0
-		} else {
-			column += 1
+		column -= utf16RuneLen(r)
+		if column < 0 {
+			break
 		}
-		left -= 1
+		offset += size
 	}
-	sourceLines := strings.Split(source, "\n")
-	return OffsetPosition{
-		line:   lines,
-		column: column,
-		source: sourceLines[lines-1],
+	return li.Position(offset)
+}
+
+// Returns the range of the source between two offsets.
+func (li *LineIndex) Range(start int, end int) Range {
+	return Range{Start: li.Position(start), End: li.Position(end)}
+}
+
+// A range of the source, from its start up to but not including its end.
+type Range struct {
+	Start OffsetPosition
+	End   OffsetPosition
+}
+
+// Reports whether the range covers no source at all, which is the case for the
+// nodes that weren't parsed from a source.
+func (r Range) IsEmpty() bool {
+	return r.End.offset <= r.Start.offset
+}
+
+// Reports whether a position is within the range.
+func (r Range) Contains(pos Position) bool {
+	return !r.IsEmpty() && !positionBefore(pos, r.Start) && positionBefore(pos, r.End)
+}
+
+func (r Range) String() string {
+	return fmt.Sprintf("%v-%v", r.Start, r.End)
+}
+
+// Returns the length of a string in UTF-16 code units.
+func utf16Len(s string) int {
+	length := 0
+	for _, r := range s {
+		length += utf16RuneLen(r)
 	}
+	return length
+}
+
+func utf16RuneLen(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+func positionBefore(a Position, b Position) bool {
+	return a.Line() < b.Line() || (a.Line() == b.Line() && a.Column() < b.Column())
 }
@@ -18,7 +18,7 @@ var source = `Y#####
 
 func TestOffsetPositionBeginningOfFile(t *testing.T) {
 	i := strings.IndexByte(source, 'Y')
-	pos := parser.NewOffsetPosition(source, i)
+	pos := parser.NewLineIndex(source).Position(i)
 
 	if pos.Line() != 1 {
 		t.Fatalf("Expected line to be 1, got %v", pos.Line())
@@ -30,7 +30,7 @@ func TestOffsetPositionBeginningOfFile(t *testing.T) {
 
 func TestOffsetPositionMiddleOfFile(t *testing.T) {
 	i := strings.IndexByte(source, 'X')
-	pos := parser.NewOffsetPosition(source, i)
+	pos := parser.NewLineIndex(source).Position(i)
 
 	if pos.Line() != 3 {
 		t.Fatalf("Expected line to be 3, got %v", pos.Line())
@@ -42,7 +42,7 @@ func TestOffsetPositionMiddleOfFile(t *testing.T) {
 
 func TestOffsetPositionAlmostEndOfFile(t *testing.T) {
 	i := strings.IndexByte(source, 'Z')
-	pos := parser.NewOffsetPosition(source, i)
+	pos := parser.NewLineIndex(source).Position(i)
 
 	if pos.Line() != 7 {
 		t.Fatalf("Expected line to be 7, got %v", pos.Line())
@@ -53,7 +53,7 @@ func TestOffsetPositionAlmostEndOfFile(t *testing.T) {
 }
 
 func TestOffsetPositionEndOfFile(t *testing.T) {
-	pos := parser.NewOffsetPosition(source, len(source))
+	pos := parser.NewLineIndex(source).Position(len(source))
 
 	if pos.Line() != 8 {
 		t.Fatalf("Expected line to be 8, got %v", pos.Line())
@@ -62,3 +62,47 @@ func TestOffsetPositionEndOfFile(t *testing.T) {
 		t.Fatalf("Expected column to be 0, got %v", pos.Column())
 	}
 }
+
+func TestLineIndex(t *testing.T) {
+	index := parser.NewLineIndex(source)
+	line, column := 1, 0
+	for offset := 0; offset <= len(source); offset++ {
+		pos := index.Position(offset)
+		if pos.Line() != line || pos.Column() != column || pos.Offset() != offset {
+			t.Fatalf("Expected %d to be at %d:%d, got %d:%d at %d", offset, line, column, pos.Line(), pos.Column(), pos.Offset())
+		}
+		if back := index.Offset(pos.Line(), pos.Column()); back != offset {
+			t.Fatalf("Expected %d:%d to be at offset %d, got %d", line, column, offset, back)
+		}
+		if offset < len(source) && source[offset] == '\n' {
+			line, column = line+1, 0
+		} else {
+			column++
+		}
+	}
+	if pos := index.Position(len(source) + 10); pos.Offset() != len(source) {
+		t.Fatalf("Expected offsets past the end to be clamped, got %d", pos.Offset())
+	}
+}
+
+func TestLineIndexUTF16(t *testing.T) {
+	// "é" is two bytes and one UTF-16 code unit, and "🎉" is four bytes and
+	// two code units.
+	source := "@(x int)\n<p>é🎉 @x</p>\n"
+	index := parser.NewLineIndex(source)
+	x := strings.LastIndex(source, "x")
+	pos := index.Position(x)
+	if pos.Column() != 11 || pos.UTF16Column() != 8 {
+		t.Fatalf("Expected x to be at byte column 11 and UTF-16 column 8, got %d and %d", pos.Column(), pos.UTF16Column())
+	}
+	if back := index.PositionUTF16(2, 8); back.Offset() != x {
+		t.Fatalf("Expected UTF-16 column 8 to be at offset %d, got %d", x, back.Offset())
+	}
+	// A column in the middle of the emoji is the position of the emoji.
+	if mid := index.PositionUTF16(2, 5); mid.Column() != 5 {
+		t.Fatalf("Expected the middle of the emoji to be at column 5, got %d", mid.Column())
+	}
+	if end := index.PositionUTF16(2, 100); end.Offset() != len(source)-1 {
+		t.Fatalf("Expected columns past the end of a line to be at its end, got %d", end.Offset())
+	}
+}
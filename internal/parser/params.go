@@ -0,0 +1,164 @@
+package parser
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Param is a single parameter of a template.  Default is the Go expression
+// written after the type, or empty when the parameter is required.
+type Param struct {
+	Name    string
+	Type    string
+	Default string
+	// The source of the parameter, from its name up to the end of its type or
+	// default value.  It is empty unless the parameter was parsed with
+	// ParseParamsAt or ParseTypeParamsAt.
+	Range Range
+}
+
+// Returns the parameter the way it is written in a Go function signature.
+func (p Param) String() string {
+	return p.Name + " " + p.Type
+}
+
+// Splits the parameters of a template, including the surrounding parentheses,
+// into each parameter.  Parameters that share a type, like "(a, b string)", are
+// each given that type.
+func ParseParams(params string) []Param {
+	return ParseParamsAt(params, 0, nil)
+}
+
+// Splits parameters like ParseParams, for parameters found at an offset of a
+// source, and gives each parameter the range of the source it was parsed from.
+func ParseParamsAt(params string, offset int, lines *LineIndex) []Param {
+	list, start := trimList(params, "(", ")")
+	return parseParamList(list, offset+start, lines)
+}
+
+// Splits the type parameters of a template, including the surrounding brackets,
+// into each type parameter.  The type of each is its constraint.
+func ParseTypeParams(typeParams string) []Param {
+	return ParseTypeParamsAt(typeParams, 0, nil)
+}
+
+// Splits type parameters like ParseTypeParams, for type parameters found at an
+// offset of a source, and gives each of them the range of the source it was
+// parsed from.
+func ParseTypeParamsAt(typeParams string, offset int, lines *LineIndex) []Param {
+	list, start := trimList(typeParams, "[", "]")
+	return parseParamList(list, offset+start, lines)
+}
+
+// Returns a list without the space and the brackets around it, along with the
+// offset of what is left in the list.
+func trimList(list string, open string, close string) (string, int) {
+	trimmed := strings.TrimLeftFunc(list, unicode.IsSpace)
+	start := len(list) - len(trimmed)
+	if strings.HasPrefix(trimmed, open) {
+		trimmed = trimmed[len(open):]
+		start += len(open)
+	}
+	trimmed = strings.TrimRightFunc(trimmed, unicode.IsSpace)
+	return strings.TrimSuffix(trimmed, close), start
+}
+
+// Returns the type arguments that pass each of the type parameters to a generic
+// type, like "[K, V]" for "[K comparable, V any]".
+func TypeArgs(typeParams string) string {
+	params := ParseTypeParams(typeParams)
+	if len(params) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(params))
+	for _, p := range params {
+		names = append(names, p.Name)
+	}
+	return "[" + strings.Join(names, ", ") + "]"
+}
+
+// Splits a list of parameters that starts at an offset of a source, giving each
+// parameter its range when the lines of the source are known.
+func parseParamList(params string, offset int, lines *LineIndex) []Param {
+	result := []Param{}
+	for _, part := range SplitArgs(params) {
+		arg := strings.TrimSpace(part)
+		start := offset + len(part) - len(strings.TrimLeftFunc(part, unicode.IsSpace))
+		offset += len(part) + len(",")
+		rng := Range{}
+		if lines != nil {
+			rng = lines.Range(start, start+len(arg))
+		}
+		def := ""
+		if idx := indexTopLevel(arg, '='); idx >= 0 {
+			def = strings.TrimSpace(arg[idx+1:])
+			arg = strings.TrimSpace(arg[:idx])
+		}
+		name, typ, _ := strings.Cut(arg, " ")
+		result = append(result, Param{Name: name, Type: strings.TrimSpace(typ), Default: def, Range: rng})
+	}
+	for i := len(result) - 2; i >= 0; i-- {
+		if result[i].Type == "" {
+			result[i].Type = result[i+1].Type
+		}
+	}
+	return result
+}
+
+// Reports whether any of the parameters has a default value.
+func HasDefaults(params []Param) bool {
+	for _, p := range params {
+		if p.Default != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Splits a comma separated list of Go expressions or parameters, ignoring the
+// commas that are inside of brackets or string literals.  Each part is returned
+// as written, and an empty list returns no parts.
+func SplitArgs(list string) []string {
+	if strings.TrimSpace(list) == "" {
+		return []string{}
+	}
+	parts := []string{}
+	for {
+		idx := indexTopLevel(list, ',')
+		if idx < 0 {
+			return append(parts, list)
+		}
+		parts = append(parts, list[:idx])
+		list = list[idx+1:]
+	}
+}
+
+// Returns the index of the first c that is not inside of brackets or a string
+// literal, or -1 if there is none.
+func indexTopLevel(s string, c byte) int {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if quote != 0 {
+			if ch == '\\' && quote != '`' {
+				i++
+			} else if ch == quote {
+				quote = 0
+			}
+			continue
+		}
+		if ch == c && depth == 0 {
+			return i
+		}
+		switch ch {
+		case '"', '\'', '`':
+			quote = ch
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		}
+	}
+	return -1
+}
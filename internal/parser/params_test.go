@@ -0,0 +1,96 @@
+package parser_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gamebox/gwirl/internal/parser"
+)
+
+func TestParseParams(t *testing.T) {
+	tests := []struct {
+		params   string
+		expected []parser.Param
+	}{
+		{"()", []parser.Param{}},
+		{"(name string)", []parser.Param{{Name: "name", Type: "string"}}},
+		{"(a, b string, c int)", []parser.Param{{Name: "a", Type: "string"}, {Name: "b", Type: "string"}, {Name: "c", Type: "int"}}},
+		{"(m map[string]int, f func(a, b int) string)", []parser.Param{{Name: "m", Type: "map[string]int"}, {Name: "f", Type: "func(a, b int) string"}}},
+		{"(title string, body gwirl.HTML)", []parser.Param{{Name: "title", Type: "string"}, {Name: "body", Type: "gwirl.HTML"}}},
+		{
+			"(title string = \"a = b, c\", compact bool = n == 0, tags []string = []string{\"x\"})",
+			[]parser.Param{{Name: "title", Type: "string", Default: "\"a = b, c\""}, {Name: "compact", Type: "bool", Default: "n == 0"}, {Name: "tags", Type: "[]string", Default: "[]string{\"x\"}"}},
+		},
+	}
+	for _, test := range tests {
+		params := parser.ParseParams(test.params)
+		if !reflect.DeepEqual(params, test.expected) {
+			t.Errorf("Expected %v for \"%s\", got %v", test.expected, test.params, params)
+		}
+	}
+}
+
+func TestParseParamsAt(t *testing.T) {
+	source := "@( items []Item, item Item = Item{},  count int)\n<p>@item</p>"
+	p := parser.NewParser2("")
+	result := p.Parse(source, "Test")
+	params := parser.ParseParamsAt(result.Template.Params.Str, result.Template.Params.Range().Start.Offset(), result.Lines())
+	expected := []string{"items []Item", "item Item = Item{}", "count int"}
+	if len(params) != len(expected) {
+		t.Fatalf("Expected %d params, got %v", len(expected), params)
+	}
+	for i, param := range params {
+		rng := param.Range
+		if got := source[rng.Start.Offset():rng.End.Offset()]; got != expected[i] {
+			t.Errorf("Expected the range of %s to be %q, got %q", param.Name, expected[i], got)
+		}
+	}
+	if start := params[1].Range.Start; start.Line() != 1 || start.Column() != 17 {
+		t.Errorf("Expected item to start at 1:17, got %v", start)
+	}
+	typeParams := parser.ParseTypeParamsAt(" [K comparable, V any]", 10, parser.NewLineIndex(strings.Repeat(" ", 40)))
+	if len(typeParams) != 2 || typeParams[1].Range.Start.Offset() != 26 {
+		t.Errorf("Expected V to start at 26, got %v", typeParams)
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		list     string
+		expected []string
+	}{
+		{"", []string{}},
+		{"a", []string{"a"}},
+		{"\"a, b\", f(x, y), []int{1, 2}", []string{"\"a, b\"", " f(x, y)", " []int{1, 2}"}},
+		{"'\\'', `,`", []string{"'\\''", " `,`"}},
+	}
+	for _, test := range tests {
+		parts := parser.SplitArgs(test.list)
+		if !reflect.DeepEqual(parts, test.expected) {
+			t.Errorf("Expected %q for %q, got %q", test.expected, test.list, parts)
+		}
+	}
+}
+
+func TestParseParamDefaultErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		err    string
+	}{
+		{"@(title string = \"Untitled\", body gwirl.HTML)\n<p>@title</p>", "Parameter body must have a default value, since it follows a parameter with one"},
+		{"@row(compact bool = false) = {<tr></tr>}", "Parameters of row can't have default values, only exported templates can"},
+	}
+	for _, test := range tests {
+		p := parser.NewParser2("")
+		result := p.Parse(test.source, "Test")
+		if len(result.Errors) != 1 || result.Errors[0].Err != test.err {
+			t.Errorf("Expected the error %q for %q, got %v", test.err, test.source, result.Errors)
+		}
+	}
+	p := parser.NewParser2("")
+	result := p.Parse("@(body gwirl.HTML, title string = \"(Untitled)\")\n<p>@title</p>", "Test")
+	if len(result.Errors) > 0 {
+		t.Errorf("Unexpected errors: %v", result.Errors)
+	}
+}
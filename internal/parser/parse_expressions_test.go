@@ -17,6 +17,13 @@ var expressionTests = []ParsingTest{
     {"complex method with chaining", "@foo.bar().something.else\"", parser.NewTT2GoExp("foo.bar().something.else", false, noChildren)},
     {"complex method with params with chaining", "@foo.bar(param1, param2).something.else\"", parser.NewTT2GoExp("foo.bar(param1, param2).something.else", false, noChildren)},
     {"complex method with literal params with chaining", "@foo.bar(\"hello\", 123).something.else\"", parser.NewTT2GoExp("foo.bar(\"hello\", 123).something.else", false, noChildren)},
+    {"generic method with type arguments", "@List[int, string](items)\"", parser.NewTT2GoExp("List[int, string](items)", false, noChildren)},
+    {"brackets without a call", "@items[0]\"", parser.NewTT2GoExp("items", false, noChildren)},
+    {"non-ASCII identifier", "@préférence\"", parser.NewTT2GoExp("préférence", false, noChildren)},
+    {"identifier with underscores", "@_user_name\"", parser.NewTT2GoExp("_user_name", false, noChildren)},
+    {"non-ASCII method with chaining", "@données.Été(année2).Δx\"", parser.NewTT2GoExp("données.Été(année2).Δx", false, noChildren)},
+    {"identifier stops at non-ASCII punctuation", "@prix€\"", parser.NewTT2GoExp("prix", false, noChildren)},
+    {"method with parentheses in string params", "@foo.bar(\")\", \"\\\"(\")\"", parser.NewTT2GoExp("foo.bar(\")\", \"\\\"(\")", false, noChildren)},
    
     // Transclusion tests
     {
@@ -66,3 +73,25 @@ func TestExpressionParsing(t *testing.T) {
     },"")
 }
 
+
+func TestExpressionBracketsWithoutCall(t *testing.T) {
+    tests := []struct {
+        source string
+        plain  string
+    }{
+        {"@(items []string)\n<p>@items[0]</p>", "[0]</p>"},
+        {"@(x string)\n<p>@x[</p>", "[</p>"},
+        {"@(x []string)\n<p>@x[f(1)] (y)</p>", "[f(1)] (y)</p>"},
+    }
+    for _, test := range tests {
+        p := parser.NewParser2("")
+        result := p.Parse(test.source, "Test")
+        if len(result.Errors) > 0 {
+            t.Fatalf("Unexpected errors for %q: %v", test.source, result.Errors)
+        }
+        content := result.Template.Content
+        if len(content) != 3 || content[1].Type != parser.TT2GoExp || content[2].Text != test.plain {
+            t.Fatalf("Expected the expression to end before the brackets in %q, got %v", test.source, content)
+        }
+    }
+}
@@ -0,0 +1,84 @@
+package parser_test
+
+import (
+	"testing"
+
+	"github.com/gamebox/gwirl/internal/parser"
+)
+
+func TestParseImports(t *testing.T) {
+	source := "@(name string)\n" +
+		"@import \"fmt\"\n" +
+		"\n" +
+		"@import h `example.com/helpers` // for formatting\n" +
+		"@import (\n" +
+		"\t. \"example.com/dot\"\n" +
+		"\t// blank imports only run the package\n" +
+		"\t_ \"example.com/blank\"; \"strings\"\n" +
+		")\n" +
+		"\n<p>@name</p>"
+	p := parser.NewParser2("")
+	result := p.Parse(source, "Test")
+	if len(result.Errors) > 0 {
+		t.Fatalf("Unexpected errors: %v", result.Errors)
+	}
+	expected := []string{`"fmt"`, "h `example.com/helpers`", `. "example.com/dot"`, `_ "example.com/blank"`, `"strings"`}
+	imports := result.Template.TopImports
+	if len(imports) != len(expected) {
+		t.Fatalf("Expected %d imports, got %v", len(expected), imports)
+	}
+	for i, imp := range imports {
+		if imp.String() != expected[i] {
+			t.Errorf("Expected import %s, got %s", expected[i], imp.String())
+		}
+	}
+	if imports[1].PackagePath() != "example.com/helpers" {
+		t.Errorf("Expected the path example.com/helpers, got %s", imports[1].PackagePath())
+	}
+	if imports[2].Name.Line() != 6 || imports[2].Name.Column() != 1 || imports[2].Path.Column() != 3 {
+		t.Errorf("Unexpected position %d:%d for %v", imports[2].Name.Line(), imports[2].Name.Column(), imports[2])
+	}
+	if imports[2].Keyword.Line() != 5 || imports[3].Keyword.Line() != 5 {
+		t.Errorf("Expected the imports of the block to share its keyword")
+	}
+	content := result.Template.Content
+	if len(content) == 0 || content[0].Text != "\n<p>" {
+		t.Errorf("Expected the content to start after the imports, got %v", content)
+	}
+}
+
+func TestParseBlankAndDotImports(t *testing.T) {
+	p := parser.NewParser2("")
+	result := p.Parse("@import _ \"a/x\"\n@import . \"b/y\"\n@import _ \"c/z\"\n@import . \"d/w\"\n", "Test")
+	if len(result.Errors) != 0 {
+		t.Fatalf("Expected blank and dot imports not to collide, got %v", result.Errors)
+	}
+}
+
+func TestParseImportErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		err    string
+	}{
+		{"missing path", "@import fmt\n", `Expected an import path, found "fmt"`},
+		{"invalid path", "@import \"has space\"\n", `Invalid import path "has space"`},
+		{"unclosed path", "@import \"fmt\n", `Invalid import path "fmt`},
+		{"trailing text", "@import \"fmt\" extra\n", `Unexpected "extra" after import`},
+		{"invalid name", "@import 1h \"fmt\"\n", "Invalid import name 1h"},
+		{"unclosed block", "@import (\n\t\"fmt\"\n", "Expected ')' to close the import block"},
+		{"duplicate", "@import \"fmt\"\n@import (\n\t\"fmt\"\n)\n", `"fmt" is already imported`},
+		{"duplicate with a name", "@import \"fmt\"\n@import fmt \"fmt\"\n", `"fmt" is already imported`},
+		{"same name", "@import \"a/x\"\n@import \"b/x\"\n", `x is already the name of "a/x", import "b/x" under another name`},
+		{"same name as a version", "@import \"a/x\"\n@import \"b/x/v2\"\n", `x is already the name of "a/x", import "b/x/v2" under another name`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := parser.NewParser2("")
+			result := p.Parse(test.source, "Test")
+			if len(result.Errors) != 1 || result.Errors[0].Err != test.err {
+				t.Errorf("Expected the error %q, got %v", test.err, result.Errors)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package parser_test
+
+import (
+	"testing"
+
+	"github.com/gamebox/gwirl/internal/parser"
+)
+
+const rangesSource = `@(items []Item, title string)
+@import h "example.com/helpers"
+
+<h1>@h.Title(title)</h1>
+@for _, item := range items {
+	@if item.Done {
+		<s>@item.Name</s>
+	} @else {
+		@item.Name
+	}
+}
+@Card(title string) = {
+	<div>@title</div>
+}
+`
+
+func sourceOf(r parser.Range) string {
+	return rangesSource[r.Start.Offset():r.End.Offset()]
+}
+
+func TestParseRanges(t *testing.T) {
+	p := parser.NewParser2("")
+	result := p.Parse(rangesSource, "Test")
+	if len(result.Errors) > 0 {
+		t.Fatalf("Expected no errors, got %v", result.Errors)
+	}
+	template := result.Template
+	content := template.Content
+	forTree := content[3]
+	ifTree := forTree.Children[0][1]
+	elseTree := ifTree.Children[2][0]
+	card := template.Templates[0]
+
+	tests := []struct {
+		name     string
+		r        parser.Range
+		expected string
+	}{
+		{"template", template.Range(), rangesSource},
+		{"params", template.Params.Range(), "(items []Item, title string)"},
+		{"import", template.TopImports[0].Range(), `h "example.com/helpers"`},
+		{"import keyword", template.TopImports[0].Keyword.Range(), "import"},
+		{"expression", content[1].Range(), "@h.Title(title)"},
+		{"expression code", content[1].TextRange(), "h.Title(title)"},
+		{"plain", content[2].Range(), "</h1>\n"},
+		{"for clause", forTree.TextRange(), " _, item := range items "},
+		{"if condition", ifTree.TextRange(), " item.Done "},
+		{"if", ifTree.Range(), "@if item.Done {\n\t\t<s>@item.Name</s>\n\t} @else {\n\t\t@item.Name\n\t}"},
+		{"else", elseTree.Range(), "@else {\n\t\t@item.Name\n\t}"},
+		{"nested expression", ifTree.Children[0][1].Range(), "@item.Name"},
+		{"defined template", card.Range(), "@Card(title string) = {\n\t<div>@title</div>\n}"},
+		{"defined template name", card.Name.Range(), "Card"},
+		{"defined template params", card.Params.Range(), "(title string)"},
+	}
+	for _, test := range tests {
+		if got := sourceOf(test.r); got != test.expected {
+			t.Errorf("Expected the range of the %s to be %q, got %q", test.name, test.expected, got)
+		}
+	}
+
+	start := ifTree.TextRange().Start
+	if start.Line() != 6 || start.Column() != 4 {
+		t.Errorf("Expected the if condition to start at 6:4, got %v", start)
+	}
+	if !forTree.Range().Contains(ifTree.Range().End) {
+		t.Errorf("Expected the for to contain the end of the if")
+	}
+	if content[1].Range().Contains(content[2].Range().Start) {
+		t.Errorf("Expected the expression to not contain the start of the next tree")
+	}
+}
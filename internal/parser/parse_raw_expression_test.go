@@ -0,0 +1,24 @@
+package parser_test
+
+import (
+	"testing"
+
+	"github.com/gamebox/gwirl/internal/parser"
+)
+
+var rawExpressionTests = []ParsingTest{
+	{"simple expression", "@raw(foobar)a", parser.NewTT2GoExpRaw("foobar", noChildren)},
+	{"complex expression", "@raw(foo.bar)a", parser.NewTT2GoExpRaw("foo.bar", noChildren)},
+	{"method with literal params", "@raw(foo.bar(\"hello\", 123))a", parser.NewTT2GoExpRaw("foo.bar(\"hello\", 123)", noChildren)},
+	{
+		"method with transclusion",
+		"@raw(foobar()) {\n\t<div>Hello</div>\n}",
+		parser.NewTT2GoExpRaw("foobar()", simpleTransclusionChildren),
+	},
+}
+
+func TestParseRawExpression(t *testing.T) {
+	runParserTest(rawExpressionTests, t, func(p *parser.Parser2) *parser.TemplateTree2 {
+		return p.RawExpression()
+	}, "")
+}
@@ -0,0 +1,114 @@
+package parser_test
+
+import (
+	"testing"
+
+	"github.com/gamebox/gwirl/internal/parser"
+)
+
+type expectedError struct {
+	err  string
+	line int
+}
+
+func TestParseRecovery(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []expectedError
+	}{
+		{
+			"invalid @ symbols",
+			"@(x int)\n<p>@ a</p>\n@if x > 0 {\n<p>@ b</p>\n}\n",
+			[]expectedError{{"Invalid '@' symbol", 2}, {"Invalid '@' symbol", 4}},
+		},
+		{
+			"stray closing braces",
+			"<p>a}</p>\n<p>b}</p>\n",
+			[]expectedError{{"Unexpected '}', use @} to output a brace", 1}, {"Unexpected '}', use @} to output a brace", 2}},
+		},
+		{
+			"unclosed string",
+			"<p>@fmt.Sprint(\"a)</p>\n<p>@ b</p>\n",
+			[]expectedError{{"Unexpected newline", 1}, {"Invalid '@' symbol", 2}},
+		},
+		{
+			"unclosed brackets",
+			"@{ x := []int{1\n<p>@ b</p>\n",
+			[]expectedError{{"Expected '}', got end of file", 1}, {"Invalid '@' symbol", 2}},
+		},
+		{
+			"if without a block",
+			"@(x bool)\n@if x\n<p>a</p>\n<p>@ b</p>\n",
+			[]expectedError{{"Expected a block for if", 2}, {"Invalid '@' symbol", 4}},
+		},
+		{
+			"else without a block",
+			"@(x bool)\n@if x {a} @else b\n<p>@ c</p>\n",
+			[]expectedError{{"Expected a block for else", 2}, {"Invalid '@' symbol", 3}},
+		},
+		{
+			"switch with errors",
+			"@(x int)\n@switch x {\n<p>hi</p>\n@case 1 {a}\n@case {b}\n}\n<p>@ c</p>\n",
+			[]expectedError{{"Expected @case or @default in switch", 3}, {"No values found for case", 5}, {"Invalid '@' symbol", 7}},
+		},
+		{
+			"unclosed block before a definition",
+			"@if true {\n<p>a</p>\n@Card() = {\n<p>@ b</p>\n}\n",
+			[]expectedError{{"Expected '}' to close the block", 1}, {"Invalid '@' symbol", 4}},
+		},
+		{
+			"unclosed comment",
+			"<p>a</p>\n@* b\n<p>@ c</p>\n",
+			[]expectedError{{"Expected '*@' to close the comment, found end of file", 2}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := parser.NewParser2("")
+			result := p.Parse(test.input, "Test")
+			if len(result.Errors) != len(test.expected) {
+				t.Fatalf("Expected %d errors, got %v", len(test.expected), result.Errors)
+			}
+			for i, expected := range test.expected {
+				got := result.Errors[i]
+				if got.Err != expected.err || got.Start.Line() != expected.line {
+					t.Errorf("Expected \"%s\" on line %d, got \"%s\" on line %d", expected.err, expected.line, got.Err, got.Start.Line())
+				}
+			}
+		})
+	}
+}
+
+func TestParseRecoveryKeepsContent(t *testing.T) {
+	p := parser.NewParser2("")
+	result := p.Parse("@(x int)\n<p>a}</p>\n@if x > 0 {\n<p>b</p>\n@Card() = {\n<p>@x</p>\n}\n", "Test")
+	if len(result.Errors) != 2 {
+		t.Fatalf("Expected 2 errors, got %v", result.Errors)
+	}
+	if len(result.Template.Templates) != 1 || result.Template.Templates[0].Name.Str != "Card" {
+		t.Fatalf("Expected Card to be parsed after the unclosed block, got %v", result.Template.Templates)
+	}
+	content := result.Template.Content
+	if len(content) < 3 || content[2].Type != parser.TT2If {
+		t.Fatalf("Expected the content around the errors to be parsed, got %v", content)
+	}
+	card := result.Template.Templates[0].Content
+	if len(card) != 3 || card[1].Type != parser.TT2GoExp {
+		t.Fatalf("Expected the content of Card to be parsed, got %v", card)
+	}
+}
+
+func TestParseKeywordPrefixes(t *testing.T) {
+	p := parser.NewParser2("")
+	result := p.Parse("<iframe src=\"@iframe\"></iframe>@format(x) {a}@elsewhere@iféé", "Test")
+	if len(result.Errors) > 0 {
+		t.Fatalf("Expected no errors, got %v", result.Errors)
+	}
+	content := result.Template.Content
+	for _, i := range []int{1, 3, 4, 5} {
+		if content[i].Type != parser.TT2GoExp {
+			t.Errorf("Expected an expression at %d, got %v", i, content[i])
+		}
+	}
+}
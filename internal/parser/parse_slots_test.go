@@ -0,0 +1,68 @@
+package parser_test
+
+import (
+	"testing"
+
+	"github.com/gamebox/gwirl/internal/parser"
+)
+
+func slotsExpression(content string, slots []parser.TemplateTree2) parser.TemplateTree2 {
+	t := parser.NewTT2GoExp(content, false, [][]parser.TemplateTree2{slots})
+	t.Metadata.Set(parser.TTMDSlots)
+	return t
+}
+
+var slotsTests = []ParsingTest{
+	{
+		"named slots",
+		"@Card(\"t\") {\n\t@slot footer {<button>Ok</button>}\n\t@* the body *@\n\t@slot body {<p>@text</p>}\n}",
+		slotsExpression("Card(\"t\")", []parser.TemplateTree2{
+			parser.NewTT2Slot("footer", []parser.TemplateTree2{parser.NewTT2Plain("<button>Ok</button>")}),
+			parser.NewTT2Slot("body", []parser.TemplateTree2{
+				parser.NewTT2Plain("<p>"),
+				parser.NewTT2GoExp("text", false, noChildren),
+				parser.NewTT2Plain("</p>"),
+			}),
+		}),
+	},
+	{
+		"positional transclusions",
+		"@Card(\"t\") {\n\t<div>Hello</div>\n}",
+		parser.NewTT2GoExp("Card(\"t\")", false, simpleTransclusionChildren),
+	},
+}
+
+func TestParseSlots(t *testing.T) {
+	runParserTest(slotsTests, t, func(p *parser.Parser2) *parser.TemplateTree2 {
+		return p.Mixed()
+	}, "")
+}
+
+func TestParseSlotErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		err   string
+		line  int
+	}{
+		{"mixed with content", "@()\n@Card() {\n@slot body {a}\n<p>b</p>\n}", "Only @slot can be used in a transclusion with named slots", 3},
+		{"duplicate slot", "@()\n@Card() {\n@slot body {a}\n@slot body {b}\n}", "Slot body is already defined", 4},
+		{"second transclusion", "@()\n@Card() {a} {\n@slot body {b}\n}", "Named slots can't be used with more than one transclusion", 3},
+		{"outside of a call", "@()\n<div>\n@slot body {a}\n</div>", "@slot can only be used in the transclusion of a template call", 3},
+		{"inside of an if", "@()\n@Card() {\n@slot body {@if true {\n@slot x {a}\n}}\n}", "@slot can only be used in the transclusion of a template call", 4},
+		{"no name", "@()\n@Card() {\n@slot {a}\n}", "Expected a name for slot", 3},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := parser.NewParser2("")
+			result := p.Parse(test.input, "Test")
+			if len(result.Errors) == 0 {
+				t.Fatalf("Expected an error")
+			}
+			err := result.Errors[0]
+			if err.Err != test.err || err.Start.Line() != test.line {
+				t.Fatalf("Expected error \"%s\" on line %d, got \"%s\" on line %d", test.err, test.line, err.Err, err.Start.Line())
+			}
+		})
+	}
+}
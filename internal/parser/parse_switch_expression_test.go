@@ -0,0 +1,65 @@
+package parser_test
+
+import (
+	"testing"
+
+	"github.com/gamebox/gwirl/internal/parser"
+)
+
+var switchExpressionTests = []ParsingTest{
+	{
+		"cases and default",
+		"@switch status {\n\t@case \"ok\", \"done\" {<b>ok</b>}\n\t@default {<b>@status</b>}\n}",
+		parser.NewTT2Switch(" status ", []parser.TemplateTree2{
+			parser.NewTT2Case(" \"ok\", \"done\" ", []parser.TemplateTree2{parser.NewTT2Plain("<b>ok</b>")}),
+			parser.NewTT2Default([]parser.TemplateTree2{
+				parser.NewTT2Plain("<b>"),
+				parser.NewTT2GoExp("status", false, noChildren),
+				parser.NewTT2Plain("</b>"),
+			}),
+		}),
+	},
+	{
+		"without an expression",
+		"@switch {\n@* comment *@\n@case n > 1 {many}\n}",
+		parser.NewTT2Switch(" ", []parser.TemplateTree2{
+			parser.NewTT2Case(" n > 1 ", []parser.TemplateTree2{parser.NewTT2Plain("many")}),
+		}),
+	},
+	{
+		"no cases",
+		"@switch x {}",
+		parser.NewTT2Switch(" x ", []parser.TemplateTree2{}),
+	},
+}
+
+func TestParseSwitchExpression(t *testing.T) {
+	runParserTest(switchExpressionTests, t, func(p *parser.Parser2) *parser.TemplateTree2 {
+		return p.Mixed()
+	}, "")
+}
+
+func TestParseSwitchExpressionErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{"content outside of a case", "@(x int)\n@switch x {\n<p>hi</p>\n}", "Expected @case or @default in switch"},
+		{"case without values", "@(x int)\n@switch x {\n@case {hi}\n}", "No values found for case"},
+		{"unclosed", "@(x int)\n@switch x {\n@case 1 {hi}\n", "Expected '}', found end of file"},
+		{"multiple defaults", "@(x int)\n@switch x {\n@default {a}\n@default {b}\n}", "Multiple defaults in switch"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := parser.NewParser2("")
+			result := p.Parse(test.input, "Test")
+			if len(result.Errors) == 0 {
+				t.Fatalf("Expected an error")
+			}
+			if result.Errors[0].Err != test.err {
+				t.Fatalf("Expected error \"%s\", got \"%s\"", test.err, result.Errors[0].Err)
+			}
+		})
+	}
+}
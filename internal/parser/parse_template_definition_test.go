@@ -0,0 +1,136 @@
+package parser_test
+
+import (
+	"testing"
+
+	"github.com/gamebox/gwirl/internal/parser"
+)
+
+var templateDefinitionTests = []ParsingTest{
+	{
+		"simple definition",
+		"@row(item Item) = {<tr>@item.Name</tr>}\n",
+		parser.NewTT2TemplateDef("row(item Item)", []parser.TemplateTree2{
+			parser.NewTT2Plain("<tr>"),
+			parser.NewTT2GoExp("item.Name", false, noChildren),
+			parser.NewTT2Plain("</tr>"),
+		}),
+	},
+	{
+		"no parameters",
+		"@divider()={<hr />}",
+		parser.NewTT2TemplateDef("divider()", []parser.TemplateTree2{
+			parser.NewTT2Plain("<hr />"),
+		}),
+	},
+}
+
+func TestParseTemplateDefinition(t *testing.T) {
+	runParserTest(templateDefinitionTests, t, func(p *parser.Parser2) *parser.TemplateTree2 {
+		return p.TemplateDefinition()
+	}, "")
+}
+
+func TestParseTemplateDefinitionInContent(t *testing.T) {
+	p := parser.NewParser2("")
+	result := p.Parse("@(items []Item)\n@row(item Item) = {\n<tr>@item.Name</tr>\n}\n<table>@row(items[0]) = 1</table>", "Test")
+	if len(result.Errors) > 0 {
+		t.Fatalf("Unexpected errors: %v", result.Errors)
+	}
+	content := result.Template.Content
+	if len(content) != 4 {
+		t.Fatalf("Expected 4 trees, got %d: %v", len(content), content)
+	}
+	if content[0].Type != parser.TT2TemplateDef || content[0].Line() != 2 || content[0].Column() != 1 {
+		t.Fatalf("Expected a template definition at 2:1, got %v", content[0])
+	}
+	name, _, params := content[0].Declaration()
+	if name != "row" || params != "(item Item)" {
+		t.Fatalf("Expected declaration of row(item Item), got %s%s", name, params)
+	}
+	if content[1].Type != parser.TT2Plain || content[1].Text != "<table>" {
+		t.Fatalf("Expected the line of the definition to not be rendered, got %v", content[1])
+	}
+	if content[2].Type != parser.TT2GoExp || content[2].Text != "row(items[0])" {
+		t.Fatalf("Expected a call to row, got %v", content[2])
+	}
+}
+
+func TestParseExportedTemplateDefinitions(t *testing.T) {
+	p := parser.NewParser2("")
+	result := p.Parse("@(rows []Row)\n@cell(v string) = {<td>@v</td>}\n@TableRow(row Row) = {<tr>@row.Name</tr>}\n<table></table>", "Table")
+	if len(result.Errors) > 0 {
+		t.Fatalf("Unexpected errors: %v", result.Errors)
+	}
+	content := result.Template.Content
+	if len(content) != 2 || content[0].Type != parser.TT2TemplateDef {
+		t.Fatalf("Expected the unexported definition to stay in the content, got %v", content)
+	}
+	templates := result.Template.Templates
+	if len(templates) != 1 {
+		t.Fatalf("Expected 1 exported template, got %d", len(templates))
+	}
+	row := templates[0]
+	if row.Name.Str != "TableRow" || row.Params.Str != "(row Row)" {
+		t.Fatalf("Expected TableRow(row Row), got %s%s", row.Name.Str, row.Params.Str)
+	}
+	if row.Name.Line() != 3 || row.Name.Column() != 1 || row.Params.Column() != 9 {
+		t.Fatalf("Unexpected positions %d:%d and %d", row.Name.Line(), row.Name.Column(), row.Params.Column())
+	}
+	if len(row.Content) != 3 {
+		t.Fatalf("Expected the content of TableRow, got %v", row.Content)
+	}
+}
+
+func TestParseDuplicateTemplateDefinitions(t *testing.T) {
+	p := parser.NewParser2("")
+	result := p.Parse("@()\n@Table() = {a}\n@Row() = {b}\n@Row() = {c}\n", "Table")
+	if len(result.Errors) != 2 {
+		t.Fatalf("Expected 2 errors, got %v", result.Errors)
+	}
+	if result.Errors[0].Err != "Template Table is already defined" || result.Errors[0].Start.Line() != 2 {
+		t.Fatalf("Unexpected error %v", result.Errors[0])
+	}
+	if result.Errors[1].Err != "Template Row is already defined" || result.Errors[1].Start.Line() != 4 {
+		t.Fatalf("Unexpected error %v", result.Errors[1])
+	}
+}
+
+func TestParseTemplateDefinitionDocComments(t *testing.T) {
+	p := parser.NewParser2("")
+	result := p.Parse("@(rows []Row)\n@* A row of the table *@\n@TableRow(row Row) = {<tr></tr>}\n@* Not a doc comment *@\n\n@TableCell(v string) = {<td></td>}\n", "Table")
+	if len(result.Errors) > 0 {
+		t.Fatalf("Unexpected errors: %v", result.Errors)
+	}
+	templates := result.Template.Templates
+	if len(templates) != 2 {
+		t.Fatalf("Expected 2 exported templates, got %d", len(templates))
+	}
+	if templates[0].Comment == nil || templates[0].Comment.Text != " A row of the table " {
+		t.Errorf("Expected TableRow to have a doc comment, got %v", templates[0].Comment)
+	}
+	if templates[1].Comment != nil {
+		t.Errorf("Expected TableCell to not have a doc comment, got %v", templates[1].Comment)
+	}
+	for _, tree := range result.Template.Content {
+		if tree.Type == parser.TT2BlockComment && tree.Text == " A row of the table " {
+			t.Errorf("Expected the doc comment to be removed from the content")
+		}
+	}
+}
+
+func TestParseExportedTemplateCallingLocalTemplate(t *testing.T) {
+	p := parser.NewParser2("")
+	result := p.Parse("@()\n@cell(v string) = {<td>@v</td>}\n@Row(v string) = {<tr>@cell(v)</tr>}\n", "Table")
+	if len(result.Errors) != 1 {
+		t.Fatalf("Expected 1 error, got %v", result.Errors)
+	}
+	err := result.Errors[0]
+	expected := "cell is defined in the body of Table, so Row can't call it, define it in Row or as an exported template instead"
+	if err.Err != expected {
+		t.Fatalf("Expected %q, got %q", expected, err.Err)
+	}
+	if err.Start.Line() != 3 || err.Start.Column() != 23 || err.End.Column() != 27 {
+		t.Fatalf("Expected the error at the call of cell, got %d:%d-%d", err.Start.Line(), err.Start.Column(), err.End.Column())
+	}
+}
@@ -0,0 +1,63 @@
+package parser_test
+
+import (
+	"testing"
+
+	"github.com/gamebox/gwirl/internal/parser"
+)
+
+func TestParseTypeParams(t *testing.T) {
+	p := parser.NewParser2("")
+	result := p.Parse("@[T any](items []T, render func(T) string)\n<ul></ul>", "List")
+	if len(result.Errors) > 0 {
+		t.Fatalf("Unexpected errors: %v", result.Errors)
+	}
+	template := result.Template
+	if template.TypeParams.Str != "[T any]" || template.Params.Str != "(items []T, render func(T) string)" {
+		t.Fatalf("Expected List[T any](items []T, render func(T) string), got %s%s", template.TypeParams.Str, template.Params.Str)
+	}
+	if template.TypeParams.Column() != 1 || template.Params.Column() != 8 {
+		t.Fatalf("Unexpected positions %d and %d", template.TypeParams.Column(), template.Params.Column())
+	}
+	if len(template.Content) != 1 || template.Content[0].Text != "<ul></ul>" {
+		t.Fatalf("Expected the content after the header, got %v", template.Content)
+	}
+}
+
+func TestParseGenericTemplateDefinition(t *testing.T) {
+	p := parser.NewParser2("")
+	result := p.Parse("@(rows []Row)\n@Table[K comparable, V any](rows map[K]V) = {<table></table>}\n", "Page")
+	if len(result.Errors) > 0 {
+		t.Fatalf("Unexpected errors: %v", result.Errors)
+	}
+	if len(result.Template.Templates) != 1 {
+		t.Fatalf("Expected 1 exported template, got %v", result.Template.Templates)
+	}
+	table := result.Template.Templates[0]
+	if table.TypeParams.Str != "[K comparable, V any]" || table.Params.Str != "(rows map[K]V)" {
+		t.Fatalf("Expected Table[K comparable, V any](rows map[K]V), got %s%s", table.TypeParams.Str, table.Params.Str)
+	}
+	if table.TypeParams.Column() != 6 || table.Params.Column() != 27 {
+		t.Fatalf("Unexpected positions %d and %d", table.TypeParams.Column(), table.Params.Column())
+	}
+	if args := parser.TypeArgs(table.TypeParams.Str); args != "[K, V]" {
+		t.Fatalf("Expected type arguments [K, V], got %s", args)
+	}
+}
+
+func TestParseTypeParamErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		err    string
+	}{
+		{"@[T any]\n<ul></ul>", "Expected parameters after the type parameters"},
+		{"@row[T any](item T) = {<tr></tr>}", "row can't have type parameters, only exported templates can"},
+	}
+	for _, test := range tests {
+		p := parser.NewParser2("")
+		result := p.Parse(test.source, "Test")
+		if len(result.Errors) != 1 || result.Errors[0].Err != test.err {
+			t.Errorf("Expected the error %q for %q, got %v", test.err, test.source, result.Errors)
+		}
+	}
+}
@@ -33,3 +33,48 @@ func TestParseTestAll2(t *testing.T) {
 	}
 	// TODO: Test content parsing better - maybe through parser/pretty print roundtrip?
 }
+
+func TestParsePlainEscapes(t *testing.T) {
+	p := parser.NewParser2("")
+	result := p.Parse("<p>me@@example.com @}@@@}</p>\n", "Test")
+	if len(result.Errors) > 0 {
+		t.Fatalf("Expected no errors, got %v", result.Errors)
+	}
+	content := result.Template.Content
+	if len(content) != 1 || content[0].Type != parser.TT2Plain {
+		t.Fatalf("Expected a single plain tree, got %v", content)
+	}
+	if content[0].Text != "<p>me@example.com }@}</p>\n" {
+		t.Errorf("Expected the escapes to be replaced, got %q", content[0].Text)
+	}
+	if end := content[0].Range().End.Offset(); end != 30 {
+		t.Errorf("Expected the plain tree to end at 30, got %d", end)
+	}
+}
+
+func TestParseDeclaredRaw(t *testing.T) {
+	sources := map[string]string{
+		"parameter": "@(raw func(string) string)\n@raw(\"a\")\n",
+		"import":    "@import \"example.com/raw\"\n@raw(\"a\")\n",
+		"alias":     "@import raw \"example.com/markup\"\n@raw(\"a\")\n",
+	}
+	for name, source := range sources {
+		t.Run(name, func(t *testing.T) {
+			p := parser.NewParser2("")
+			result := p.Parse(source, "Test")
+			if len(result.Errors) > 0 {
+				t.Fatalf("Expected no errors, got %v", result.Errors)
+			}
+			for _, tree := range result.Template.Content {
+				if tree.Type != parser.TT2GoExp {
+					continue
+				}
+				if tree.Text != "raw(\"a\")" || tree.Metadata.Has(parser.TTMDRaw) {
+					t.Fatalf("Expected a call of the declared raw, got %v", tree)
+				}
+				return
+			}
+			t.Fatalf("Expected an expression, got %v", result.Template.Content)
+		})
+	}
+}
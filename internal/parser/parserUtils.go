@@ -6,10 +6,14 @@ import (
 
 type stringPredicate func(string) bool
 
-func isGoIdentifierStart(c byte) bool {
-	return unicode.IsLetter(rune(c))
+// Reports whether a rune can start a Go identifier, which is a letter or an
+// underscore.
+func isGoIdentifierStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
 }
 
-func isGoIdentifierPart(c byte) bool {
-	return isGoIdentifierStart(c) || unicode.IsNumber(rune(c))
+// Reports whether a rune can be part of a Go identifier after its start, which
+// also allows decimal digits.
+func isGoIdentifierPart(r rune) bool {
+	return isGoIdentifierStart(r) || unicode.IsDigit(r)
 }
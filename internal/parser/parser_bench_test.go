@@ -0,0 +1,61 @@
+package parser_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gamebox/gwirl/internal/parser"
+)
+
+var benchmarkTemplates = []string{
+	"manageParticipants.html.gwirl",
+	"base.html.gwirl",
+	"testAll.html.gwirl",
+}
+
+func benchmarkParse(b *testing.B, source string) {
+	b.SetBytes(int64(len(source)))
+	b.ReportAllocs()
+	p := parser.NewParser2("")
+	for i := 0; i < b.N; i++ {
+		res := p.Parse(source, "Bench")
+		if len(res.Errors) > 0 {
+			b.Fatalf("Expected no errors, got %v", res.Errors)
+		}
+	}
+}
+
+func BenchmarkParseTemplates(b *testing.B) {
+	for _, name := range benchmarkTemplates {
+		source, err := os.ReadFile(filepath.Join("..", "..", "gwirl", "testdata", "templates", name))
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(strings.TrimSuffix(name, ".html.gwirl"), func(b *testing.B) {
+			benchmarkParse(b, string(source))
+		})
+	}
+}
+
+// Parsing should take time in proportion to the size of the template, so the
+// bytes per second of these should stay about the same as they get bigger.
+func BenchmarkParseLarge(b *testing.B) {
+	source, err := os.ReadFile(filepath.Join("..", "..", "gwirl", "testdata", "templates", "manageParticipants.html.gwirl"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	// Everything after the parameters and the imports is markup that can be
+	// repeated without changing what the template means.
+	content := string(source)
+	header := content[:strings.Index(content, "\n\n<")+2]
+	content = content[len(header):]
+	for _, n := range []int{1, 10, 100} {
+		large := header + strings.Repeat(content, n)
+		b.Run(fmt.Sprintf("%dKB", len(large)/1024), func(b *testing.B) {
+			benchmarkParse(b, large)
+		})
+	}
+}
@@ -3,36 +3,48 @@ package parser
 import (
 	"errors"
 	"fmt"
+	"go/scanner"
+	"go/token"
 	"io"
+	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 /*
  * The Gwirl Parser2 implements this  grammar that removes some back-tracking within the
  * 'mixed' non-terminal. It is defined as follows:
  * {{{
- *   parser : comment? whitespace? ('@' parentheses)? templateContent
+ *   parser : comment? whitespace? ('@' squareBrackets? parentheses)? templateContent
  *   templateContent : (importExpression | localDef | template | mixed)*
- *   templateDeclaration : '@' identifier parentheses*
+ *   templateDeclaration : '@' identifier squareBrackets? parentheses*
  *   localDef : templateDeclaration (' ' | '\t')* '=' (' ' | '\t') goBlock
- *   template : templateDeclaration (' ' | '\t')* '=' (' ' | '\t') '{' templateContent '}'
- *   mixed : (comment | scalaBlockDisplayed | forExpression | ifExpression | matchExpOrSafeExpOrExpr | caseExpression | plain) | ('{' mixed* '}')
+ *   template : templateDeclaration (' ' | '\t')* '=' (' ' | '\t')* '{' mixed* '}' '\r'? '\n'?
+ *   mixed : (comment | scalaBlockDisplayed | forExpression | ifExpression | switchExpression | slotCall | matchExpOrSafeExpOrExpr | caseExpression | plain) | ('{' mixed* '}')
  *   matchExpOrSafeExpOrExpr : (expression | safeExpression) (whitespaceNoBreak 'match' block)?
  *   scalaBlockDisplayed : scalaBlock
  *   scalaBlockChained : scalaBlock
  *   scalaBlock : '@' brackets
- *   importExpression : '@' 'import ' .* '\r'? '\n'
+ *   importExpression : '@' "import" whitespaceNoBreak? (importSpec | '(' (whitespace (importSpec | lineComment) (';' | '\r'? '\n')?)* ')') lineComment? '\r'? '\n'
+ *   importSpec : ('.' | identifier)? whitespaceNoBreak? stringLiteral
+ *   lineComment : '//' [^'\n']*
  *   forExpression : '@' "for" parentheses block
  *   simpleExpr : methodCall expressionPart*
  *   complexExpr : parentheses
  *   safeExpression : '@' parentheses
+ *   rawExpression : '@' "raw" parentheses block*
+ *   slotCall : '@' "slot" whitespaceNoBreak identifier block
  *   ifExpression : '@' "if" parentheses expressionPart (elseIfCall)* elseCall?
  *   elseCall : whitespaceNoBreak? "else" whitespaceNoBreak? expressionPart
  *   elseIfCall : whitespaceNoBreak? "else if" parentheses whitespaceNoBreak? expressionPart
+ *   switchExpression : '@' "switch" [^'{']* '{' whitespace? ((caseCall | defaultCall | comment) whitespace?)* '}'
+ *   caseCall : '@' "case" [^'{']+ expressionPart
+ *   defaultCall : '@' "default" whitespaceNoBreak? expressionPart
  *   chainedMethods : ('.' methodCall)+
  *   expressionPart : chainedMethods | block | (whitespaceNoBreak scalaBlockChained) | parentheses
  *   expression : '@' methodCall expressionPart*
- *   methodCall : identifier parentheses?
+ *   methodCall : identifier (squareBrackets? parentheses)?
  *   block : whitespaceNoBreak? '{' mixed* '}'
  *   brackets : '{' (brackets | [^'}'])* '}'
  *   comment : '@*' [^'*@']* '*@'
@@ -47,6 +59,9 @@ type Parser2 struct {
 	input      input
 	errorStack []ParseError
 	logger     io.Writer
+	// Whether the template declares its own raw, in which case `@raw(...)` is
+	// an expression that uses it rather than a raw expression.
+	rawDeclared bool
 }
 
 func (p *Parser2) SetLogger(logger io.Writer) {
@@ -62,17 +77,12 @@ func (p *Parser2) log(message string) {
 }
 
 func (p *Parser2) logf(format string, other ...any) {
-	p.log(fmt.Sprintf(format, other...))
-}
-
-func (p *Parser2) accept(str string) bool {
-	length := len(str)
-	if !p.input.isPastEOF(length) && p.input.matches(str) {
-		p.input.advance(length)
-	} else if p.input.isPastEOF(length) {
-		return false
+	// Formatting the trees that are logged takes longer than parsing them, so
+	// it is only done when there is a logger.
+	if p.logger == nil {
+		return
 	}
-	return true
+	p.log(fmt.Sprintf(format, other...))
 }
 
 func (p *Parser2) check(pred stringPredicate) bool {
@@ -85,7 +95,7 @@ func (p *Parser2) check(pred stringPredicate) bool {
 
 func (p *Parser2) checkStr(str string) bool {
 	length := len(str)
-	if !p.input.isPastEOF(length) && p.input.matches(str) {
+	if p.input.matches(str) {
 		p.input.advance(length)
 		return true
 	}
@@ -94,82 +104,104 @@ func (p *Parser2) checkStr(str string) bool {
 
 func (p *Parser2) error(message string, startOffset, endOffset int) {
 	p.logf("Error starts at %d and ends at %d", startOffset, endOffset)
-	start := NewOffsetPosition(p.input.source(), startOffset)
-	end := NewOffsetPosition(p.input.source(), endOffset)
+	start := p.input.pos(startOffset)
+	end := p.input.pos(endOffset)
 	p.errorStack = append(p.errorStack, ParseError{message, start, end})
 }
 
-func (p *Parser2) any(length int) string {
-	if p.input.isEOF() {
-		return ""
-	} else {
-		s := p.input.apply(length)
-		p.input.advance(length)
-		return s
-	}
+// Adds an error for a tree that has already been parsed, spanning length
+// characters from the start of the tree.
+func (p *Parser2) errorAt(message string, tree *TemplateTree2, length int) {
+	offset := p.input.offsetOf(tree.Line(), tree.Column())
+	p.error(message, offset, offset+length)
 }
 
-func (p *Parser2) anyUntil(f func(string) bool, inclusive bool) string {
-	sb := strings.Builder{}
-	for !p.input.isEOF() && !f(p.input.apply(1)) {
-		sb.WriteString(p.any(1))
+// Goes back to an earlier offset and drops the errors found since then, for
+// when what was being parsed turns out to be something else.
+func (p *Parser2) backtrack(offset int, errorCount int) {
+	p.input.regressTo(offset)
+	p.errorStack = p.errorStack[:errorCount]
+}
+
+// Checks for a keyword like "@if", which can't be followed by more of an
+// identifier, since "@iframe" is an expression instead.
+func (p *Parser2) keyword(kw string) bool {
+	pos := p.input.offset()
+	if !p.checkStr(kw) {
+		return false
 	}
-	if inclusive && !p.input.isEOF() {
-		sb.WriteString(p.any(1))
+	if r, _ := p.input.peekRune(); isGoIdentifierPart(r) {
+		p.input.regressTo(pos)
+		return false
 	}
-	return sb.String()
+	return true
 }
 
 func (p *Parser2) anyUntilStr(stop string, inclusive bool) string {
-	sb := strings.Builder{}
-	stopLength := len(stop)
-	for !p.input.isPastEOF(stopLength) && !p.input.matches(stop) {
-		sb.WriteString(p.any(1))
-	}
-	if inclusive && !p.input.isPastEOF(stopLength) {
-		sb.WriteString(p.any(stopLength))
+	start := p.input.offset()
+	p.input.takeUntil(stop)
+	if inclusive && !p.input.isEOF() {
+		p.input.advance(len(stop))
 	}
-	return sb.String()
+	return p.input.since(start)
 }
 
 func (p *Parser2) position(positional Positional, offset int) {
 	if positional == nil {
 		return
 	}
-	positional.SetPos(NewOffsetPosition(p.input.source(), offset))
+	positional.SetPos(p.input.pos(offset))
+}
+
+// Sets the position and range of a string that was parsed from the source at
+// the given offset.
+func (p *Parser2) positionString(ps *PosString, offset int) {
+	ps.SetRange(p.input.rangeOf(offset, offset+len(ps.Str)))
+}
+
+// Sets the range of a tree to the source from its start up to the current
+// offset, and the range of its text to the source of the given length at the
+// offset of the text.
+func (p *Parser2) ranges(tree *TemplateTree2, start int, textStart int, textLength int) {
+	tree.SetRange(p.input.rangeOf(start, p.input.offset()))
+	tree.SetTextRange(p.input.rangeOf(textStart, textStart+textLength))
 }
 
 func (p *Parser2) recursiveTag(prefix string, suffix string, allowStringLiterals bool, forbidNewlines bool) *string {
 	pos := p.input.offset()
 	if p.checkStr(prefix) {
 		stack := 1
-		sb := strings.Builder{}
-		sb.WriteString(prefix)
+		stops := prefix[:1] + suffix[:1]
+		if forbidNewlines {
+			stops += "\n"
+		}
+		if allowStringLiterals {
+			stops += "\""
+		}
 		for stack > 0 {
+			p.input.takeUntilAny(stops)
 			if forbidNewlines && p.checkStr("\n") {
 				p.error("Unexpected newline", pos, p.input.offset())
 				stack = 0
 			} else if p.checkStr(prefix) {
 				stack = stack + 1
-				sb.WriteString(prefix)
 			} else if p.checkStr(suffix) {
 				stack = stack - 1
-				sb.WriteString(suffix)
 			} else if p.input.isEOF() {
-				p.error(fmt.Sprintf("Expected '%s', got end of file", suffix), pos, p.input.offset())
-				stack = 0
-			} else if allowStringLiterals {
-				s, err := p.stringLiteral("\"", "\\")
-				if err != nil {
-					sb.WriteString(p.any(1))
-				} else {
-					sb.WriteString(s)
+				p.error(fmt.Sprintf("Expected '%s', got end of file", suffix), pos, pos+len(prefix))
+				// The tag would swallow everything after the line it starts
+				// on, so parsing picks up again on the next line.
+				if end := strings.IndexByte(p.input.source()[pos:], '\n'); end >= 0 {
+					p.input.regressTo(pos + end)
 				}
+				stack = 0
+			} else if allowStringLiterals && p.input.peek() == '"' {
+				p.stringLiteral("\"", "\\")
 			} else {
-				sb.WriteString(p.any(1))
+				p.input.advance(1)
 			}
 		}
-		tag := sb.String()
+		tag := p.input.since(pos)
 		return &tag
 	}
 	return nil
@@ -177,41 +209,29 @@ func (p *Parser2) recursiveTag(prefix string, suffix string, allowStringLiterals
 }
 
 func (p *Parser2) stringLiteral(quote string, escape string) (string, error) {
+	start := p.input.offset()
 	if p.checkStr(quote) {
 		within := true
-		sb := strings.Builder{}
-		sb.WriteString(quote)
 		for within {
+			p.input.takeUntilAny(quote[:1] + escape[:1] + "\n")
 			if p.checkStr(quote) {
-				sb.WriteString(quote)
 				within = false
 			} else if p.checkStr(escape) {
-				sb.WriteString(escape)
-				if p.checkStr(quote) {
-					sb.WriteString(quote)
-				} else if p.checkStr(escape) {
-					sb.WriteString(escape)
+				if !p.checkStr(quote) {
+					p.checkStr(escape)
 				}
-			} else if p.input.isEOF() {
+			} else if p.input.isEOF() || p.input.peek() == '\n' {
+				// Strings can't span lines, so an unclosed one ends with its line.
 				within = false
 			} else {
-				sb.WriteString(p.any(1))
+				p.input.advance(1)
 			}
 		}
-		return sb.String(), nil
+		return p.input.since(start), nil
 	}
 	return "", errors.New("Quote not found")
 }
 
-func several2[T any](p *Parser2, parser func(p *Parser2) *T, provided []*T) []*T {
-	parsed := parser(p)
-	for parsed != nil {
-		provided = append(provided, parsed)
-		parsed = parser(p)
-	}
-	return provided
-}
-
 func (p *Parser2) parentheses(forbidNewlines bool) *string {
 	return p.recursiveTag("(", ")", true, forbidNewlines)
 }
@@ -221,25 +241,22 @@ func (p *Parser2) squareBrackets() *string {
 }
 
 func (p *Parser2) whitespaceNoBreak() *string {
-	result := p.anyUntil(func(c string) bool {
-		return c != " " && c != "\t"
-	}, false)
+	result := p.input.takeWhile(func(c rune) bool {
+		return c == ' ' || c == '\t'
+	})
 	return &result
 }
 
 func (p *Parser2) whitespace() (string, error) {
-	return p.anyUntil(func(c string) bool {
-		return c[0] > 32
-	}, false), nil
+	return p.input.takeWhile(func(c rune) bool {
+		return c <= 32
+	}), nil
 }
 
 func (p *Parser2) identifier() (string, error) {
-	if !p.input.isEOF() && isGoIdentifierStart(p.input.apply(1)[0]) {
+	if r, _ := p.input.peekRune(); isGoIdentifierStart(r) {
 		p.log("getting identifier\n")
-		return p.anyUntil(func(c string) bool {
-			p.log("Identifier anyUntil")
-			return !isGoIdentifierPart(c[0])
-		}, false), nil
+		return p.input.takeWhile(isGoIdentifierPart), nil
 	}
 	return "", errors.New("Not an identifier")
 }
@@ -248,9 +265,12 @@ func (p *Parser2) Comment() *TemplateTree2 {
 	pos := p.input.offset()
 	if p.checkStr("@*") {
 		text := p.anyUntilStr("*@", false)
-		p.accept("*@")
+		if !p.checkStr("*@") {
+			p.error("Expected '*@' to close the comment, found end of file", pos, pos+2)
+		}
 		comment := NewTT2BlockComment(text)
 		p.position(&comment, pos)
+		p.ranges(&comment, pos, pos+2, len(text))
 		return &comment
 	}
 	return nil
@@ -280,6 +300,7 @@ func (p *Parser2) GoBlock() *TemplateTree2 {
 		}
 		blk := NewTT2GoBlock(b)
 		p.position(&blk, pos)
+		p.ranges(&blk, pos-1, pos, len(b))
 		return &blk
 	}
 	return nil
@@ -296,16 +317,137 @@ func (p *Parser2) Brackets() string {
 	return *result
 }
 
-// TODO: Write a parser for go import block statement
-func (p *Parser2) ImportExpression() *PosString {
+// Parses an import declaration, which is either a single import or a block of
+// imports in parentheses like in Go.  Returns nil if there is no declaration,
+// and the imports that could be parsed if it has errors.
+func (p *Parser2) ImportExpression() []Import {
 	start := p.input.offset()
-	if p.checkStr("@import ") {
-		content := strings.TrimSpace(p.anyUntilStr("\n", true))
-		ps := NewPosString("import " + content)
-		p.position(&ps, start+1)
-		return &ps
+	if !p.checkStr("@import") {
+		return nil
 	}
-	return nil
+	keyword := NewPosString("import")
+	p.positionString(&keyword, start+1)
+	ws := p.whitespaceNoBreak()
+	if p.checkStr("(") {
+		return p.importBlock(keyword, start)
+	}
+	if r, _ := p.input.peekRune(); *ws == "" && isGoIdentifierPart(r) {
+		// Something like @imports is an expression instead.
+		p.input.regressTo(start)
+		return nil
+	}
+	imports := []Import{}
+	if imp := p.importSpec(keyword); imp != nil {
+		imports = append(imports, *imp)
+	}
+	p.importEnd(false)
+	return imports
+}
+
+// Parses the imports of a block, up to and including the closing parenthesis.
+func (p *Parser2) importBlock(keyword PosString, start int) []Import {
+	imports := []Import{}
+	for {
+		p.whitespace()
+		if p.checkStr(")") {
+			p.importEnd(false)
+			return imports
+		}
+		if p.input.isEOF() {
+			p.error("Expected ')' to close the import block", start, p.input.offset())
+			return imports
+		}
+		if p.checkStr("//") {
+			p.anyUntilStr("\n", true)
+			continue
+		}
+		if imp := p.importSpec(keyword); imp != nil {
+			imports = append(imports, *imp)
+		}
+		p.importEnd(true)
+	}
+}
+
+// Parses a single import, which is an optional name followed by the path of the
+// package.
+func (p *Parser2) importSpec(keyword PosString) *Import {
+	imp := Import{Keyword: keyword}
+	pos := p.input.offset()
+	name := ""
+	if p.checkStr(".") {
+		name = "."
+	} else {
+		name = p.input.takeWhile(isGoIdentifierPart)
+	}
+	if first, _ := utf8.DecodeRuneInString(name); name != "" && name != "." && !isGoIdentifierStart(first) {
+		p.error(fmt.Sprintf("Invalid import name %s", name), pos, p.input.offset())
+	}
+	imp.Name = NewPosString(name)
+	p.positionString(&imp.Name, pos)
+	p.whitespaceNoBreak()
+	pos = p.input.offset()
+	path := p.importPath()
+	if path == "" {
+		rest := strings.TrimRight(p.anyUntilStr("\n", false), "\r")
+		p.error(fmt.Sprintf("Expected an import path, found %q", name+rest), pos-len(name), p.input.offset())
+		return nil
+	}
+	unquoted, err := strconv.Unquote(path)
+	if err != nil || !validImportPath(unquoted) {
+		p.error(fmt.Sprintf("Invalid import path %s", path), pos, p.input.offset())
+		return nil
+	}
+	imp.Path = NewPosString(path)
+	p.positionString(&imp.Path, pos)
+	return &imp
+}
+
+// Parses the quoted path of an import, stopping at the end of the line if the
+// path isn't closed.
+func (p *Parser2) importPath() string {
+	if p.input.isEOF() {
+		return ""
+	}
+	quote := p.input.apply(1)
+	if quote != "\"" && quote != "`" {
+		return ""
+	}
+	start := p.input.offset()
+	p.input.advance(1)
+	for !p.input.isEOF() {
+		c := p.input.apply(1)
+		if c == "\n" {
+			break
+		}
+		p.input.advance(1)
+		if c == quote {
+			break
+		}
+		if c == "\\" && quote == "\"" && !p.input.isEOF() {
+			p.input.advance(1)
+		}
+	}
+	return p.input.since(start)
+}
+
+// Parses the rest of the line after an import, which can only have a comment.
+// Inside of a block, imports can also be followed by a semicolon or the end of
+// the block.
+func (p *Parser2) importEnd(inBlock bool) {
+	p.whitespaceNoBreak()
+	if p.checkStr("//") {
+		p.anyUntilStr("\n", false)
+	}
+	if inBlock && (p.checkStr(";") || (!p.input.isEOF() && p.input.apply(1) == ")")) {
+		return
+	}
+	pos := p.input.offset()
+	p.checkStr("\r")
+	if p.input.isEOF() || p.checkStr("\n") {
+		return
+	}
+	rest := strings.TrimRight(p.anyUntilStr("\n", true), "\r\n")
+	p.error(fmt.Sprintf("Unexpected %q after import", rest), pos, pos+len(rest))
 }
 
 func (p *Parser2) methodCall() string {
@@ -314,6 +456,9 @@ func (p *Parser2) methodCall() string {
 	if name != "" {
 		sb := strings.Builder{}
 		sb.WriteString(name)
+		if typeArgs := p.typeArgs(); typeArgs != "" {
+			sb.WriteString(typeArgs)
+		}
 		parens := p.parentheses(true)
 		if parens != nil {
 			sb.WriteString(*parens)
@@ -325,6 +470,36 @@ func (p *Parser2) methodCall() string {
 	return name
 }
 
+// Returns the type arguments of a call, like "[int]" in "List[int](items)".
+// Brackets are only type arguments when the arguments of the call follow them,
+// so nothing is consumed for anything else, like the index in "items[0]" or a
+// bracket that is never closed.
+func (p *Parser2) typeArgs() string {
+	rest := p.input.source()[p.input.offset():]
+	if !strings.HasPrefix(rest, "[") {
+		return ""
+	}
+	depth := 0
+	for i, c := range rest {
+		switch c {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case '\n':
+			return ""
+		}
+		if depth == 0 {
+			if !strings.HasPrefix(rest[i+1:], "(") {
+				return ""
+			}
+			p.input.advance(i + 1)
+			return rest[:i+1]
+		}
+	}
+	return ""
+}
+
 func (p *Parser2) chainedMethods() string {
 	if p.checkStr(".") {
 		sb := strings.Builder{}
@@ -391,12 +566,47 @@ func (p *Parser2) SafeExpression() *TemplateTree2 {
     exp := NewTT2GoExpSafe(content, escape)
     t = &exp
     p.position(t, pos)
+    start := pos - 2
+    if escape {
+        start--
+    }
+    p.ranges(t, start, pos, len(content))
 
     return t
 }
 
+// Parses an expression whose value is always output without escaping, even
+// when expressions are escaped by default.
+func (p *Parser2) RawExpression() *TemplateTree2 {
+	p.log("RawExpression")
+	if p.rawDeclared || !p.checkStr("@raw(") {
+		return nil
+	}
+	p.input.regress(1)
+	pos := p.input.offset() + 1
+	code := p.parentheses(true)
+	if code == nil {
+		p.input.regressTo(pos - 5)
+		return nil
+	}
+	content := strings.TrimPrefix(strings.TrimSuffix(*code, ")"), "(")
+	transclusions := [][]TemplateTree2{}
+	if strings.HasSuffix(content, ")") {
+		transclusions = p.multipleBlocks()
+	}
+	transclusions, slots := p.namedSlots(transclusions)
+	t := NewTT2GoExpRaw(content, transclusions)
+	if slots {
+		t.Metadata.Set(TTMDSlots)
+	}
+	p.position(&t, pos)
+	p.ranges(&t, pos-len("@raw("), pos, len(content))
+	return &t
+}
+
 func (p *Parser2) Expression() *TemplateTree2 {
 	p.log("expression")
+	start := p.input.offset()
 	if !p.checkStr("@") {
 		return nil
 	}
@@ -426,44 +636,148 @@ func (p *Parser2) Expression() *TemplateTree2 {
 		// TODO: check that first segment is not a Go or Gwirl keyword
 		t := NewTT2GoExp(combinedExpression, escape, [][]TemplateTree2{})
 		p.position(&t, pos)
+		p.ranges(&t, start, pos, len(combinedExpression))
 		return &t
 	}
 
-	transclusions := p.multipleBlocks()
+	transclusions, slots := p.namedSlots(p.multipleBlocks())
 	t := NewTT2GoExp(combinedExpression, escape, transclusions)
+	if slots {
+		t.Metadata.Set(TTMDSlots)
+	}
 	p.position(&t, pos)
+	p.ranges(&t, start, pos, len(combinedExpression))
 	return &t
 }
 
-func (p *Parser2) block() *[]TemplateTree2 {
-	var result *[]TemplateTree2 = nil
+func (p *Parser2) slotCall() *TemplateTree2 {
 	pos := p.input.offset()
+	if !p.checkStr("@slot") {
+		return nil
+	}
+	if p.input.isEOF() || (p.input.apply(1) != " " && p.input.apply(1) != "\t") {
+		p.input.regressTo(pos)
+		return nil
+	}
 	p.whitespaceNoBreak()
-	if p.checkStr("{") {
-		mixeds := []*TemplateTree2{}
-		mixeds = several2[TemplateTree2](p, func(p *Parser2) *TemplateTree2 {
-			res := p.Mixed()
-			if res == nil {
-				return nil
+	nameStart := p.input.offset()
+	name, _ := p.identifier()
+	if name == "" {
+		p.error("Expected a name for slot", pos, p.input.offset())
+		return nil
+	}
+	blk := p.block()
+	if blk == nil {
+		p.error(fmt.Sprintf("Expected a block for slot %s", name), pos, p.input.offset())
+		return nil
+	}
+	t := NewTT2Slot(name, *blk)
+	p.position(&t, pos+1)
+	p.ranges(&t, pos, nameStart, len(name))
+	return &t
+}
+
+// Checks whether the transclusions of a call are named slots, and when they
+// are returns a single transclusion holding only the slots.  Named slots can't
+// be mixed with other content or other transclusions.
+func (p *Parser2) namedSlots(transclusions [][]TemplateTree2) ([][]TemplateTree2, bool) {
+	var firstSlot *TemplateTree2
+	for i := range transclusions {
+		for j := range transclusions[i] {
+			if transclusions[i][j].Type == TT2Slot && firstSlot == nil {
+				firstSlot = &transclusions[i][j]
+			}
+		}
+	}
+	if firstSlot == nil {
+		return transclusions, false
+	}
+	if len(transclusions) > 1 {
+		p.errorAt("Named slots can't be used with more than one transclusion", firstSlot, len(firstSlot.Text)+4)
+		return transclusions, false
+	}
+	slots := []TemplateTree2{}
+	names := map[string]bool{}
+	for _, transclusion := range transclusions {
+		for j := range transclusion {
+			tree := &transclusion[j]
+			switch {
+			case tree.Type == TT2Slot && names[tree.Text]:
+				p.errorAt(fmt.Sprintf("Slot %s is already defined", tree.Text), tree, len(tree.Text)+4)
+			case tree.Type == TT2Slot:
+				names[tree.Text] = true
+				slots = append(slots, *tree)
+			case tree.Type == TT2BlockComment:
+			case tree.Type == TT2Plain && strings.TrimSpace(tree.Text) == "":
+			default:
+				p.errorAt("Only @slot can be used in a transclusion with named slots", tree, 1)
 			}
-			return res
-		}, mixeds)
+		}
+	}
+	return [][]TemplateTree2{slots}, true
+}
 
-		accepted := p.accept("}")
-		if !accepted {
-			p.error(fmt.Sprintf("Expected '}', found end of file"), pos, p.input.offset())
+// Reports the slots that are not directly inside of a transclusion, since
+// there is no template for them to be passed to.
+func (p *Parser2) strandedSlots(content []TemplateTree2) {
+	for i := range content {
+		tree := &content[i]
+		if tree.Type == TT2Slot {
+			p.errorAt("@slot can only be used in the transclusion of a template call", tree, len(tree.Text)+4)
 		}
-		flatMixed := []TemplateTree2{}
-		for _, m := range mixeds {
-			if m != nil {
-				flatMixed = append(flatMixed, *m)
+		for j, children := range tree.Children {
+			if tree.Metadata.Has(TTMDSlots) && j == 0 {
+				for _, slot := range children {
+					for _, slotContent := range slot.Children {
+						p.strandedSlots(slotContent)
+					}
+				}
+				continue
 			}
+			p.strandedSlots(children)
 		}
-		result = &flatMixed
-	} else {
+	}
+}
+
+// Parses a block of content in braces.  A block that is never closed ends at
+// the next template definition, so that the templates after it are still
+// parsed.
+func (p *Parser2) block() *[]TemplateTree2 {
+	pos := p.input.offset()
+	p.whitespaceNoBreak()
+	start := p.input.offset()
+	if !p.checkStr("{") {
 		p.input.regressTo(pos)
+		return nil
+	}
+	mixeds := []TemplateTree2{}
+	more := true
+	for more && !p.atDefinition() {
+		mixeds, more = p.nextMixed(mixeds)
+	}
+	if !p.checkStr("}") {
+		p.error("Expected '}' to close the block", start, start+1)
 	}
-	return result
+	return &mixeds
+}
+
+// Parses the next part of some content and adds it to the content.  An invalid
+// '@' is reported and skipped, as is what's left of a part with errors.
+// Returns false when there is nothing left to parse, at a closing brace or the
+// end of the file.
+func (p *Parser2) nextMixed(content []TemplateTree2) ([]TemplateTree2, bool) {
+	pos := p.input.offset()
+	if mix := p.Mixed(); mix != nil {
+		return append(content, *mix), true
+	}
+	if p.input.offset() != pos {
+		return content, true
+	}
+	if p.checkStr("@") {
+		p.error("Invalid '@' symbol", pos, pos+1)
+		return content, true
+	}
+	return content, false
 }
 
 func (p *Parser2) multipleBlocks() [][]TemplateTree2 {
@@ -483,30 +797,37 @@ func (p *Parser2) expressionPart(blockArgsAllowed bool) *[]TemplateTree2 {
 	return p.block()
 }
 
+// Parses the condition of an @if or @for, or the values of a @case, up to the
+// brace that opens its block.  It can go on over several lines, but not into a
+// line that starts with markup, an '@' or a brace, since the brace was most
+// likely forgotten then.
 func (p *Parser2) ifOrForDeclaration() string {
-	return p.anyUntilStr("{", false)
+	start := p.input.offset()
+	for p.input.takeUntilAny("{\n"); p.input.peek() == '\n'; p.input.takeUntilAny("{\n") {
+		next := strings.TrimLeft(p.input.source()[p.input.offset()+1:], " \t\r")
+		if next == "" || strings.ContainsAny(next[:1], "@<}") {
+			break
+		}
+		p.input.advance(1)
+	}
+	return p.input.since(start)
 }
 
 func (p *Parser2) forExpression() *TemplateTree2 {
-	var result *TemplateTree2 = nil
 	pos := p.input.offset()
-	if p.checkStr("@for") {
-		condition := p.ifOrForDeclaration()
-		if condition != "" {
-			blk := p.expressionPart(true)
-			if blk != nil {
-				s := NewTT2For(condition, *blk)
-				result = &s
-			}
-		}
-	}
-
-	if result == nil {
-		p.input.regressTo(pos)
+	if !p.keyword("@for") {
 		return nil
 	}
-	p.position(result, pos+1)
-	return result
+	condition := p.ifOrForDeclaration()
+	blk := p.expressionPart(true)
+	if blk == nil {
+		p.error("Expected a block for for", pos, p.input.offset())
+		blk = &[]TemplateTree2{}
+	}
+	result := NewTT2For(condition, *blk)
+	p.position(&result, pos+1)
+	p.ranges(&result, pos, pos+len("@for"), len(condition))
+	return &result
 }
 
 func (p *Parser2) elseIfs() []TemplateTree2 {
@@ -514,7 +835,8 @@ func (p *Parser2) elseIfs() []TemplateTree2 {
 	for {
 		pos := p.input.offset()
 		p.whitespaceNoBreak()
-		if p.checkStr("@else if") {
+		start := p.input.offset()
+		if p.keyword("@else if") {
 			condition := p.ifOrForDeclaration()
 			if condition == "" {
 				p.error("No condition found for else if", pos, p.input.offset())
@@ -526,6 +848,8 @@ func (p *Parser2) elseIfs() []TemplateTree2 {
 				break
 			}
 			tree := NewTT2ElseIf(condition, *blk)
+			p.position(&tree, start+1)
+			p.ranges(&tree, start, start+len("@else if"), len(condition))
 			trees = append(trees, tree)
 		} else {
 			p.input.regressTo(pos)
@@ -536,83 +860,167 @@ func (p *Parser2) elseIfs() []TemplateTree2 {
 }
 
 func (p *Parser2) ifExpression() *TemplateTree2 {
-	var result *TemplateTree2 = nil
 	pos := p.input.offset()
-	if p.checkStr("@if") {
-		condition := p.ifOrForDeclaration()
-		if condition != "" {
-			var elseIfTrees []TemplateTree2
-			var elseTree *TemplateTree2
-			blk := p.expressionPart(true)
-			p.logf("Got blk %v", blk)
-			if blk != nil {
-				// TODO: Get elseIfs
-				elseIfTrees = p.elseIfs()
-				elseTree = p.elseCall()
-
-				ifTree := NewTT2If(condition, *blk, elseIfTrees, elseTree)
-				result = &ifTree
-			}
-		}
-	}
-
-	if result == nil {
-		p.input.regressTo(pos)
+	if !p.keyword("@if") {
 		return nil
 	}
-	p.position(result, pos+1)
-	return result
+	condition := p.ifOrForDeclaration()
+	if strings.TrimSpace(condition) == "" {
+		p.error("No condition found for if", pos, p.input.offset())
+	}
+	blk := p.expressionPart(true)
+	p.logf("Got blk %v", blk)
+	var elseIfTrees []TemplateTree2
+	var elseTree *TemplateTree2
+	if blk == nil {
+		p.error("Expected a block for if", pos, p.input.offset())
+		blk = &[]TemplateTree2{}
+	} else {
+		elseIfTrees = p.elseIfs()
+		elseTree = p.elseCall()
+	}
+	result := NewTT2If(condition, *blk, elseIfTrees, elseTree)
+	p.position(&result, pos+1)
+	p.ranges(&result, pos, pos+len("@if"), len(condition))
+	return &result
 }
 
 func (p *Parser2) elseCall() *TemplateTree2 {
 	reset := p.input.offset()
 	p.whitespaceNoBreak()
-	if p.checkStr("@else") {
+	start := p.input.offset()
+	if p.keyword("@else") {
 		p.whitespaceNoBreak()
 		blk := p.expressionPart(true)
-		if blk != nil {
-			t := NewTT2Else(*blk)
-			return &t
+		if blk == nil {
+			p.error("Expected a block for else", start, p.input.offset())
+			return nil
 		}
-		return nil
+		t := NewTT2Else(*blk)
+		p.position(&t, start+1)
+		p.ranges(&t, start, start+len("@else"), 0)
+		return &t
 	}
 	p.input.regressTo(reset)
 	return nil
 }
 
-func (p *Parser2) plainSingle() string {
-	if p.checkStr("@@") {
-		return "@"
+func (p *Parser2) caseCall() *TemplateTree2 {
+	pos := p.input.offset()
+	if p.keyword("@case") {
+		values := p.ifOrForDeclaration()
+		if strings.TrimSpace(values) == "" {
+			p.error("No values found for case", pos, p.input.offset())
+		}
+		blk := p.expressionPart(true)
+		if blk == nil {
+			p.error("Expected a block for case", pos, p.input.offset())
+			return nil
+		}
+		t := NewTT2Case(values, *blk)
+		p.position(&t, pos+1)
+		p.ranges(&t, pos, pos+len("@case"), len(values))
+		return &t
+	}
+	if p.keyword("@default") {
+		p.whitespaceNoBreak()
+		blk := p.expressionPart(true)
+		if blk == nil {
+			p.error("Expected a block for default", pos, p.input.offset())
+			return nil
+		}
+		t := NewTT2Default(*blk)
+		p.position(&t, pos+1)
+		p.ranges(&t, pos, pos+len("@default"), 0)
+		return &t
 	}
-	if p.checkStr("@}") {
-		return "}"
+	return nil
+}
+
+func (p *Parser2) switchExpression() *TemplateTree2 {
+	pos := p.input.offset()
+	if !p.checkStr("@switch") {
+		return nil
 	}
-	if p.input.isEOF() {
-		return ""
+	if !p.input.isEOF() {
+		next := p.input.apply(1)
+		if next != " " && next != "\t" && next != "{" {
+			p.input.regressTo(pos)
+			return nil
+		}
 	}
-	next := p.input.apply(1)
-	if next != "@" && next != "}" {
-		return p.any(1)
+	expression := p.ifOrForDeclaration()
+	cases := []TemplateTree2{}
+	if !p.checkStr("{") {
+		p.error("Expected a block for switch", pos, p.input.offset())
+		t := NewTT2Switch(expression, cases)
+		p.position(&t, pos+1)
+		p.ranges(&t, pos, pos+len("@switch"), len(expression))
+		return &t
 	}
-	return ""
+	hasDefault := false
+	for {
+		p.whitespace()
+		if p.Comment() != nil {
+			continue
+		}
+		start := p.input.offset()
+		if p.checkStr("}") {
+			break
+		}
+		if p.input.isEOF() {
+			p.error("Expected '}', found end of file", pos, pos+len("@switch"))
+			break
+		}
+		c := p.caseCall()
+		if c == nil {
+			if p.input.offset() == start {
+				// Anything else is skipped up to where a case could start.
+				p.input.advance(1)
+				p.input.takeUntilAny("\n@}")
+				skipped := p.input.since(start)
+				p.error("Expected @case or @default in switch", start, start+len(strings.TrimRight(skipped, " \t\r")))
+			}
+			continue
+		}
+		if c.Type == TT2Default {
+			if hasDefault {
+				p.error("Multiple defaults in switch", start, p.input.offset())
+			}
+			hasDefault = true
+		}
+		cases = append(cases, *c)
+	}
+	t := NewTT2Switch(expression, cases)
+	p.position(&t, pos+1)
+	p.ranges(&t, pos, pos+len("@switch"), len(expression))
+	return &t
 }
 
+// Parses plain text up to the next '@' or '}' that isn't escaped.  The text is
+// a slice of the source unless it has escapes, which are replaced by the
+// character they escape.
 func (p *Parser2) plain() *TemplateTree2 {
 	pos := p.input.offset()
-	var result *TemplateTree2 = nil
-	part := p.plainSingle()
-	if part != "" {
-		sb := strings.Builder{}
-		for part != "" {
-			sb.WriteString(part)
-			part = p.plainSingle()
-		}
-		plain := NewTT2Plain(sb.String())
-		p.position(&plain, pos)
-		result = &plain
+	var sb strings.Builder
+	text := p.input.takeUntilAny("@}")
+	for p.input.matches("@@") || p.input.matches("@}") {
+		sb.WriteString(text)
+		sb.WriteString(p.input.apply(2)[1:])
+		p.input.advance(2)
+		text = p.input.takeUntilAny("@}")
 	}
-
-	return result
+	if p.input.offset() == pos {
+		return nil
+	}
+	if sb.Len() > 0 {
+		sb.WriteString(text)
+		text = sb.String()
+	}
+	plain := NewTT2Plain(text)
+	p.position(&plain, pos)
+	p.ranges(&plain, pos, pos, p.input.offset()-pos)
+	return &plain
 }
 
 func (p *Parser2) Mixed() *TemplateTree2 {
@@ -637,12 +1045,27 @@ func (p *Parser2) Mixed() *TemplateTree2 {
 	if ifExp != nil {
 		return ifExp
 	}
+	p.logf("mixedOpt1: trying switch @ %d", pos)
+	switchExp := p.switchExpression()
+	if switchExp != nil {
+		return switchExp
+	}
+	p.logf("mixedOpt1: trying slot @ %d", pos)
+	slot := p.slotCall()
+	if slot != nil {
+		return slot
+	}
 	p.logf("mixedOpt1: trying plain @ %d", pos)
 	plain := p.plain()
 	if plain != nil {
 		p.logf("mixedOpt1: got plain: %v", plain)
 		return plain
 	}
+	p.logf("mixedOpt1: trying RawExpression")
+	rawExp := p.RawExpression()
+	if rawExp != nil {
+		return rawExp
+	}
     p.logf("mixedOpt1: trying SafeExpression")
     safeExp := p.SafeExpression()
     if safeExp != nil {
@@ -659,43 +1082,309 @@ func (p *Parser2) Mixed() *TemplateTree2 {
 	return nil
 }
 
-func (p *Parser2) TopImports() []PosString {
-	imports := make([]PosString, 0, 0)
-	done := false
+// Parses the imports at the top of the template, reporting any package that is
+// imported more than once and any two packages imported under the same name.
+func (p *Parser2) TopImports() []Import {
+	imports := []Import{}
+	seen := map[string]bool{}
+	names := map[string]string{}
 	p.whitespace()
-	for !done {
-		impExp := p.ImportExpression()
-		if impExp != nil {
-			imports = append(imports, *impExp)
-			continue
+	for {
+		decl := p.ImportExpression()
+		if decl == nil {
+			break
+		}
+		for _, imp := range decl {
+			path := imp.PackagePath()
+			if seen[path] {
+				r := imp.Path.Range()
+				p.error(fmt.Sprintf("%s is already imported", imp.Path.Str), r.Start.Offset(), r.End.Offset())
+				continue
+			}
+			name := imp.PackageName()
+			if other, ok := names[name]; ok {
+				r := imp.Range()
+				p.error(fmt.Sprintf("%s is already the name of %q, import %s under another name", name, other, imp.Path.Str), r.Start.Offset(), r.End.Offset())
+				continue
+			}
+			seen[path] = true
+			if name != "_" && name != "." {
+				names[name] = path
+			}
+			imports = append(imports, imp)
+		}
+		// Blank lines between imports belong to the imports, but the ones
+		// after the last import belong to the content.
+		pos := p.input.offset()
+		p.whitespace()
+		if !p.input.matches("@import") {
+			p.input.regressTo(pos)
 		}
-		done = true
 	}
 	return imports
 }
 
+// Parses a template defined inside of the template being parsed, which can be
+// called by the content that follows it.
+func (p *Parser2) TemplateDefinition() *TemplateTree2 {
+	pos := p.input.offset()
+	errorCount := len(p.errorStack)
+	name, typeParams, params := p.definitionHeader()
+	if params == nil {
+		p.backtrack(pos, errorCount)
+		return nil
+	}
+	blk := p.block()
+	end := p.input.offset()
+	// The definition doesn't render anything, so neither does the line it is on.
+	p.checkStr("\r")
+	p.checkStr("\n")
+	paramsStart := pos + 1 + len(name) + len(typeParams)
+	p.checkParams(*params, paramsStart)
+	first, _ := utf8.DecodeRuneInString(name)
+	if !unicode.IsUpper(first) && HasDefaults(ParseParams(*params)) {
+		p.error(fmt.Sprintf("Parameters of %s can't have default values, only exported templates can", name), paramsStart, paramsStart+len(*params))
+	}
+	if !unicode.IsUpper(first) && typeParams != "" {
+		p.error(fmt.Sprintf("%s can't have type parameters, only exported templates can", name), pos+1+len(name), paramsStart)
+	}
+	t := NewTT2TemplateDef(name+typeParams+*params, *blk)
+	p.position(&t, pos+1)
+	t.SetRange(p.input.rangeOf(pos, end))
+	t.SetTextRange(p.input.rangeOf(pos+1, pos+1+len(t.Text)))
+	return &t
+}
+
+// Parses the start of a template definition up to the brace that opens its
+// content, like "@row(item string) = ".  Returns nil parameters when there is
+// no definition.
+func (p *Parser2) definitionHeader() (string, string, *string) {
+	if !p.checkStr("@") {
+		return "", "", nil
+	}
+	name, _ := p.identifier()
+	if name == "" {
+		return "", "", nil
+	}
+	typeParams := ""
+	if tps := p.squareBrackets(); tps != nil {
+		typeParams = *tps
+	}
+	params := p.parentheses(false)
+	if params == nil {
+		return "", "", nil
+	}
+	p.whitespaceNoBreak()
+	if !p.checkStr("=") {
+		return "", "", nil
+	}
+	p.whitespaceNoBreak()
+	if p.input.isEOF() || p.input.apply(1) != "{" {
+		return "", "", nil
+	}
+	return name, typeParams, params
+}
+
+// Reports whether a template definition starts at the start of the current
+// line.  Definitions can only be at the top level, so one inside of a block
+// means that the block was never closed.
+func (p *Parser2) atDefinition() bool {
+	pos := p.input.offset()
+	if p.input.isEOF() || p.input.apply(1) != "@" || (pos > 0 && p.input.source()[pos-1] != '\n') {
+		return false
+	}
+	errorCount := len(p.errorStack)
+	_, _, params := p.definitionHeader()
+	p.backtrack(pos, errorCount)
+	return params != nil
+}
+
+// Checks that the parameters with default values come after all of the
+// parameters without them, since they are passed as options.
+func (p *Parser2) checkParams(params string, offset int) {
+	optional := false
+	for _, param := range ParseParams(params) {
+		if param.Default != "" {
+			optional = true
+		} else if optional {
+			p.error(fmt.Sprintf("Parameter %s must have a default value, since it follows a parameter with one", param.Name), offset, offset+len(params))
+			return
+		}
+	}
+}
+
 func (p *Parser2) TemplateContent() []TemplateTree2 {
 	mixeds := []TemplateTree2{}
 
-	done := false
-
-	for !done {
-		mix := p.Mixed()
-		if mix != nil {
-			mixeds = append(mixeds, *mix)
+	for {
+		def := p.TemplateDefinition()
+		if def != nil {
+			mixeds = append(mixeds, *def)
+			continue
+		}
+		var more bool
+		mixeds, more = p.nextMixed(mixeds)
+		if more {
 			continue
 		}
 		pos := p.input.offset()
-		if p.checkStr("@") {
-			p.error("Invalid '@' symbol", pos, pos+1)
-		} else {
-			done = true
+		if !p.checkStr("}") {
+			break
 		}
+		// A brace that doesn't close anything is reported, and the content
+		// after it is still parsed.
+		p.error("Unexpected '}', use @} to output a brace", pos, pos+1)
 	}
 
 	return mixeds
 }
 
+// Moves the templates defined at the top level of the content with exported
+// names into templates of their own, since they are generated as separate
+// functions.  Templates defined with unexported names stay in the content.
+func (p *Parser2) exportedTemplates(name string, content []TemplateTree2) ([]TemplateTree2, []Template2) {
+	remaining := make([]TemplateTree2, 0, len(content))
+	templates := []Template2{}
+	defined := map[string]bool{name: true}
+	for _, tree := range content {
+		if tree.Type != TT2TemplateDef {
+			remaining = append(remaining, tree)
+			continue
+		}
+		defName, typeParams, params := tree.Declaration()
+		if defined[defName] {
+			p.errorAt(fmt.Sprintf("Template %s is already defined", defName), &tree, len(defName))
+			continue
+		}
+		defined[defName] = true
+		first, _ := utf8.DecodeRuneInString(defName)
+		if !unicode.IsUpper(first) {
+			remaining = append(remaining, tree)
+			continue
+		}
+		offset := tree.TextRange().Start.Offset()
+		namePs := NewPosString(defName)
+		p.positionString(&namePs, offset)
+		typeParamsPs := NewPosString(typeParams)
+		p.positionString(&typeParamsPs, offset+len(defName))
+		paramsPs := NewPosString(params)
+		p.positionString(&paramsPs, offset+len(defName)+len(typeParams))
+		var defContent []TemplateTree2
+		if len(tree.Children) > 0 {
+			defContent = tree.Children[0]
+		}
+		var comment *TemplateTree2
+		remaining, comment = docComment(remaining)
+		t := NewTemplate2(namePs, comment, paramsPs, []Import{}, defContent)
+		t.TypeParams = typeParamsPs
+		t.SetPos(namePs.Range().Start)
+		t.SetRange(tree.Range())
+		templates = append(templates, t)
+	}
+	p.localTemplateCalls(name, remaining, templates)
+	return remaining, templates
+}
+
+// Reports the calls from the exported templates of a file to the templates
+// defined in the body of its main template.  Those are local to the function of
+// the main template, so the functions of the other templates can't call them.
+func (p *Parser2) localTemplateCalls(name string, content []TemplateTree2, templates []Template2) {
+	locals := map[string]bool{}
+	for _, tree := range content {
+		if tree.Type == TT2TemplateDef {
+			defName, _, _ := tree.Declaration()
+			locals[defName] = true
+		}
+	}
+	if len(locals) == 0 {
+		return
+	}
+	for _, t := range templates {
+		// The templates an exported template defines itself hide the ones
+		// of the main template.
+		visible := map[string]bool{}
+		for local := range locals {
+			visible[local] = true
+		}
+		for _, tree := range t.Content {
+			if tree.Type == TT2TemplateDef {
+				defName, _, _ := tree.Declaration()
+				delete(visible, defName)
+			}
+		}
+		p.localCalls(visible, name, t.Name.Str, t.Content)
+	}
+}
+
+func (p *Parser2) localCalls(locals map[string]bool, main string, caller string, trees []TemplateTree2) {
+	for i := range trees {
+		tree := &trees[i]
+		switch tree.Type {
+		case TT2GoExp, TT2GoBlock, TT2If, TT2ElseIf, TT2For, TT2Switch, TT2Case:
+			for _, call := range calledNames(tree.Text) {
+				if !locals[call.name] {
+					continue
+				}
+				start := tree.TextRange().Start.Offset() + call.offset
+				msg := fmt.Sprintf("%s is defined in the body of %s, so %s can't call it, define it in %s or as an exported template instead", call.name, main, caller, caller)
+				p.error(msg, start, start+len(call.name))
+			}
+		}
+		for _, children := range tree.Children {
+			p.localCalls(locals, main, caller, children)
+		}
+	}
+}
+
+type calledName struct {
+	name   string
+	offset int
+}
+
+// Returns the names of the functions called in Go code, with their offsets in
+// the code, leaving out methods and the functions of packages.
+func calledNames(code string) []calledName {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", -1, len(code))
+	s := scanner.Scanner{}
+	s.Init(file, []byte(code), nil, 0)
+	calls := []calledName{}
+	prev := token.ILLEGAL
+	var ident *calledName
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if ident != nil && tok == token.LPAREN {
+			calls = append(calls, *ident)
+		}
+		ident = nil
+		if tok == token.IDENT && prev != token.PERIOD {
+			ident = &calledName{name: lit, offset: file.Offset(pos)}
+		}
+		prev = tok
+	}
+	return calls
+}
+
+// Removes the comment at the end of the content when nothing but a single line
+// break separates it from what follows, and returns it as the doc comment of
+// what follows.
+func docComment(content []TemplateTree2) ([]TemplateTree2, *TemplateTree2) {
+	idx := len(content) - 1
+	breaks := 0
+	for idx >= 0 && content[idx].Type == TT2Plain && strings.TrimSpace(content[idx].Text) == "" {
+		breaks += strings.Count(content[idx].Text, "\n")
+		idx--
+	}
+	if idx < 0 || content[idx].Type != TT2BlockComment || breaks > 1 {
+		return content, nil
+	}
+	comment := content[idx]
+	return append(content[:idx], content[idx+1:]...), &comment
+}
+
 type ParseError struct {
 	Err   string
 	Start Position
@@ -708,6 +1397,12 @@ type ParseResult2 struct {
 	Errors   []ParseError
 }
 
+// Returns the line table of the source that was parsed, to find the positions
+// of offsets in it.
+func (r ParseResult2) Lines() *LineIndex {
+	return r.Input.lines
+}
+
 func (p *Parser2) constructorArgs() *PosString {
 	if p.checkStr("@(") {
 		p.input.regress(1)
@@ -715,7 +1410,7 @@ func (p *Parser2) constructorArgs() *PosString {
 		args := p.templateArgs()
 		if args != nil {
 			ps := NewPosString(*args)
-			p.position(&ps, pos)
+			p.positionString(&ps, pos)
 			return &ps
 		}
 		return nil
@@ -735,22 +1430,38 @@ func (p *Parser2) templateArgs() *string {
 	return p.parentheses(true)
 }
 
-func (p *Parser2) maybeTemplateArgs() *PosString {
-	if p.checkStr("@(") {
-		p.input.regress(1)
+// Parses the parameters of the template, along with its type parameters when
+// they come before them, like "@[T any](items []T)".
+func (p *Parser2) maybeTemplateArgs() (*PosString, *PosString) {
+	var typeParams *PosString
+	start := p.input.offset()
+	if !p.checkStr("@(") && !p.checkStr("@[") {
+		return nil, nil
+	}
+	p.input.regress(1)
+	if p.input.apply(1) == "[" {
 		pos := p.input.offset()
-		args := p.templateArgs()
-		p.logf("Args is %v\n", args)
-		if args != nil {
-			ps := NewPosString(*args)
-			p.position(&ps, pos)
-			result := ps
-			p.checkStr("\n")
-			return &result
+		tps := p.squareBrackets()
+		if tps == nil || p.input.isEOF() || p.input.apply(1) != "(" {
+			p.error("Expected parameters after the type parameters", start, p.input.offset())
+			return nil, nil
 		}
-		return nil
+		ps := NewPosString(*tps)
+		p.positionString(&ps, pos)
+		typeParams = &ps
 	}
-	return nil
+	pos := p.input.offset()
+	args := p.templateArgs()
+	p.logf("Args is %v\n", args)
+	if args != nil {
+		p.checkParams(*args, pos)
+		ps := NewPosString(*args)
+		p.positionString(&ps, pos)
+		result := ps
+		p.checkStr("\n")
+		return typeParams, &result
+	}
+	return typeParams, nil
 }
 
 func (p *Parser2) Parse(source string, name string) ParseResult2 {
@@ -758,10 +1469,11 @@ func (p *Parser2) Parse(source string, name string) ParseResult2 {
 	p.errorStack = make([]ParseError, 0, 0)
 
 	_, comment := p.parseConstructorAndArgComment()
-	args := p.maybeTemplateArgs()
+	typeParams, args := p.maybeTemplateArgs()
 	p.log("Looking for top imports")
 	topImports := p.TopImports()
 	p.logf("TopImports, %v", topImports)
+	p.rawDeclared = declaresRaw(args, topImports)
 	mixeds := p.TemplateContent()
 	var templateArgs PosString
 	if args == nil {
@@ -770,6 +1482,9 @@ func (p *Parser2) Parse(source string, name string) ParseResult2 {
 		templateArgs = *args
 	}
 
+	p.strandedSlots(mixeds)
+	mixeds, templates := p.exportedTemplates(name, mixeds)
+
 	template := NewTemplate2(
 		NewPosString(name),
 		comment,
@@ -777,6 +1492,11 @@ func (p *Parser2) Parse(source string, name string) ParseResult2 {
 		topImports,
 		mixeds,
 	)
+	template.Templates = templates
+	template.SetRange(p.input.rangeOf(0, len(source)))
+	if typeParams != nil {
+		template.TypeParams = *typeParams
+	}
 
 	if len(p.errorStack) > 0 {
 		p.logf("Errors found while parsing\n")
@@ -788,6 +1508,24 @@ func (p *Parser2) Parse(source string, name string) ParseResult2 {
 	return ParseResult2{template, p.input, p.errorStack}
 }
 
+// Reports whether a parameter or an imported package of a template is named
+// raw.
+func declaresRaw(args *PosString, imports []Import) bool {
+	if args != nil {
+		for _, param := range ParseParams(args.Str) {
+			if param.Name == "raw" {
+				return true
+			}
+		}
+	}
+	for _, imp := range imports {
+		if imp.PackageName() == "raw" {
+			return true
+		}
+	}
+	return false
+}
+
 func NewParser2(source string) Parser2 {
 	in := input{}
 	in.reset(source)
@@ -6,20 +6,27 @@ import (
 )
 
 type Template2 struct {
-	Name       PosString
-	Comment    *TemplateTree2
-	Params     PosString
-	TopImports []PosString
+	Name    PosString
+	Comment *TemplateTree2
+	Params  PosString
+	// The type parameters of the template including the surrounding brackets,
+	// or empty if it isn't generic.
+	TypeParams PosString
+	TopImports []Import
 	Content    []TemplateTree2
-	column     int
-	line       int
+	// The exported templates defined in the same file, which are generated as
+	// functions of their own.
+	Templates []Template2
+	column    int
+	line      int
+	rng       Range
 }
 
 func NewTemplate2(
 	name PosString,
 	comment *TemplateTree2,
 	params PosString,
-	topImports []PosString,
+	topImports []Import,
 	content []TemplateTree2,
 ) Template2 {
 	return Template2{
@@ -46,14 +53,31 @@ func (t *Template2) SetPos(pos Position) {
 	t.line = pos.Line()
 }
 
+// Returns the range of the source of the template, which is the whole file
+// for the template of a file, and the definition for the templates defined in
+// it.
+func (t *Template2) Range() Range {
+	return t.rng
+}
+
+func (t *Template2) SetRange(r Range) {
+	t.rng = r
+}
+
 func (t Template2) String() string {
 	sb := strings.Builder{}
 	sb.WriteString("Template2 {\n")
 	sb.WriteString(fmt.Sprintf("\tName: \"%s\",\n", t.Name))
 	sb.WriteString(fmt.Sprintf("\tComment: %v,\n", t.Comment))
+	if t.TypeParams.Str != "" {
+		sb.WriteString(fmt.Sprintf("\tTypeParams = %v,\n", t.TypeParams))
+	}
 	sb.WriteString(fmt.Sprintf("\tParams = %v,\n", t.Params))
 	sb.WriteString(fmt.Sprintf("\tTopImports = %v,\n", t.TopImports))
 	sb.WriteString(fmt.Sprintf("\tContent = %v\n", t.Content))
+	if len(t.Templates) > 0 {
+		sb.WriteString(fmt.Sprintf("\tTemplates = %v\n", t.Templates))
+	}
 	sb.WriteString(fmt.Sprintf("\tPos = (%d, %d)\n", t.line, t.column))
 	sb.WriteString("}")
 	return sb.String()
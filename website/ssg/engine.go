@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/gamebox/gwirl"
 	"github.com/yuin/goldmark"
 	meta "github.com/yuin/goldmark-meta"
 	"github.com/yuin/goldmark/extension"
@@ -16,13 +17,13 @@ import (
 
 type Engine struct {
     docFiles []File
-    layoutTemplate func(Page, string) string
+    layoutTemplate func(Page, gwirl.HTML) gwirl.HTML
     outputDir string
     baseDir string
     markdown goldmark.Markdown
 }
 
-type LayoutTemplate func(Page, string) string
+type LayoutTemplate func(Page, gwirl.HTML) gwirl.HTML
 
 func NewEngine(docBaseDir string, layoutTemplate LayoutTemplate, outputDir string) *Engine {
     eng := Engine{ layoutTemplate: layoutTemplate, outputDir: outputDir, baseDir: docBaseDir }
@@ -85,7 +86,7 @@ func (e *Engine) writeFiles() {
         e.ensureDirectoryExists(dirPath)
 
         page := NewFilePage(e.docFiles[i], pages)
-        content := e.layoutTemplate(&page, e.docFiles[i].html)
+        content := e.layoutTemplate(&page, gwirl.HTML(e.docFiles[i].html))
         err := os.WriteFile(htmlFilePath, []byte(content), 0644)
         if err != nil {
             log.Fatalf("Error writing file %s: %v", htmlFilePath, err)